@@ -1,15 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // embed the timezone database for the timezone parameter
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"hatena-bookmark-mcp/internal/config"
+	"hatena-bookmark-mcp/internal/format"
 	"hatena-bookmark-mcp/internal/service"
 	"hatena-bookmark-mcp/internal/types"
+	"hatena-bookmark-mcp/internal/utils"
 )
 
 const (
@@ -19,11 +29,160 @@ const (
 
 // GetHatenaBookmarksParams represents the parameters for the tool
 type GetHatenaBookmarksParams struct {
+	Username string            `json:"username,omitempty"`
+	Tag      string            `json:"tag,omitempty"`
+	Date     string            `json:"date,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Page     types.FlexibleInt `json:"page,omitempty"`
+
+	GroupByDate  bool   `json:"group_by_date,omitempty"`
+	IncludeScore bool   `json:"include_score,omitempty"`
+	SortBy       string `json:"sort_by,omitempty"`
+	ErrorOnEmpty bool   `json:"error_on_empty,omitempty"`
+
+	ResolveShortURLs bool   `json:"resolve_short_urls,omitempty"`
+	DateFormat       string `json:"date_format,omitempty"`
+	Timezone         string `json:"timezone,omitempty"`
+	IncludeHeaders   bool   `json:"include_headers,omitempty"`
+
+	CollapseDuplicateTitles bool `json:"collapse_duplicate_titles,omitempty"`
+	FetchAll                bool `json:"fetch_all,omitempty"`
+	SortTags                bool `json:"sort_tags,omitempty"`
+	BestEffort              bool `json:"best_effort,omitempty"`
+	IncludeEpoch            bool `json:"include_epoch,omitempty"`
+	AnnotatePage            bool `json:"annotate_page,omitempty"`
+	TagTree                 bool `json:"tag_tree,omitempty"`
+	LowercaseTags           bool `json:"lowercase_tags,omitempty"`
+
+	TagSeparator string            `json:"tag_separator,omitempty"`
+	MinResults   types.FlexibleInt `json:"min_results,omitempty"`
+
+	Format            string `json:"format,omitempty"`
+	KeyCase           string `json:"key_case,omitempty"`
+	WarningsAsContent bool   `json:"warnings_as_content,omitempty"`
+
+	MaxOutputBytes types.FlexibleInt `json:"max_output_bytes,omitempty"`
+
+	NoCache bool `json:"no_cache,omitempty"`
+
+	RawXML string `json:"raw_xml,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// GetAllTaggedParams represents the parameters for the get_all_tagged tool
+type GetAllTaggedParams struct {
+	Username string `json:"username"`
+	Tag      string `json:"tag"`
+}
+
+// GetTagCountsParams represents the parameters for the get_tag_counts tool
+type GetTagCountsParams struct {
+	Username      string            `json:"username"`
+	TopN          types.FlexibleInt `json:"top_n,omitempty"`
+	WithFirstSeen bool              `json:"with_first_seen,omitempty"`
+}
+
+// GetTodaysBookmarksParams represents the parameters for the get_todays_bookmarks tool
+type GetTodaysBookmarksParams struct {
+	Username string `json:"username"`
+}
+
+// GetBookmarkStatsParams represents the parameters for the get_bookmark_stats tool
+type GetBookmarkStatsParams struct {
+	Username string `json:"username"`
+}
+
+// ExportBookmarksRSSParams represents the parameters for the export_bookmarks_rss tool
+type ExportBookmarksRSSParams struct {
+	Username string            `json:"username"`
+	Tag      string            `json:"tag,omitempty"`
+	Date     string            `json:"date,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Page     types.FlexibleInt `json:"page,omitempty"`
+}
+
+// SuggestTagsParams represents the parameters for the suggest_tags tool
+type SuggestTagsParams struct {
+	Username string            `json:"username"`
+	Tag      string            `json:"tag"`
+	TopN     types.FlexibleInt `json:"top_n,omitempty"`
+}
+
+// InvalidateCacheParams represents the parameters for the invalidate_cache tool
+type InvalidateCacheParams struct {
+	Username string `json:"username"`
+}
+
+// GetCommentKeywordsParams represents the parameters for the get_comment_keywords tool
+type GetCommentKeywordsParams struct {
+	Username  string            `json:"username"`
+	TopN      types.FlexibleInt `json:"top_n,omitempty"`
+	MinLength types.FlexibleInt `json:"min_length,omitempty"`
+}
+
+// GetEntryDetailParams represents the parameters for the get_entry_detail tool
+type GetEntryDetailParams struct {
+	URL string `json:"url"`
+}
+
+// GetReadLaterParams represents the parameters for the get_read_later tool
+type GetReadLaterParams struct {
+	Username      string            `json:"username"`
+	OlderThanDays types.FlexibleInt `json:"older_than_days,omitempty"`
+}
+
+// GetDomainCountParams represents the parameters for the get_domain_count tool
+type GetDomainCountParams struct {
+	Username    string `json:"username"`
+	GroupByPath bool   `json:"group_by_path,omitempty"`
+}
+
+// GetUserOverviewParams represents the parameters for the get_user_overview tool
+type GetUserOverviewParams struct {
+	Username    string            `json:"username"`
+	RecentCount types.FlexibleInt `json:"recent_count,omitempty"`
+}
+
+// FilterBookmarksParams represents the parameters for the filter_bookmarks tool
+type FilterBookmarksParams struct {
+	Username   string            `json:"username"`
+	HasComment *bool             `json:"has_comment,omitempty"`
+	HasTags    *bool             `json:"has_tags,omitempty"`
+	Page       types.FlexibleInt `json:"page,omitempty"`
+}
+
+// CompareUsersParams represents the parameters for the compare_users tool
+type CompareUsersParams struct {
+	UsernameA   string            `json:"username_a"`
+	UsernameB   string            `json:"username_b"`
+	WithinPages types.FlexibleInt `json:"within_pages,omitempty"`
+}
+
+// GetRecentDomainsParams represents the parameters for the get_recent_domains tool
+type GetRecentDomainsParams struct {
+	Username string            `json:"username"`
+	Limit    types.FlexibleInt `json:"limit,omitempty"`
+}
+
+// GetTagFeedParams represents the parameters for the get_tag_feed tool
+type GetTagFeedParams struct {
+	Tag  string            `json:"tag"`
+	Sort string            `json:"sort,omitempty"`
+	Page types.FlexibleInt `json:"page,omitempty"`
+}
+
+// DebugParseParams represents the parameters for the debug_parse tool
+type DebugParseParams struct {
+	Username string            `json:"username"`
+	Tag      string            `json:"tag,omitempty"`
+	Date     string            `json:"date,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Page     types.FlexibleInt `json:"page,omitempty"`
+}
+
+// CheckUserParams represents the parameters for the check_user tool
+type CheckUserParams struct {
 	Username string `json:"username"`
-	Tag      string `json:"tag,omitempty"`
-	Date     string `json:"date,omitempty"`
-	URL      string `json:"url,omitempty"`
-	Page     int    `json:"page,omitempty"`
 }
 
 func main() {
@@ -32,7 +191,33 @@ func main() {
 	logger.Info("Starting Hatena Bookmark MCP Server", "version", ServerVersion)
 
 	// Initialize services
-	bookmarkService := service.NewBookmarkService(logger)
+	serviceOptions, err := loadServiceOptions()
+	if err != nil {
+		logger.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if path := os.Getenv("HATENA_CONFIG"); path != "" {
+		fileConfig, err := config.Load(path)
+		if err != nil {
+			logger.Error("Invalid configuration file", "error", err)
+			os.Exit(1)
+		}
+		serviceOptions = fileConfig.Apply(serviceOptions)
+		logger.Info("Loaded configuration file", "path", path)
+	}
+	if err := serviceOptions.Validate(); err != nil {
+		logger.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	bookmarkService := service.NewBookmarkServiceWithOptions(logger, serviceOptions)
+	defer bookmarkService.Close()
+	formatters := format.NewRegistry()
+
+	defaults := loadBookmarkDefaults()
+	if err := defaults.validate(formatters); err != nil {
+		logger.Error("Invalid default parameters", "error", err)
+		os.Exit(1)
+	}
 
 	// Create MCP server with implementation
 	server := mcp.NewServer(&mcp.Implementation{
@@ -45,10 +230,171 @@ func main() {
 		Name:        "get_hatena_bookmarks",
 		Description: "Retrieve bookmarks from Hatena Bookmark RSS feed for a specified user with optional filtering",
 	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetHatenaBookmarksParams]) (*mcp.CallToolResultFor[interface{}], error) {
-		return handleGetBookmarks(ctx, params.Arguments, bookmarkService, logger)
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetBookmarks(ctx, params.Arguments, bookmarkService, formatters, defaults, logger)
+	})
+
+	// Register the get_all_tagged tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_all_tagged",
+		Description: "Retrieve all bookmarks with a tag for a user by merging the query-style and path-style tag feeds",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetAllTaggedParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetAllTagged(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_tag_counts tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_tag_counts",
+		Description: "Count how many of a user's bookmarks carry each tag, optionally with the date each tag was first used",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetTagCountsParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetTagCounts(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_todays_bookmarks tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_todays_bookmarks",
+		Description: "Retrieve bookmarks a user added today (JST)",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetTodaysBookmarksParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetTodaysBookmarks(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_bookmark_stats tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_bookmark_stats",
+		Description: "Compute aggregate statistics over a user's bookmarks (tags, domains, comments, date span)",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetBookmarkStatsParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetBookmarkStats(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the export_bookmarks_rss tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "export_bookmarks_rss",
+		Description: "Retrieve a user's bookmarks with optional filtering and re-export them as an RSS 2.0 feed",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ExportBookmarksRSSParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleExportBookmarksRSS(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the suggest_tags tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "suggest_tags",
+		Description: "Suggest tags that most frequently co-occur with a given tag in a user's bookmarks",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[SuggestTagsParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleSuggestTags(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the invalidate_cache tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "invalidate_cache",
+		Description: "Drop a user's cached get_hatena_bookmarks results so that the next calls fetch fresh data, e.g. after editing bookmarks",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[InvalidateCacheParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleInvalidateCache(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the check_user tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "check_user",
+		Description: "Check whether a user's bookmark feed exists without downloading its items",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[CheckUserParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleCheckUser(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_comment_keywords tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_comment_keywords",
+		Description: "Get the most frequent words across the comments of a user's bookmarks",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetCommentKeywordsParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetCommentKeywords(ctx, params.Arguments, bookmarkService, logger)
 	})
 
-	logger.Info("Registered MCP tools", "tool_count", 1)
+	// Register the get_entry_detail tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_entry_detail",
+		Description: "Get an entry's bookmark count, recent bookmarkers with comments, and star count",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetEntryDetailParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetEntryDetail(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_read_later tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_read_later",
+		Description: "Get a user's read-later (あとで読む) bookmarks, oldest first, optionally only those older than N days",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetReadLaterParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetReadLater(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_domain_count tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_domain_count",
+		Description: "Get the number of distinct domains a user has bookmarked and the most frequent ones",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetDomainCountParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetDomainCount(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_tag_feed tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_tag_feed",
+		Description: "Get hot or recent bookmarks for a tag across all Hatena Bookmark users",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetTagFeedParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetTagFeed(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_recent_domains tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_recent_domains",
+		Description: "Get the domains a user bookmarked most recently, newest first, with their latest bookmark and a favicon URL",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetRecentDomainsParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetRecentDomains(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the get_user_overview tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_user_overview",
+		Description: "Get a profile overview of a user in one call: bookmark total, top tags and domains, monthly activity and recent bookmarks",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetUserOverviewParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleGetUserOverview(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the compare_users tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "compare_users",
+		Description: "Compare two users' recent bookmarks: URLs both bookmarked, counts unique to each and a Jaccard similarity score",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[CompareUsersParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleCompareUsers(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	// Register the filter_bookmarks tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "filter_bookmarks",
+		Description: "Get the bookmarks on a feed page that have, or lack, a comment and tags, for archive triage",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[FilterBookmarksParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleFilterBookmarks(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	toolCount := 18 + registerDebugTools(server, bookmarkService, logger)
+
+	logger.Info("Registered MCP tools", "tool_count", toolCount)
+
+	// Warm the cache for configured users without delaying startup
+	if usernames := envList("HATENA_WARM_USERNAMES"); len(usernames) > 0 {
+		go warmCache(bookmarkService, usernames, logger)
+	}
 
 	// Start server with stdio transport
 	if err := server.Run(context.Background(), mcp.NewStdioTransport()); err != nil {
@@ -57,6 +403,24 @@ func main() {
 	}
 }
 
+// warmCache fetches the first page of each user's bookmarks into the cache
+func warmCache(bookmarkService *service.BookmarkService, usernames []string, logger *slog.Logger) {
+	params := make([]types.GetHatenaBookmarksParams, len(usernames))
+	for i, username := range usernames {
+		params[i] = types.GetHatenaBookmarksParams{Username: username}
+	}
+
+	warmed := 0
+	for _, result := range bookmarkService.Warm(context.Background(), params) {
+		if result.Err != nil {
+			logger.Warn("Failed to warm cache", "username", result.Params.Username, "error", result.Err)
+			continue
+		}
+		warmed++
+	}
+	logger.Info("Warmed cache", "users", len(usernames), "warmed", warmed)
+}
+
 // initLogger initializes the structured logger
 func initLogger() *slog.Logger {
 	// Get log level from environment variable
@@ -83,59 +447,805 @@ func initLogger() *slog.Logger {
 	return slog.New(handler)
 }
 
+// loadServiceOptions builds service options from environment variables,
+// returning an error if a value cannot be parsed
+func loadServiceOptions() (service.Options, error) {
+	var errs []error
+	collect := func(err error) {
+		errs = append(errs, err)
+	}
+
+	cacheTTL, err := envDuration("HATENA_CACHE_TTL")
+	collect(err)
+	cacheNegativeTTL, err := envDuration("HATENA_CACHE_NEGATIVE_TTL")
+	collect(err)
+	cacheRefreshAhead, err := envDuration("HATENA_CACHE_REFRESH_AHEAD")
+	collect(err)
+	maxTagsPerItem, err := envInt("HATENA_MAX_TAGS_PER_ITEM")
+	collect(err)
+	maxTitleLength, err := envInt("HATENA_MAX_TITLE_LENGTH")
+	collect(err)
+	minCommentLength, err := envInt("HATENA_MIN_COMMENT_LENGTH")
+	collect(err)
+	streamThreshold, err := envInt("HATENA_STREAM_THRESHOLD")
+	collect(err)
+
+	if err := errors.Join(errs...); err != nil {
+		return service.Options{}, err
+	}
+
+	return service.Options{
+		BaseURL:       os.Getenv("HATENA_BASE_URL"),
+		AllowedHosts:  envList("HATENA_ALLOWED_HOSTS"),
+		AllowAnyHost:  envBool("HATENA_ALLOW_ANY_HOST"),
+		LogHTTPBodies: envBool("LOG_HTTP_BODIES"),
+
+		CacheTTL:          cacheTTL,
+		CacheNegativeTTL:  cacheNegativeTTL,
+		CacheRefreshAhead: cacheRefreshAhead,
+
+		MaxTagsPerItem:    maxTagsPerItem,
+		MaxTitleLength:    maxTitleLength,
+		DatePreference:    strings.ToLower(strings.TrimSpace(os.Getenv("HATENA_DATE_PREFERENCE"))),
+		MinCommentLength:  minCommentLength,
+		MissingLinkPolicy: strings.ToLower(strings.TrimSpace(os.Getenv("HATENA_MISSING_LINK_POLICY"))),
+		DeriveUntitled:    envBool("HATENA_DERIVE_UNTITLED"),
+		StreamThreshold:   streamThreshold,
+
+		PinnedCertSHA256: envList("HATENA_PINNED_CERT_SHA256"),
+
+		AuthMode:     strings.ToLower(os.Getenv("HATENA_AUTH_MODE")),
+		AuthUsername: os.Getenv("HATENA_AUTH_USERNAME"),
+		AuthToken:    os.Getenv("HATENA_AUTH_TOKEN"),
+
+		ShortenerHosts: envList("HATENA_SHORTENER_HOSTS"),
+		DisableHTTP2:   envBool("HATENA_DISABLE_HTTP2"),
+		Accept:         strings.TrimSpace(os.Getenv("HATENA_ACCEPT")),
+
+		LoginPathPattern: os.Getenv("HATENA_LOGIN_PATH_PATTERN"),
+		ReadLaterTag:   os.Getenv("HATENA_READ_LATER_TAG"),
+		TagAliases:     envMap("HATENA_TAG_ALIASES"),
+	}, nil
+}
+
+// registerDebugTools registers the debugging tools when DEBUG_TOOLS is set,
+// returning the number of tools registered
+func registerDebugTools(server *mcp.Server, bookmarkService *service.BookmarkService, logger *slog.Logger) int {
+	if !envBool("DEBUG_TOOLS") {
+		return 0
+	}
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "debug_parse",
+		Description: "Debugging tool: return the parser's raw output for a feed page without service-level filtering or sorting",
+	}, func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[DebugParseParams]) (*mcp.CallToolResultFor[interface{}], error) {
+		ctx, logger := requestScope(ctx, params.Meta, logger)
+		return handleDebugParse(ctx, params.Arguments, bookmarkService, logger)
+	})
+
+	return 1
+}
+
+// bookmarkDefaults holds get_hatena_bookmarks parameter values used when a
+// call leaves them empty, so that single-user deployments need not repeat them
+type bookmarkDefaults struct {
+	Username string
+	Timezone string
+	Format   string
+}
+
+// loadBookmarkDefaults reads the default parameter values from the environment
+func loadBookmarkDefaults() bookmarkDefaults {
+	return bookmarkDefaults{
+		Username: strings.TrimSpace(os.Getenv("HATENA_DEFAULT_USERNAME")),
+		Timezone: strings.TrimSpace(os.Getenv("HATENA_DEFAULT_TIMEZONE")),
+		Format:   strings.TrimSpace(os.Getenv("HATENA_DEFAULT_FORMAT")),
+	}
+}
+
+// validate checks the defaults at startup so that a misconfiguration is not
+// reported as a parameter error on every call
+func (d bookmarkDefaults) validate(formatters *format.Registry) error {
+	if d.Username != "" {
+		if err := utils.NewValidator().ValidateUsername(d.Username); err != nil {
+			return fmt.Errorf("HATENA_DEFAULT_USERNAME: %w", err)
+		}
+	}
+	if d.Timezone != "" {
+		if _, err := time.LoadLocation(d.Timezone); err != nil {
+			return fmt.Errorf("HATENA_DEFAULT_TIMEZONE: %w", err)
+		}
+	}
+	if d.Format != "" {
+		if _, err := formatters.Get(d.Format); err != nil {
+			return fmt.Errorf("HATENA_DEFAULT_FORMAT: %w", err)
+		}
+	}
+	return nil
+}
+
+// apply fills the arguments left empty with the defaults. Explicit arguments
+// always win, and no default username is applied to raw XML input.
+func (d bookmarkDefaults) apply(arguments *GetHatenaBookmarksParams) {
+	if strings.TrimSpace(arguments.Username) == "" && arguments.RawXML == "" {
+		arguments.Username = d.Username
+	}
+	if arguments.Timezone == "" {
+		arguments.Timezone = d.Timezone
+	}
+	if arguments.Format == "" {
+		arguments.Format = d.Format
+	}
+}
+
+// envBool reports whether the environment variable is set to a true value
+func envBool(name string) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	return err == nil && value
+}
+
+// envDuration parses a duration such as "5m" from an environment variable,
+// returning 0 if it is unset and an error naming the variable if it is invalid
+func envDuration(name string) (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return 0, nil
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid duration %q: %w", name, raw, err)
+	}
+	return value, nil
+}
+
+// envInt parses an integer from an environment variable, returning 0 if it
+// is unset and an error naming the variable if it is invalid
+func envInt(name string) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid integer %q: %w", name, raw, err)
+	}
+	return value, nil
+}
+
+// envList splits a comma-separated environment variable into trimmed values
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// envMap parses a comma-separated list of key=value pairs from an
+// environment variable. An entry without "=" maps the key to an empty value.
+func envMap(name string) map[string]string {
+	values := envList(name)
+	if len(values) == 0 {
+		return nil
+	}
+
+	result := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, _ := strings.Cut(value, "=")
+		result[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return result
+}
+
+// requestIDMetaKey is the _meta key under which a client or gateway may pass
+// its request ID with a tool call
+const requestIDMetaKey = "request_id"
+
+// requestScope returns ctx carrying the request ID of a tool call, and
+// logger annotated with it, so that the log lines of the call and its
+// requests to Hatena can be correlated. The ID is taken from ctx if a
+// transport already set one, then from the call's _meta, and generated
+// otherwise.
+func requestScope(ctx context.Context, meta mcp.Meta, logger *slog.Logger) (context.Context, *slog.Logger) {
+	if utils.RequestIDFromContext(ctx) == "" {
+		requestID, _ := meta[requestIDMetaKey].(string)
+		if !utils.IsValidRequestID(requestID) {
+			requestID = utils.NewRequestID()
+		}
+		ctx = utils.WithRequestID(ctx, requestID)
+	}
+	return ctx, utils.LoggerFromContext(ctx, logger)
+}
+
 // handleGetBookmarks handles the get_hatena_bookmarks tool call
 func handleGetBookmarks(
 	ctx context.Context,
 	arguments GetHatenaBookmarksParams,
 	bookmarkService *service.BookmarkService,
+	formatters *format.Registry,
+	defaults bookmarkDefaults,
 	logger *slog.Logger,
 ) (*mcp.CallToolResultFor[interface{}], error) {
 	logger.Debug("Handling get_hatena_bookmarks request", "arguments", arguments)
 
+	defaults.apply(&arguments)
+
+	// Resolve the output format before doing any work
+	formatter, err := formatters.Get(arguments.Format)
+	if err != nil {
+		return createErrorResult(err), nil
+	}
+	if err := format.ValidateKeyCase(arguments.KeyCase); err != nil {
+		return createErrorResult(err), nil
+	}
+	formatter = format.WithKeyCase(formatter, arguments.KeyCase)
+	if arguments.MaxOutputBytes < 0 {
+		return createErrorResult(&types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "max_output_bytes must not be negative",
+			Details: map[string]interface{}{"max_output_bytes": int(arguments.MaxOutputBytes)},
+		}), nil
+	}
+
 	// Convert to internal types
 	params := types.GetHatenaBookmarksParams{
 		Username: arguments.Username,
 		Tag:      arguments.Tag,
 		Date:     arguments.Date,
 		URL:      arguments.URL,
-		Page:     arguments.Page,
+		Page:     int(arguments.Page),
+
+		GroupByDate:  arguments.GroupByDate,
+		IncludeScore: arguments.IncludeScore,
+		SortBy:       arguments.SortBy,
+		ErrorOnEmpty: arguments.ErrorOnEmpty,
+
+		ResolveShortURLs: arguments.ResolveShortURLs,
+		DateFormat:       arguments.DateFormat,
+		Timezone:         arguments.Timezone,
+		IncludeHeaders:   arguments.IncludeHeaders,
+
+		CollapseDuplicateTitles: arguments.CollapseDuplicateTitles,
+		FetchAll:                arguments.FetchAll,
+		SortTags:                arguments.SortTags,
+		BestEffort:              arguments.BestEffort,
+		IncludeEpoch:            arguments.IncludeEpoch,
+		AnnotatePage:            arguments.AnnotatePage,
+		TagTree:                 arguments.TagTree,
+		LowercaseTags:           arguments.LowercaseTags,
+
+		TagSeparator: arguments.TagSeparator,
+		MinResults:   int(arguments.MinResults),
+
+		NoCache: arguments.NoCache,
+
+		RawXML: arguments.RawXML,
+		Cursor: arguments.Cursor,
 	}
 
 	// Get bookmarks from service
 	result, err := bookmarkService.GetBookmarks(ctx, params)
 	if err != nil {
 		logger.Error("Failed to get bookmarks", "error", err, "params", params)
-		
-		// Check if it's an MCP error
-		if mcpErr, ok := err.(*types.MCPError); ok {
-			return &mcp.CallToolResultFor[interface{}]{
-				IsError: true,
-				Content: []mcp.Content{
-					&mcp.TextContent{Text: mcpErr.Message},
-				},
-			}, nil
-		}
-		
-		// Generic error
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully retrieved bookmarks", 
+		"username", params.Username,
+		"bookmark_count", len(result.Bookmarks))
+
+	toolResult := createSuccessResult(result, formatter, int(arguments.MaxOutputBytes))
+	if arguments.WarningsAsContent && len(result.Warnings) > 0 && !toolResult.IsError {
+		toolResult.Content = append(toolResult.Content, createWarningsContent(result.Warnings))
+	}
+
+	return toolResult, nil
+}
+
+// handleGetAllTagged handles the get_all_tagged tool call
+func handleGetAllTagged(
+	ctx context.Context,
+	arguments GetAllTaggedParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_all_tagged request", "arguments", arguments)
+
+	result, err := bookmarkService.GetAllTagged(ctx, arguments.Username, arguments.Tag)
+	if err != nil {
+		logger.Error("Failed to get tagged bookmarks", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully retrieved tagged bookmarks",
+		"username", arguments.Username,
+		"tag", arguments.Tag,
+		"bookmark_count", len(result.Bookmarks))
+
+	return createJSONResult(result), nil
+}
+
+// handleGetTagCounts handles the get_tag_counts tool call
+func handleGetTagCounts(
+	ctx context.Context,
+	arguments GetTagCountsParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_tag_counts request", "arguments", arguments)
+
+	result, err := bookmarkService.GetTagCounts(ctx, arguments.Username, int(arguments.TopN), arguments.WithFirstSeen)
+	if err != nil {
+		logger.Error("Failed to count tags", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully counted tags",
+		"username", arguments.Username,
+		"tag_count", len(result.Tags))
+
+	return createJSONResult(result), nil
+}
+
+// handleGetTodaysBookmarks handles the get_todays_bookmarks tool call
+func handleGetTodaysBookmarks(
+	ctx context.Context,
+	arguments GetTodaysBookmarksParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_todays_bookmarks request", "arguments", arguments)
+
+	result, err := bookmarkService.GetTodaysBookmarks(ctx, arguments.Username)
+	if err != nil {
+		logger.Error("Failed to get today's bookmarks", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully retrieved today's bookmarks",
+		"username", arguments.Username,
+		"bookmark_count", len(result.Bookmarks))
+
+	return createJSONResult(result), nil
+}
+
+// handleGetBookmarkStats handles the get_bookmark_stats tool call
+func handleGetBookmarkStats(
+	ctx context.Context,
+	arguments GetBookmarkStatsParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_bookmark_stats request", "arguments", arguments)
+
+	stats, err := bookmarkService.GetBookmarkStats(ctx, arguments.Username)
+	if err != nil {
+		logger.Error("Failed to get bookmark stats", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully computed bookmark stats",
+		"username", arguments.Username,
+		"bookmark_count", stats.TotalBookmarks)
+
+	return createJSONResult(stats), nil
+}
+
+// handleExportBookmarksRSS handles the export_bookmarks_rss tool call
+func handleExportBookmarksRSS(
+	ctx context.Context,
+	arguments ExportBookmarksRSSParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling export_bookmarks_rss request", "arguments", arguments)
+
+	params := types.GetHatenaBookmarksParams{
+		Username: arguments.Username,
+		Tag:      arguments.Tag,
+		Date:     arguments.Date,
+		URL:      arguments.URL,
+		Page:     int(arguments.Page),
+	}
+
+	result, err := bookmarkService.GetBookmarks(ctx, params)
+	if err != nil {
+		logger.Error("Failed to get bookmarks for export", "error", err, "params", params)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully exported bookmarks as RSS",
+		"username", params.Username,
+		"bookmark_count", len(result.Bookmarks))
+
+	return createSuccessResult(result, format.RSSFormatter{}, 0), nil
+}
+
+// handleSuggestTags handles the suggest_tags tool call
+func handleSuggestTags(
+	ctx context.Context,
+	arguments SuggestTagsParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling suggest_tags request", "arguments", arguments)
+
+	result, err := bookmarkService.SuggestTags(ctx, arguments.Username, arguments.Tag, int(arguments.TopN))
+	if err != nil {
+		logger.Error("Failed to suggest tags", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully suggested tags",
+		"username", arguments.Username,
+		"tag", arguments.Tag,
+		"suggestion_count", len(result.Suggestions))
+
+	return createJSONResult(result), nil
+}
+
+// handleInvalidateCache handles the invalidate_cache tool call
+func handleInvalidateCache(
+	ctx context.Context,
+	arguments InvalidateCacheParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling invalidate_cache request", "arguments", arguments)
+
+	result, err := bookmarkService.InvalidateCache(ctx, arguments.Username)
+	if err != nil {
+		logger.Error("Failed to invalidate cache", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully invalidated cache",
+		"username", result.User,
+		"invalidated", result.Invalidated)
+
+	return createJSONResult(result), nil
+}
+
+// handleCheckUser handles the check_user tool call
+func handleCheckUser(
+	ctx context.Context,
+	arguments CheckUserParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling check_user request", "arguments", arguments)
+
+	result, err := bookmarkService.CheckUser(ctx, arguments.Username)
+	if err != nil {
+		logger.Error("Failed to check user", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	return createJSONResult(result), nil
+}
+
+// handleGetCommentKeywords handles the get_comment_keywords tool call
+func handleGetCommentKeywords(
+	ctx context.Context,
+	arguments GetCommentKeywordsParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_comment_keywords request", "arguments", arguments)
+
+	result, err := bookmarkService.GetCommentKeywords(ctx, arguments.Username, int(arguments.TopN), int(arguments.MinLength))
+	if err != nil {
+		logger.Error("Failed to get comment keywords", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully retrieved comment keywords",
+		"username", arguments.Username,
+		"keyword_count", len(result.Keywords))
+
+	return createJSONResult(result), nil
+}
+
+// handleGetEntryDetail handles the get_entry_detail tool call
+func handleGetEntryDetail(
+	ctx context.Context,
+	arguments GetEntryDetailParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_entry_detail request", "arguments", arguments)
+
+	result, err := bookmarkService.GetEntryDetail(ctx, arguments.URL)
+	if err != nil {
+		logger.Error("Failed to get entry detail", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully retrieved entry detail",
+		"url", arguments.URL,
+		"bookmarker_count", len(result.Bookmarkers))
+
+	return createJSONResult(result), nil
+}
+
+// handleGetReadLater handles the get_read_later tool call
+func handleGetReadLater(
+	ctx context.Context,
+	arguments GetReadLaterParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_read_later request", "arguments", arguments)
+
+	result, err := bookmarkService.GetReadLater(ctx, arguments.Username, int(arguments.OlderThanDays))
+	if err != nil {
+		logger.Error("Failed to get read-later bookmarks", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully retrieved read-later bookmarks",
+		"username", arguments.Username,
+		"bookmark_count", result.Count)
+
+	return createJSONResult(result), nil
+}
+
+// handleGetDomainCount handles the get_domain_count tool call
+func handleGetDomainCount(
+	ctx context.Context,
+	arguments GetDomainCountParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_domain_count request", "arguments", arguments)
+
+	result, err := bookmarkService.GetDomainCount(ctx, arguments.Username, arguments.GroupByPath)
+	if err != nil {
+		logger.Error("Failed to get domain count", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully computed domain count",
+		"username", arguments.Username,
+		"distinct_domains", result.DistinctDomains)
+
+	return createJSONResult(result), nil
+}
+
+// handleFilterBookmarks handles the filter_bookmarks tool call
+func handleFilterBookmarks(
+	ctx context.Context,
+	arguments FilterBookmarksParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling filter_bookmarks request", "arguments", arguments)
+
+	result, err := bookmarkService.FilterBookmarks(ctx, arguments.Username, arguments.HasComment, arguments.HasTags, int(arguments.Page))
+	if err != nil {
+		logger.Error("Failed to filter bookmarks", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully filtered bookmarks",
+		"username", result.User,
+		"total_count", result.TotalCount,
+		"matched_count", result.MatchedCount)
+
+	return createJSONResult(result), nil
+}
+
+// handleCompareUsers handles the compare_users tool call
+func handleCompareUsers(
+	ctx context.Context,
+	arguments CompareUsersParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling compare_users request", "arguments", arguments)
+
+	result, err := bookmarkService.CompareUsers(ctx, arguments.UsernameA, arguments.UsernameB, int(arguments.WithinPages))
+	if err != nil {
+		logger.Error("Failed to compare users", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully compared users",
+		"username_a", result.UserA,
+		"username_b", result.UserB,
+		"shared_count", result.SharedCount)
+
+	return createJSONResult(result), nil
+}
+
+// handleGetUserOverview handles the get_user_overview tool call
+func handleGetUserOverview(
+	ctx context.Context,
+	arguments GetUserOverviewParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_user_overview request", "arguments", arguments)
+
+	result, err := bookmarkService.GetUserOverview(ctx, arguments.Username, int(arguments.RecentCount))
+	if err != nil {
+		logger.Error("Failed to get user overview", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully built user overview",
+		"username", result.User,
+		"total_bookmarks", result.TotalBookmarks)
+
+	return createJSONResult(result), nil
+}
+
+// handleGetRecentDomains handles the get_recent_domains tool call
+func handleGetRecentDomains(
+	ctx context.Context,
+	arguments GetRecentDomainsParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_recent_domains request", "arguments", arguments)
+
+	result, err := bookmarkService.GetRecentDomains(ctx, arguments.Username, int(arguments.Limit))
+	if err != nil {
+		logger.Error("Failed to get recent domains", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully retrieved recent domains",
+		"username", result.User,
+		"domain_count", result.Count)
+
+	return createJSONResult(result), nil
+}
+
+// handleGetTagFeed handles the get_tag_feed tool call
+func handleGetTagFeed(
+	ctx context.Context,
+	arguments GetTagFeedParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling get_tag_feed request", "arguments", arguments)
+
+	result, err := bookmarkService.GetTagFeed(ctx, arguments.Tag, arguments.Sort, int(arguments.Page))
+	if err != nil {
+		logger.Error("Failed to get tag feed", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	logger.Info("Successfully retrieved tag feed",
+		"tag", result.Tag,
+		"sort", result.Sort,
+		"bookmark_count", result.TotalCount)
+
+	return createJSONResult(result), nil
+}
+
+// handleDebugParse handles the debug_parse tool call
+func handleDebugParse(
+	ctx context.Context,
+	arguments DebugParseParams,
+	bookmarkService *service.BookmarkService,
+	logger *slog.Logger,
+) (*mcp.CallToolResultFor[interface{}], error) {
+	logger.Debug("Handling debug_parse request", "arguments", arguments)
+
+	result, err := bookmarkService.DebugParse(ctx, types.GetHatenaBookmarksParams{
+		Username: arguments.Username,
+		Tag:      arguments.Tag,
+		Date:     arguments.Date,
+		URL:      arguments.URL,
+		Page:     int(arguments.Page),
+	})
+	if err != nil {
+		logger.Error("Failed to parse feed", "error", err, "arguments", arguments)
+		return createErrorResult(err), nil
+	}
+
+	return createJSONResult(result), nil
+}
+
+// createErrorResult creates an error MCP tool result
+func createErrorResult(err error) *mcp.CallToolResultFor[interface{}] {
+	// Check if it's an MCP error, possibly wrapped
+	var mcpErr *types.MCPError
+	if errors.As(err, &mcpErr) {
 		return &mcp.CallToolResultFor[interface{}]{
 			IsError: true,
 			Content: []mcp.Content{
-				&mcp.TextContent{Text: "An unexpected error occurred while fetching bookmarks"},
+				&mcp.TextContent{Text: mcpErr.Message},
 			},
-		}, nil
+		}
 	}
 
-	logger.Info("Successfully retrieved bookmarks", 
-		"username", params.Username,
-		"bookmark_count", len(result.Bookmarks))
+	// Generic error
+	return &mcp.CallToolResultFor[interface{}]{
+		IsError: true,
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: "An unexpected error occurred while fetching bookmarks"},
+		},
+	}
+}
+
+// createSuccessResult creates a successful MCP tool result rendered by
+// formatter. If maxOutputBytes is positive and the output exceeds it, the
+// output is reduced step by step: JSON is first compacted, then the
+// bookmarks are dropped, leaving only counts. A second text block then notes
+// the reduction.
+func createSuccessResult(result *types.GetHatenaBookmarksResponse, formatter format.Formatter, maxOutputBytes int) *mcp.CallToolResultFor[interface{}] {
+	output, mimeType, err := formatter.Render(result)
+	if err != nil {
+		return createErrorResult(err)
+	}
+
+	reducedTo := ""
+	if maxOutputBytes > 0 && len(output) > maxOutputBytes && mimeType == "application/json" {
+		output, reducedTo = compactJSON(output), "compact JSON"
+	}
+	if maxOutputBytes > 0 && len(output) > maxOutputBytes {
+		countOnly := *result
+		countOnly.Bookmarks = []types.BookmarkItem{}
+		countOnly.DateGroups = nil
+		countOnly.TagTree = nil
+
+		output, mimeType, err = formatter.Render(&countOnly)
+		if err != nil {
+			return createErrorResult(err)
+		}
+		if mimeType == "application/json" {
+			output = compactJSON(output)
+		}
+		reducedTo = "counts only, without bookmarks"
+	}
+
+	content := []mcp.Content{
+		&mcp.TextContent{Text: output},
+	}
+	if reducedTo != "" {
+		content = append(content, &mcp.TextContent{
+			Text: fmt.Sprintf("Note: the response exceeded max_output_bytes (%d) and was reduced to %s.", maxOutputBytes, reducedTo),
+		})
+	}
+
+	return &mcp.CallToolResultFor[interface{}]{
+		IsError: false,
+		Content: content,
+	}
+}
+
+// compactJSON removes insignificant whitespace from a JSON document,
+// returning it unchanged if it is not valid JSON
+func compactJSON(output string) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(output)); err != nil {
+		return output
+	}
+	return compact.String()
+}
+
+// createWarningsContent creates a text block listing warnings, one per line
+func createWarningsContent(warnings []string) *mcp.TextContent {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Warnings (%d):\n", len(warnings))
+	for _, warning := range warnings {
+		fmt.Fprintf(&text, "- %s\n", warning)
+	}
 
-	return createSuccessResult(result), nil
+	return &mcp.TextContent{Text: text.String()}
 }
 
-// createSuccessResult creates a successful MCP tool result
-func createSuccessResult(result *types.GetHatenaBookmarksResponse) *mcp.CallToolResultFor[interface{}] {
+// createJSONResult creates a successful MCP tool result displaying v as JSON
+func createJSONResult(v interface{}) *mcp.CallToolResultFor[interface{}] {
 	// Convert result to JSON for display
-	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	resultJSON, _ := json.MarshalIndent(v, "", "  ")
 	
 	return &mcp.CallToolResultFor[interface{}]{
 		IsError: false,
@@ -0,0 +1,595 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"hatena-bookmark-mcp/internal/format"
+	"hatena-bookmark-mcp/internal/service"
+	"hatena-bookmark-mcp/internal/types"
+	"hatena-bookmark-mcp/internal/utils"
+)
+
+// testLogger returns a logger that discards its output
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// resultText returns the text of a tool result's only content block
+func resultText(t *testing.T, result *mcp.CallToolResultFor[interface{}]) string {
+	t.Helper()
+
+	if len(result.Content) != 1 {
+		t.Fatalf("got %d content blocks, want 1", len(result.Content))
+	}
+	text, ok := result.Content[0].(*mcp.TextContent)
+	if !ok {
+		t.Fatalf("content is %T, want *mcp.TextContent", result.Content[0])
+	}
+	return text.Text
+}
+
+func TestCreateErrorResult(t *testing.T) {
+	mcpErr := (&types.MCPError{Code: types.ErrorCodeNetwork, Message: "Failed to fetch RSS feed"}).WithCause(errors.New("dial tcp: refused"))
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"MCP error", mcpErr, "Failed to fetch RSS feed"},
+		{"wrapped MCP error", fmt.Errorf("page 2: %w", mcpErr), "Failed to fetch RSS feed"},
+		{"other error", errors.New("boom"), "An unexpected error occurred while fetching bookmarks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := createErrorResult(tt.err)
+			if !result.IsError {
+				t.Error("IsError = false")
+			}
+			if got := resultText(t, result); got != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleInvalidateCache(t *testing.T) {
+	bookmarkService := service.NewBookmarkService(testLogger())
+	defer bookmarkService.Close()
+
+	result, err := handleInvalidateCache(context.Background(), InvalidateCacheParams{Username: "bob"}, bookmarkService, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError {
+		t.Fatalf("IsError = true: %s", resultText(t, result))
+	}
+	var response types.InvalidateCacheResponse
+	if err := json.Unmarshal([]byte(resultText(t, result)), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.User != "bob" || response.Invalidated != 0 {
+		t.Errorf("response = %+v", response)
+	}
+
+	result, err = handleInvalidateCache(context.Background(), InvalidateCacheParams{Username: "bob/../x"}, bookmarkService, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError {
+		t.Error("IsError = false for an invalid username")
+	}
+}
+
+// rawFeed is an RSS 2.0 feed for raw_xml whose two items have unparseable dates
+const rawFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>t</title><link>https://example.com/</link>
+<item><title>A</title><link>https://example.com/a</link><pubDate>someday</pubDate></item>
+<item><title>B</title><link>https://example.com/b</link><pubDate>tomorrow</pubDate></item>
+</channel></rss>`
+
+func TestHandleGetBookmarksWarningsAsContent(t *testing.T) {
+	bookmarkService := service.NewBookmarkService(testLogger())
+	defer bookmarkService.Close()
+
+	for _, enabled := range []bool{false, true} {
+		arguments := GetHatenaBookmarksParams{RawXML: rawFeed, WarningsAsContent: enabled}
+		result, err := handleGetBookmarks(context.Background(), arguments, bookmarkService, format.NewRegistry(), bookmarkDefaults{}, testLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsError {
+			t.Fatalf("IsError = true: %v", result.Content)
+		}
+
+		if !enabled {
+			if len(result.Content) != 1 {
+				t.Errorf("got %d content blocks, want only the JSON block", len(result.Content))
+			}
+			continue
+		}
+		if len(result.Content) != 2 {
+			t.Fatalf("got %d content blocks, want the JSON and warnings blocks", len(result.Content))
+		}
+		if !json.Valid([]byte(result.Content[0].(*mcp.TextContent).Text)) {
+			t.Error("first block is not the JSON result")
+		}
+		lines := strings.Split(strings.TrimSuffix(result.Content[1].(*mcp.TextContent).Text, "\n"), "\n")
+		if len(lines) != 3 || lines[0] != "Warnings (2):" {
+			t.Fatalf("warnings block = %q, want a header and two warnings", lines)
+		}
+		for i, date := range []string{"someday", "tomorrow"} {
+			if line := lines[i+1]; !strings.HasPrefix(line, "- ") || !strings.Contains(line, date) {
+				t.Errorf("warning line = %q, want one for %s", line, date)
+			}
+		}
+	}
+}
+
+// toolNames lists the tools a server exposes to a connected client
+func toolNames(t *testing.T, server *mcp.Server) []string {
+	t.Helper()
+
+	ctx := context.Background()
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverSession.Close()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "v0.0.0"}, nil)
+	clientSession, err := client.Connect(ctx, clientTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientSession.Close()
+
+	result, err := clientSession.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func TestRegisterDebugTools(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"false", nil},
+		{"yes", nil},
+		{"true", []string{"debug_parse"}},
+		{"1", []string{"debug_parse"}},
+	}
+
+	bookmarkService := service.NewBookmarkService(testLogger())
+	defer bookmarkService.Close()
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("DEBUG_TOOLS=%q", tt.value), func(t *testing.T) {
+			t.Setenv("DEBUG_TOOLS", tt.value)
+			server := mcp.NewServer(&mcp.Implementation{Name: ServerName, Version: ServerVersion}, nil)
+
+			if got := registerDebugTools(server, bookmarkService, testLogger()); got != len(tt.want) {
+				t.Errorf("registerDebugTools() = %d, want %d", got, len(tt.want))
+			}
+			if got := toolNames(t, server); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tools = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBookmarkDefaults(t *testing.T) {
+	t.Setenv("HATENA_DEFAULT_USERNAME", " alice ")
+	t.Setenv("HATENA_DEFAULT_TIMEZONE", "Asia/Tokyo")
+	t.Setenv("HATENA_DEFAULT_FORMAT", "")
+
+	want := bookmarkDefaults{Username: "alice", Timezone: "Asia/Tokyo"}
+	if got := loadBookmarkDefaults(); got != want {
+		t.Errorf("loadBookmarkDefaults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestBookmarkDefaultsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults bookmarkDefaults
+		wantErr  string
+	}{
+		{"empty", bookmarkDefaults{}, ""},
+		{"valid", bookmarkDefaults{Username: "alice", Timezone: "Asia/Tokyo", Format: "rss"}, ""},
+		{"invalid username", bookmarkDefaults{Username: "a/b"}, "HATENA_DEFAULT_USERNAME"},
+		{"unknown timezone", bookmarkDefaults{Timezone: "Mars/Olympus"}, "HATENA_DEFAULT_TIMEZONE"},
+		{"unknown format", bookmarkDefaults{Format: "yaml"}, "HATENA_DEFAULT_FORMAT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.defaults.validate(format.NewRegistry())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr+":") {
+				t.Errorf("validate() error = %v, want one naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBookmarkDefaultsApply(t *testing.T) {
+	defaults := bookmarkDefaults{Username: "alice", Timezone: "Asia/Tokyo", Format: "rss"}
+
+	tests := []struct {
+		name      string
+		arguments GetHatenaBookmarksParams
+		want      GetHatenaBookmarksParams
+	}{
+		{"blank", GetHatenaBookmarksParams{Username: "  "}, GetHatenaBookmarksParams{Username: "alice", Timezone: "Asia/Tokyo", Format: "rss"}},
+		{"explicit", GetHatenaBookmarksParams{Username: "bob", Timezone: "UTC", Format: "json"}, GetHatenaBookmarksParams{Username: "bob", Timezone: "UTC", Format: "json"}},
+		{"raw XML", GetHatenaBookmarksParams{RawXML: rawFeed}, GetHatenaBookmarksParams{RawXML: rawFeed, Timezone: "Asia/Tokyo", Format: "rss"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arguments := tt.arguments
+			defaults.apply(&arguments)
+			if !reflect.DeepEqual(arguments, tt.want) {
+				t.Errorf("apply() = %+v, want %+v", arguments, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleGetBookmarksDefaultUsername(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		io.WriteString(w, rawFeed)
+	}))
+	defer server.Close()
+
+	bookmarkService := service.NewBookmarkServiceWithOptions(testLogger(), service.Options{
+		BaseURL:      server.URL,
+		AllowedHosts: []string{"127.0.0.1"},
+		CacheTTL:     -1,
+	})
+	defer bookmarkService.Close()
+
+	defaults := bookmarkDefaults{Username: "alice"}
+	for _, username := range []string{"", "bob"} {
+		result, err := handleGetBookmarks(context.Background(), GetHatenaBookmarksParams{Username: username}, bookmarkService, format.NewRegistry(), defaults, testLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsError {
+			t.Fatalf("IsError = true: %s", resultText(t, result))
+		}
+	}
+
+	want := []string{"/alice/rss", "/bob/rss"}
+	if !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested %q, want %q", requested, want)
+	}
+}
+
+// largeResponse returns a response with n bookmarks
+func largeResponse(n int) *types.GetHatenaBookmarksResponse {
+	response := &types.GetHatenaBookmarksResponse{User: "alice", Page: 1, TotalCount: n}
+	for i := 0; i < n; i++ {
+		response.Bookmarks = append(response.Bookmarks, types.BookmarkItem{
+			Title:        fmt.Sprintf("Article %d", i),
+			URL:          fmt.Sprintf("https://example.com/articles/%d", i),
+			BookmarkedAt: "2024-02-10T09:00:00+09:00",
+			Tags:         []string{"go", "xml"},
+		})
+	}
+	return response
+}
+
+func TestCreateSuccessResultMaxOutputBytes(t *testing.T) {
+	response := largeResponse(50)
+	indented, _, err := format.JSONFormatter{}.Render(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact := compactJSON(indented)
+
+	tests := []struct {
+		name           string
+		maxOutputBytes int
+		wantReducedTo  string
+	}{
+		{"no budget", 0, ""},
+		{"within budget", len(indented), ""},
+		{"compacting fits", len(compact), "compact JSON"},
+		{"compacting does not fit", len(compact) - 1, "counts only, without bookmarks"},
+		{"tiny budget", 10, "counts only, without bookmarks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := createSuccessResult(response, format.JSONFormatter{}, tt.maxOutputBytes)
+			if result.IsError {
+				t.Fatalf("IsError = true: %v", result.Content)
+			}
+
+			output := result.Content[0].(*mcp.TextContent).Text
+			if tt.wantReducedTo == "" {
+				if len(result.Content) != 1 || output != indented {
+					t.Errorf("output was reduced within the budget")
+				}
+				return
+			}
+
+			if len(result.Content) != 2 {
+				t.Fatalf("got %d content blocks, want the output and a note", len(result.Content))
+			}
+			note := result.Content[1].(*mcp.TextContent).Text
+			if want := fmt.Sprintf("max_output_bytes (%d) and was reduced to %s.", tt.maxOutputBytes, tt.wantReducedTo); !strings.HasSuffix(note, want) {
+				t.Errorf("note = %q, want it to end with %q", note, want)
+			}
+
+			var decoded types.GetHatenaBookmarksResponse
+			if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+				t.Fatalf("reduced output is not JSON: %v", err)
+			}
+			if decoded.TotalCount != 50 {
+				t.Errorf("total_count = %d, want it kept", decoded.TotalCount)
+			}
+			wantBookmarks := 50
+			if tt.wantReducedTo != "compact JSON" {
+				wantBookmarks = 0
+			}
+			if len(decoded.Bookmarks) != wantBookmarks || strings.Contains(output, "\n") {
+				t.Errorf("reduced output has %d bookmarks, want %d, compacted", len(decoded.Bookmarks), wantBookmarks)
+			}
+		})
+	}
+}
+
+func TestCreateSuccessResultMaxOutputBytesGrouped(t *testing.T) {
+	tests := []struct {
+		name  string
+		group func(response *types.GetHatenaBookmarksResponse)
+	}{
+		{"group_by_date", func(response *types.GetHatenaBookmarksResponse) {
+			response.DateGroups = []types.DateGroup{{Date: "2024-02-10", Bookmarks: response.Bookmarks}}
+			response.Bookmarks = []types.BookmarkItem{}
+		}},
+		{"tag_tree", func(response *types.GetHatenaBookmarksResponse) {
+			response.TagTree = map[string]*types.TagNode{"go": {Bookmarks: response.Bookmarks}}
+			response.Bookmarks = []types.BookmarkItem{}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := largeResponse(50)
+			tt.group(response)
+
+			result := createSuccessResult(response, format.JSONFormatter{}, 1000)
+
+			if len(result.Content) != 2 || !strings.HasSuffix(result.Content[1].(*mcp.TextContent).Text, "reduced to counts only, without bookmarks.") {
+				t.Fatalf("content = %v, want the reduced output and a note", result.Content)
+			}
+			output := result.Content[0].(*mcp.TextContent).Text
+			if len(output) > 1000 || strings.Contains(output, "https://example.com/articles/") {
+				t.Errorf("reduced output still has bookmarks: %s", output)
+			}
+		})
+	}
+}
+
+func TestCreateSuccessResultMaxOutputBytesNonJSON(t *testing.T) {
+	// Only JSON can be compacted, so RSS goes straight to counts only
+	result := createSuccessResult(largeResponse(50), format.RSSFormatter{}, 1000)
+
+	if len(result.Content) != 2 || !strings.HasSuffix(result.Content[1].(*mcp.TextContent).Text, "reduced to counts only, without bookmarks.") {
+		t.Fatalf("content = %v, want the reduced feed and a note", result.Content)
+	}
+	if output := result.Content[0].(*mcp.TextContent).Text; strings.Contains(output, "<item>") {
+		t.Errorf("reduced feed still has items")
+	}
+}
+
+func TestHandleGetBookmarksNegativeMaxOutputBytes(t *testing.T) {
+	bookmarkService := service.NewBookmarkService(testLogger())
+	defer bookmarkService.Close()
+
+	arguments := GetHatenaBookmarksParams{RawXML: rawFeed, MaxOutputBytes: -1}
+	result, err := handleGetBookmarks(context.Background(), arguments, bookmarkService, format.NewRegistry(), bookmarkDefaults{}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || resultText(t, result) != "max_output_bytes must not be negative" {
+		t.Errorf("result = %v, want a validation error", result.Content)
+	}
+}
+
+func TestEnvDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"unset", "", 0, false},
+		{"valid", "5m", 5 * time.Minute, false},
+		{"surrounding spaces", " 30s ", 30 * time.Second, false},
+		{"negative", "-1s", -time.Second, false},
+		{"missing unit", "300", 0, true},
+		{"not a duration", "soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HATENA_TEST_DURATION", tt.value)
+
+			got, err := envDuration("HATENA_TEST_DURATION")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "HATENA_TEST_DURATION") {
+				t.Errorf("error %q does not name the variable", err)
+			}
+			if got != tt.want {
+				t.Errorf("envDuration() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"unset", "", 0, false},
+		{"valid", "42", 42, false},
+		{"negative", "-1", -1, false},
+		{"not a number", "many", 0, true},
+		{"fraction", "1.5", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HATENA_TEST_INT", tt.value)
+
+			got, err := envInt("HATENA_TEST_INT")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envInt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "HATENA_TEST_INT") {
+				t.Errorf("error %q does not name the variable", err)
+			}
+			if got != tt.want {
+				t.Errorf("envInt() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadServiceOptionsReportsEveryInvalidVariable(t *testing.T) {
+	t.Setenv("HATENA_CACHE_TTL", "soon")
+	t.Setenv("HATENA_MAX_TAGS_PER_ITEM", "many")
+	t.Setenv("HATENA_STREAM_THRESHOLD", "10")
+
+	_, err := loadServiceOptions()
+	if err == nil {
+		t.Fatal("loadServiceOptions() error = nil, want an error")
+	}
+	for _, name := range []string{"HATENA_CACHE_TTL", "HATENA_MAX_TAGS_PER_ITEM"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+	if strings.Contains(err.Error(), "HATENA_STREAM_THRESHOLD") {
+		t.Errorf("error %q mentions a valid variable", err)
+	}
+}
+
+func TestLoadServiceOptions(t *testing.T) {
+	t.Setenv("HATENA_CACHE_TTL", "10m")
+	t.Setenv("HATENA_MAX_TITLE_LENGTH", "80")
+	t.Setenv("HATENA_DATE_PREFERENCE", " PubDate_First ")
+	t.Setenv("HATENA_ALLOWED_HOSTS", "mirror.example, b.hatena.ne.jp")
+
+	options, err := loadServiceOptions()
+	if err != nil {
+		t.Fatalf("loadServiceOptions() error = %v", err)
+	}
+	if !reflect.DeepEqual(options.AllowedHosts, []string{"mirror.example", "b.hatena.ne.jp"}) {
+		t.Errorf("AllowedHosts = %q", options.AllowedHosts)
+	}
+	if options.CacheTTL != 10*time.Minute || options.MaxTitleLength != 80 || options.DatePreference != "pubdate_first" {
+		t.Errorf("options = %s, %d, %q", options.CacheTTL, options.MaxTitleLength, options.DatePreference)
+	}
+}
+
+func TestRequestScope(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		meta mcp.Meta
+		want string // empty for a generated ID
+	}{
+		{"from the transport", utils.WithRequestID(context.Background(), "gateway-1"), mcp.Meta{"request_id": "client-1"}, "gateway-1"},
+		{"from _meta", context.Background(), mcp.Meta{"request_id": "client-1"}, "client-1"},
+		{"none", context.Background(), nil, ""},
+		{"not a string", context.Background(), mcp.Meta{"request_id": 42}, ""},
+		{"with spaces", context.Background(), mcp.Meta{"request_id": "client 1"}, ""},
+		{"with a newline", context.Background(), mcp.Meta{"request_id": "client-1\nforged=1"}, ""},
+		{"too long", context.Background(), mcp.Meta{"request_id": strings.Repeat("a", 129)}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs strings.Builder
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			ctx, scoped := requestScope(tt.ctx, tt.meta, logger)
+
+			requestID := utils.RequestIDFromContext(ctx)
+			if tt.want != "" && requestID != tt.want {
+				t.Errorf("request ID = %q, want %q", requestID, tt.want)
+			}
+			if tt.want == "" && (len(requestID) != 8 || !utils.IsValidRequestID(requestID)) {
+				t.Errorf("request ID = %q, want a generated one", requestID)
+			}
+
+			scoped.Info("handled")
+			if !strings.Contains(logs.String(), "request_id="+requestID) {
+				t.Errorf("log %q is not tagged with the request ID", logs.String())
+			}
+		})
+	}
+}
+
+func TestHandleGetBookmarksForwardsRequestID(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(utils.RequestIDHeader)
+		io.WriteString(w, rawFeed)
+	}))
+	defer server.Close()
+
+	bookmarkService := service.NewBookmarkServiceWithOptions(testLogger(), service.Options{BaseURL: server.URL, AllowedHosts: []string{"127.0.0.1"}})
+	defer bookmarkService.Close()
+
+	ctx, logger := requestScope(context.Background(), mcp.Meta{"request_id": "client-1"}, testLogger())
+	result, err := handleGetBookmarks(ctx, GetHatenaBookmarksParams{Username: "alice"}, bookmarkService, format.NewRegistry(), bookmarkDefaults{}, logger)
+	if err != nil {
+		t.Fatalf("handleGetBookmarks() error = %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("handleGetBookmarks() = %s", resultText(t, result))
+	}
+	if header != "client-1" {
+		t.Errorf("%s header = %q, want the _meta request ID", utils.RequestIDHeader, header)
+	}
+}
@@ -0,0 +1,95 @@
+package analysis
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// TopDomains counts bookmarks per host and returns the number of distinct
+// hosts along with the topN most frequent ones (ties broken by host name).
+// Bookmarks whose URL has no host are skipped.
+func TopDomains(items []types.BookmarkItem, topN int) (int, []types.DomainCount) {
+	return topKeys(items, topN, Host)
+}
+
+// TopPaths is like TopDomains but groups by host and path, ignoring the query
+// string and fragment, so that bookmarks differing only by query count
+// together. The bookmarks themselves are not modified.
+func TopPaths(items []types.BookmarkItem, topN int) (int, []types.DomainCount) {
+	return topKeys(items, topN, PathKey)
+}
+
+// PathKey returns the lowercased host followed by the path of a bookmark URL,
+// without query or fragment, or an empty string if the URL has no host
+func PathKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	return strings.ToLower(u.Hostname()) + u.EscapedPath()
+}
+
+// topKeys counts bookmarks per grouping key and returns the number of
+// distinct keys along with the topN most frequent ones
+func topKeys(items []types.BookmarkItem, topN int, key func(string) string) (int, []types.DomainCount) {
+	counts := make(map[string]int)
+	for _, item := range items {
+		if k := key(item.URL); k != "" {
+			counts[k]++
+		}
+	}
+
+	top := make([]types.DomainCount, 0, len(counts))
+	for domain, count := range counts {
+		top = append(top, types.DomainCount{Domain: domain, Count: count})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Domain < top[j].Domain
+	})
+
+	if topN > 0 && len(top) > topN {
+		top = top[:topN]
+	}
+
+	return len(counts), top
+}
+
+// FaviconURLFormat builds a favicon URL for a host, given the query-escaped host
+const FaviconURLFormat = "https://www.google.com/s2/favicons?domain=%s"
+
+// RecentDomains returns up to limit distinct hosts in the order they first
+// appear in items, which are expected newest first, each with the first
+// bookmark seen for it and a favicon URL. Bookmarks whose URL has no host
+// are skipped; a limit of 0 returns every host.
+func RecentDomains(items []types.BookmarkItem, limit int) []types.RecentDomain {
+	seen := make(map[string]bool)
+	domains := make([]types.RecentDomain, 0)
+
+	for _, item := range items {
+		if limit > 0 && len(domains) >= limit {
+			break
+		}
+
+		host := Host(item.URL)
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		domains = append(domains, types.RecentDomain{
+			Domain:     host,
+			FaviconURL: fmt.Sprintf(FaviconURLFormat, url.QueryEscape(host)),
+			Bookmark:   item,
+		})
+	}
+
+	return domains
+}
@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// domainFixture has repeated domains, differing in case and port
+var domainFixture = []types.BookmarkItem{
+	{URL: "https://go.dev/blog/go1.22"},
+	{URL: "https://Go.dev/doc"},
+	{URL: "https://go.dev:443/play"},
+	{URL: "https://example.com/a?x=1"},
+	{URL: "https://example.com/a?x=2"},
+	{URL: "https://news.example.org/"},
+	{URL: "not a url"},
+}
+
+func TestTopDomains(t *testing.T) {
+	distinct, top := TopDomains(domainFixture, 2)
+
+	if distinct != 3 {
+		t.Errorf("distinct = %d, want 3", distinct)
+	}
+	want := []types.DomainCount{{Domain: "go.dev", Count: 3}, {Domain: "example.com", Count: 2}}
+	if !reflect.DeepEqual(top, want) {
+		t.Errorf("top = %v, want %v", top, want)
+	}
+}
+
+func TestTopDomainsEmpty(t *testing.T) {
+	distinct, top := TopDomains(nil, 5)
+	if distinct != 0 || top == nil || len(top) != 0 {
+		t.Errorf("TopDomains(nil) = %d, %v, want 0 and an empty list", distinct, top)
+	}
+}
+
+func TestPathKey(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/a?x=1", "example.com/a"},
+		{"https://Example.COM/A#top", "example.com/A"},
+		{"https://example.com:8443/a", "example.com/a"},
+		{"https://example.com", "example.com"},
+		{"https://example.com/a%20b", "example.com/a%20b"},
+		{"/relative/path", ""},
+		{"not a url", ""},
+	}
+
+	for _, tt := range tests {
+		if got := PathKey(tt.url); got != tt.want {
+			t.Errorf("PathKey(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestTopPaths(t *testing.T) {
+	items := append([]types.BookmarkItem(nil), domainFixture...)
+
+	distinct, top := TopPaths(items, 1)
+
+	if distinct != 5 {
+		t.Errorf("distinct = %d, want 5", distinct)
+	}
+	want := []types.DomainCount{{Domain: "example.com/a", Count: 2}}
+	if !reflect.DeepEqual(top, want) {
+		t.Errorf("top = %v, want %v", top, want)
+	}
+	if !reflect.DeepEqual(items, domainFixture) {
+		t.Error("TopPaths modified the bookmarks")
+	}
+}
+
+func TestRecentDomains(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "https://go.dev/blog", Title: "newest"},
+		{URL: "https://example.com/a", Title: "second"},
+		{URL: "https://Go.dev/doc", Title: "older go.dev"},
+		{URL: "not a url"},
+		{URL: "https://news.example.org/", Title: "third"},
+	}
+
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{0, []string{"go.dev", "example.com", "news.example.org"}},
+		{2, []string{"go.dev", "example.com"}},
+		{10, []string{"go.dev", "example.com", "news.example.org"}},
+	}
+
+	for _, tt := range tests {
+		domains := RecentDomains(items, tt.limit)
+
+		var got []string
+		for _, domain := range domains {
+			got = append(got, domain.Domain)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RecentDomains(limit %d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+
+	domains := RecentDomains(items, 1)
+	if domains[0].Bookmark.Title != "newest" {
+		t.Errorf("bookmark = %q, want the first one seen for the host", domains[0].Bookmark.Title)
+	}
+	if want := "https://www.google.com/s2/favicons?domain=go.dev"; domains[0].FaviconURL != want {
+		t.Errorf("FaviconURL = %q, want %q", domains[0].FaviconURL, want)
+	}
+}
+
+func TestRecentDomainsEmpty(t *testing.T) {
+	if domains := RecentDomains(nil, 5); domains == nil || len(domains) != 0 {
+		t.Errorf("RecentDomains(nil) = %v, want an empty list", domains)
+	}
+}
@@ -0,0 +1,127 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// stopwords lists common words excluded from comment keywords
+var stopwords = map[string]bool{
+	"about": true, "an": true, "and": true, "are": true, "as": true,
+	"at": true, "be": true, "but": true, "by": true, "can": true,
+	"for": true, "from": true, "has": true, "have": true, "if": true,
+	"in": true, "is": true, "it": true, "its": true, "my": true,
+	"not": true, "of": true, "on": true, "or": true, "so": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true,
+	"we": true, "with": true, "you": true,
+	"記事": true, "自分": true, "感じ": true,
+}
+
+// CommentKeywords counts word frequencies across the non-empty comments of
+// bookmarks and returns the topN most frequent words (ties broken by word).
+// Words shorter than minLength runes and stopwords are skipped.
+func CommentKeywords(items []types.BookmarkItem, topN, minLength int) []types.WordCount {
+	counts := make(map[string]int)
+
+	for _, item := range items {
+		for _, word := range tokenize(item.Comment) {
+			if len([]rune(word)) < minLength || stopwords[word] {
+				continue
+			}
+			counts[word]++
+		}
+	}
+
+	result := make([]types.WordCount, 0, len(counts))
+	for word, count := range counts {
+		result = append(result, types.WordCount{Word: word, Count: count})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Word < result[j].Word
+	})
+
+	if topN > 0 && len(result) > topN {
+		result = result[:topN]
+	}
+
+	return result
+}
+
+// tokenize splits text into lowercase words. Japanese has no word spacing,
+// so runs are split by script instead: hiragana (mostly particles and
+// inflections) separates words, katakana runs are kept whole, and kanji runs
+// longer than two characters are split into bigrams. Numbers are dropped.
+func tokenize(text string) []string {
+	var tokens []string
+	var run []rune
+	runScript := scriptOther
+
+	flush := func() {
+		switch {
+		case len(run) == 0:
+		case runScript == scriptHan && len(run) > 2:
+			for i := 0; i+1 < len(run); i++ {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+		case runScript == scriptWord && isNumber(run):
+		default:
+			tokens = append(tokens, string(run))
+		}
+		run = run[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		script := scriptOf(r)
+		if script != runScript {
+			flush()
+			runScript = script
+		}
+		if script != scriptOther {
+			run = append(run, r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// Character classes used by tokenize
+const (
+	scriptOther = iota // separators, punctuation and hiragana
+	scriptWord         // letters and digits of space-separated scripts
+	scriptHan
+	scriptKatakana
+)
+
+// scriptOf classifies a rune for tokenization
+func scriptOf(r rune) int {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return scriptHan
+	case unicode.Is(unicode.Katakana, r) || r == 'ー':
+		return scriptKatakana
+	case unicode.Is(unicode.Hiragana, r):
+		return scriptOther
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		return scriptWord
+	default:
+		return scriptOther
+	}
+}
+
+// isNumber reports whether the word consists only of digits
+func isNumber(word []rune) bool {
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Go is great, and Go's generics are GREAT!", []string{"go", "is", "great", "and", "go", "s", "generics", "are", "great"}},
+		{"Released in 2024", []string{"released", "in"}},
+		{"ジェネリクスが便利", []string{"ジェネリクス", "便利"}},
+		{"非同期処理の解説記事。", []string{"非同", "同期", "期処", "処理", "解説", "説記", "記事"}},
+		{"Goのエラー処理", []string{"go", "エラー", "処理"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentKeywords(t *testing.T) {
+	items := []types.BookmarkItem{
+		{Comment: "Go generics are great"},
+		{Comment: "Generics in Go, finally"},
+		{Comment: "ジェネリクスの解説。Goのジェネリクスが便利"},
+		{Comment: "便利な記事"},
+		{Comment: ""},
+	}
+
+	tests := []struct {
+		name      string
+		topN      int
+		minLength int
+		want      []types.WordCount
+	}{
+		{"top words", 3, 2, []types.WordCount{{Word: "go", Count: 3}, {Word: "generics", Count: 2}, {Word: "ジェネリクス", Count: 2}}},
+		{"stopwords and short words skipped", 0, 3, []types.WordCount{
+			{Word: "generics", Count: 2}, {Word: "ジェネリクス", Count: 2},
+			{Word: "finally", Count: 1}, {Word: "great", Count: 1},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CommentKeywords(items, tt.topN, tt.minLength); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CommentKeywords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentKeywordsWithoutComments(t *testing.T) {
+	got := CommentKeywords([]types.BookmarkItem{{Title: "A"}, {Comment: "  "}}, 10, 1)
+	if got == nil || len(got) != 0 {
+		t.Errorf("CommentKeywords() = %v, want an empty list", got)
+	}
+}
@@ -0,0 +1,46 @@
+package analysis
+
+import (
+	"sort"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// URLOverlap compares the URLs bookmarked in a and b. It returns the URLs
+// present in both, sorted, and the number of distinct URLs only in a and
+// only in b. Bookmarks are deduplicated by URL within each side.
+func URLOverlap(a, b []types.BookmarkItem) (shared []string, onlyA, onlyB int) {
+	urlsA := urlSet(a)
+	urlsB := urlSet(b)
+
+	shared = make([]string, 0)
+	for u := range urlsA {
+		if urlsB[u] {
+			shared = append(shared, u)
+		}
+	}
+	sort.Strings(shared)
+
+	return shared, len(urlsA) - len(shared), len(urlsB) - len(shared)
+}
+
+// Jaccard returns the Jaccard similarity |A ∩ B| / |A ∪ B| given the size of
+// the intersection and of each difference. Two empty sets have similarity 0.
+func Jaccard(shared, onlyA, onlyB int) float64 {
+	union := shared + onlyA + onlyB
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// urlSet returns the distinct non-empty URLs of the bookmarks
+func urlSet(items []types.BookmarkItem) map[string]bool {
+	urls := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.URL != "" {
+			urls[item.URL] = true
+		}
+	}
+	return urls
+}
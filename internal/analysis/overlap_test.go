@@ -0,0 +1,74 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// bookmarksAt returns one bookmark per URL
+func bookmarksAt(urls ...string) []types.BookmarkItem {
+	items := make([]types.BookmarkItem, len(urls))
+	for i, u := range urls {
+		items[i] = types.BookmarkItem{URL: u}
+	}
+	return items
+}
+
+func TestURLOverlap(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       []types.BookmarkItem
+		wantShared []string
+		wantOnlyA  int
+		wantOnlyB  int
+	}{
+		{
+			"partial overlap",
+			bookmarksAt("https://c.example/", "https://a.example/", "https://b.example/", "https://a.example/"),
+			bookmarksAt("https://b.example/", "https://d.example/", "https://c.example/"),
+			[]string{"https://b.example/", "https://c.example/"}, 1, 1,
+		},
+		{
+			"one side empty",
+			bookmarksAt("https://a.example/", "https://b.example/"),
+			nil,
+			[]string{}, 2, 0,
+		},
+		{
+			"empty URLs ignored",
+			bookmarksAt("", "https://a.example/"),
+			bookmarksAt("", "https://a.example/"),
+			[]string{"https://a.example/"}, 0, 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shared, onlyA, onlyB := URLOverlap(tt.a, tt.b)
+			if !reflect.DeepEqual(shared, tt.wantShared) || onlyA != tt.wantOnlyA || onlyB != tt.wantOnlyB {
+				t.Errorf("URLOverlap() = %v, %d, %d, want %v, %d, %d", shared, onlyA, onlyB, tt.wantShared, tt.wantOnlyA, tt.wantOnlyB)
+			}
+		})
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		shared, onlyA, onlyB int
+		want                 float64
+	}{
+		{2, 1, 1, 0.5},
+		{3, 0, 0, 1},
+		{0, 2, 3, 0},
+		{0, 0, 0, 0},
+		{1, 2, 0, 1.0 / 3},
+	}
+
+	for _, tt := range tests {
+		if got := Jaccard(tt.shared, tt.onlyA, tt.onlyB); got != tt.want {
+			t.Errorf("Jaccard(%d, %d, %d) = %v, want %v", tt.shared, tt.onlyA, tt.onlyB, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// ComputeStats computes aggregate statistics over bookmarks.
+// An empty input yields zero values.
+func ComputeStats(items []types.BookmarkItem) *types.BookmarkStats {
+	stats := &types.BookmarkStats{TotalBookmarks: len(items)}
+	if len(items) == 0 {
+		return stats
+	}
+
+	tags := make(map[string]bool)
+	domains := make(map[string]bool)
+	tagTotal := 0
+	commented := 0
+	var oldest, newest time.Time
+
+	for _, item := range items {
+		for _, tag := range item.Tags {
+			tags[tag] = true
+		}
+		tagTotal += len(item.Tags)
+
+		if host := Host(item.URL); host != "" {
+			domains[host] = true
+		}
+
+		if item.Comment != "" {
+			commented++
+		}
+
+		t, err := time.Parse(time.RFC3339, item.BookmarkedAt)
+		if err != nil {
+			continue
+		}
+		if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+		if newest.IsZero() || t.After(newest) {
+			newest = t
+		}
+	}
+
+	stats.DistinctTags = len(tags)
+	stats.DistinctDomains = len(domains)
+	stats.AverageTagsPerBookmark = float64(tagTotal) / float64(len(items))
+	stats.CommentPercentage = float64(commented) * 100 / float64(len(items))
+
+	if !oldest.IsZero() {
+		stats.OldestBookmarkedAt = oldest.Format(time.RFC3339)
+		stats.NewestBookmarkedAt = newest.Format(time.RFC3339)
+	}
+
+	return stats
+}
+
+// MonthlyActivity counts bookmarks per month (YYYY-MM, in each timestamp's
+// own offset), oldest month first. Months without bookmarks are omitted and
+// bookmarks with unparseable timestamps are skipped.
+func MonthlyActivity(items []types.BookmarkItem) []types.MonthCount {
+	counts := make(map[string]int)
+	for _, item := range items {
+		t, err := time.Parse(time.RFC3339, item.BookmarkedAt)
+		if err != nil {
+			continue
+		}
+		counts[t.Format("2006-01")]++
+	}
+
+	activity := make([]types.MonthCount, 0, len(counts))
+	for month, count := range counts {
+		activity = append(activity, types.MonthCount{Month: month, Count: count})
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		return activity[i].Month < activity[j].Month
+	})
+
+	return activity
+}
+
+// Host returns the lowercased host name of a bookmark URL, or an empty
+// string if the URL cannot be parsed
+func Host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestComputeStats(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "https://Example.com/a", Tags: []string{"go", "rss"}, Comment: "good", BookmarkedAt: "2024-02-10T09:00:00+09:00"},
+		{URL: "https://example.com/b", Tags: []string{"go"}, BookmarkedAt: "2024-01-05T12:00:00Z"},
+		{URL: "https://go.dev/", BookmarkedAt: "not a date"},
+		{URL: "https://go.dev/blog", Tags: []string{"release"}, Comment: "nice", BookmarkedAt: "2024-03-01T00:00:00+09:00"},
+	}
+
+	stats := ComputeStats(items)
+
+	if stats.TotalBookmarks != 4 || stats.DistinctTags != 3 || stats.DistinctDomains != 2 {
+		t.Errorf("totals = %d bookmarks, %d tags, %d domains, want 4, 3, 2", stats.TotalBookmarks, stats.DistinctTags, stats.DistinctDomains)
+	}
+	if math.Abs(stats.AverageTagsPerBookmark-1) > 1e-9 {
+		t.Errorf("AverageTagsPerBookmark = %v, want 1", stats.AverageTagsPerBookmark)
+	}
+	if math.Abs(stats.CommentPercentage-50) > 1e-9 {
+		t.Errorf("CommentPercentage = %v, want 50", stats.CommentPercentage)
+	}
+	if stats.OldestBookmarkedAt != "2024-01-05T12:00:00Z" || stats.NewestBookmarkedAt != "2024-03-01T00:00:00+09:00" {
+		t.Errorf("oldest, newest = %q, %q", stats.OldestBookmarkedAt, stats.NewestBookmarkedAt)
+	}
+}
+
+func TestComputeStatsEmpty(t *testing.T) {
+	stats := ComputeStats(nil)
+	if *stats != (types.BookmarkStats{}) {
+		t.Errorf("ComputeStats(nil) = %+v, want zero values", stats)
+	}
+}
+
+func TestMonthlyActivity(t *testing.T) {
+	items := []types.BookmarkItem{
+		{BookmarkedAt: "2024-02-10T09:00:00+09:00"},
+		{BookmarkedAt: "2023-12-31T23:30:00-05:00"}, // 2024-01 in UTC, but December in its own offset
+		{BookmarkedAt: "2024-02-01T00:00:00+09:00"},
+		{BookmarkedAt: "2024-03-05T10:00:00Z"},
+		{BookmarkedAt: "someday"},
+	}
+
+	want := []types.MonthCount{{Month: "2023-12", Count: 1}, {Month: "2024-02", Count: 2}, {Month: "2024-03", Count: 1}}
+	if got := MonthlyActivity(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("MonthlyActivity() = %v, want %v", got, want)
+	}
+	if got := MonthlyActivity(nil); got == nil || len(got) != 0 {
+		t.Errorf("MonthlyActivity(nil) = %v, want an empty list", got)
+	}
+}
@@ -0,0 +1,136 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// CoOccurringTags returns the tags that appear most often on bookmarks
+// tagged with seed, ranked by co-occurrence count (ties broken by tag name).
+// At most topN tags are returned; an unused seed tag yields an empty list.
+func CoOccurringTags(items []types.BookmarkItem, seed string, topN int) []types.TagCount {
+	counts := make(map[string]int)
+
+	for _, item := range items {
+		if !HasTag(item, seed) {
+			continue
+		}
+
+		seen := make(map[string]bool, len(item.Tags))
+		for _, tag := range item.Tags {
+			if tag == seed || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			counts[tag]++
+		}
+	}
+
+	return topTagCounts(counts, topN)
+}
+
+// TopTags returns the most used tags across bookmarks, ranked by the number
+// of bookmarks carrying them (ties broken by tag name). At most topN tags are
+// returned.
+func TopTags(items []types.BookmarkItem, topN int) []types.TagCount {
+	counts := make(map[string]int)
+
+	for _, item := range items {
+		seen := make(map[string]bool, len(item.Tags))
+		for _, tag := range item.Tags {
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			counts[tag]++
+		}
+	}
+
+	return topTagCounts(counts, topN)
+}
+
+// timestampLayouts are the layouts TagFirstSeen accepts, most common first.
+// The parser emits RFC 3339, but the others cover feeds whose dates were
+// passed through as written.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02",
+}
+
+// TagFirstSeen returns the earliest bookmark date of each tag as RFC 3339,
+// in that timestamp's own offset. Bookmarks dated with the parse time for
+// lack of a feed date, and timestamps in no known layout, are skipped, so a
+// tag only found on such bookmarks has no entry.
+func TagFirstSeen(items []types.BookmarkItem) map[string]string {
+	earliest := make(map[string]time.Time)
+
+	for _, item := range items {
+		if item.DateFallback {
+			continue
+		}
+		t, ok := parseTimestamp(item.BookmarkedAt)
+		if !ok {
+			continue
+		}
+
+		for _, tag := range item.Tags {
+			if seen, found := earliest[tag]; !found || t.Before(seen) {
+				earliest[tag] = t
+			}
+		}
+	}
+
+	firstSeen := make(map[string]string, len(earliest))
+	for tag, t := range earliest {
+		firstSeen[tag] = t.Format(time.RFC3339)
+	}
+	return firstSeen
+}
+
+// parseTimestamp parses a bookmark timestamp in any of timestampLayouts
+func parseTimestamp(value string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// topTagCounts sorts tag counts by count descending, then tag ascending,
+// and returns at most topN of them
+func topTagCounts(counts map[string]int, topN int) []types.TagCount {
+	result := make([]types.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		result = append(result, types.TagCount{Tag: tag, Count: count})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Tag < result[j].Tag
+	})
+
+	if topN > 0 && len(result) > topN {
+		result = result[:topN]
+	}
+
+	return result
+}
+
+// HasTag reports whether the bookmark carries tag
+func HasTag(item types.BookmarkItem, tag string) bool {
+	for _, t := range item.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
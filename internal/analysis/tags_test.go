@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// tagFixture has "go" strongly co-occurring with "programming"
+var tagFixture = []types.BookmarkItem{
+	{Tags: []string{"go", "programming", "release"}},
+	{Tags: []string{"go", "programming"}},
+	{Tags: []string{"programming", "go", "go"}},
+	{Tags: []string{"go", "xml"}},
+	{Tags: []string{"rust", "programming"}},
+	{Tags: nil},
+}
+
+func TestCoOccurringTags(t *testing.T) {
+	tests := []struct {
+		name string
+		seed string
+		topN int
+		want []types.TagCount
+	}{
+		{"ranked by count then tag", "go", 0, []types.TagCount{{Tag: "programming", Count: 3}, {Tag: "release", Count: 1}, {Tag: "xml", Count: 1}}},
+		{"capped at topN", "go", 1, []types.TagCount{{Tag: "programming", Count: 3}}},
+		{"other seed", "programming", 0, []types.TagCount{{Tag: "go", Count: 3}, {Tag: "release", Count: 1}, {Tag: "rust", Count: 1}}},
+		{"unused seed", "python", 10, []types.TagCount{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CoOccurringTags(tagFixture, tt.seed, tt.topN)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CoOccurringTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopTags(t *testing.T) {
+	want := []types.TagCount{{Tag: "go", Count: 4}, {Tag: "programming", Count: 4}, {Tag: "release", Count: 1}}
+	if got := TopTags(tagFixture, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("TopTags() = %v, want %v", got, want)
+	}
+}
+
+func TestTagFirstSeen(t *testing.T) {
+	items := []types.BookmarkItem{
+		{Tags: []string{"go"}, BookmarkedAt: "2024-02-10T09:00:00+09:00"},
+		// Earlier in absolute time despite the later wall clock
+		{Tags: []string{"go", "xml"}, BookmarkedAt: "2024-02-09T23:00:00-05:00"},
+		{Tags: []string{"go"}, BookmarkedAt: "2024-02-09 23:00:00"},
+		{Tags: []string{"xml"}, BookmarkedAt: "Mon, 05 Feb 2024 10:00:00 +0900"},
+		{Tags: []string{"rss"}, BookmarkedAt: "2020-01-01T00:00:00Z", DateFallback: true},
+		{Tags: []string{"rss"}, BookmarkedAt: "yesterday"},
+	}
+
+	want := map[string]string{
+		"go":  "2024-02-09T23:00:00Z",
+		"xml": "2024-02-05T10:00:00+09:00",
+	}
+	if got := TagFirstSeen(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("TagFirstSeen() = %v, want %v", got, want)
+	}
+}
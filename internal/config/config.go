@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"hatena-bookmark-mcp/internal/parser"
+	"hatena-bookmark-mcp/internal/service"
+)
+
+// Config is the optional configuration file. Every field may be omitted;
+// omitted fields fall back to environment variables and then to the service
+// defaults.
+type Config struct {
+	BaseURL      string   `json:"base_url"`
+	AllowedHosts []string `json:"allowed_hosts"` // Replaces the default allowlist
+	UserAgent    string   `json:"user_agent"`
+
+	MaxConcurrentRequests int `json:"max_concurrent_requests"`
+	MaxPages              int `json:"max_pages"`
+	MaxItems              int `json:"max_items"`
+
+	DialTimeout           Duration `json:"dial_timeout"`
+	TLSHandshakeTimeout   Duration `json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout Duration `json:"response_header_timeout"`
+	RequestTimeout        Duration `json:"request_timeout"`
+	PageTimeout           Duration `json:"page_timeout"`
+	FetchAllTimeout       Duration `json:"fetch_all_timeout"`
+
+	CacheTTL          Duration `json:"cache_ttl"`          // Negative disables caching
+	CacheNegativeTTL  Duration `json:"cache_negative_ttl"` // Negative disables caching empty results
+	CacheRefreshAhead Duration `json:"cache_refresh_ahead"`
+
+	MaxTagsPerItem    int    `json:"max_tags_per_item"`
+	MaxTitleLength    int    `json:"max_title_length"`
+	DatePreference    string `json:"date_preference"`
+	MinCommentLength  int    `json:"min_comment_length"`
+	MissingLinkPolicy string `json:"missing_link_policy"`
+	DeriveUntitled    bool   `json:"derive_untitled"`
+
+	StreamThreshold int `json:"stream_threshold"` // Bytes; negative disables streaming
+}
+
+// Duration is a time.Duration written in JSON as a Go duration string,
+// e.g. "5s" or "1m30s"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Load reads and validates a JSON configuration file. Unknown keys are
+// rejected so that typos are reported at startup.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("parse config %s: unexpected data after the top-level object", path)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// Validate checks that the configured values are usable
+func (c *Config) Validate() error {
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("base_url %q must be an absolute http or https URL", c.BaseURL)
+		}
+	}
+
+	for _, host := range c.AllowedHosts {
+		if host == "" || strings.ContainsAny(host, "/ ") {
+			return fmt.Errorf("allowed_hosts entry %q must be a host name without a scheme or path", host)
+		}
+	}
+
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"max_concurrent_requests", c.MaxConcurrentRequests},
+		{"max_pages", c.MaxPages},
+		{"max_items", c.MaxItems},
+		{"max_tags_per_item", c.MaxTagsPerItem},
+		{"max_title_length", c.MaxTitleLength},
+		{"min_comment_length", c.MinCommentLength},
+	}
+	for _, count := range counts {
+		if count.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", count.name, count.value)
+		}
+	}
+
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"dial_timeout", c.DialTimeout},
+		{"tls_handshake_timeout", c.TLSHandshakeTimeout},
+		{"response_header_timeout", c.ResponseHeaderTimeout},
+		{"request_timeout", c.RequestTimeout},
+		{"page_timeout", c.PageTimeout},
+		{"fetch_all_timeout", c.FetchAllTimeout},
+		{"cache_refresh_ahead", c.CacheRefreshAhead},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %s", timeout.name, time.Duration(timeout.value))
+		}
+	}
+
+	switch c.DatePreference {
+	case "", parser.DatePreferenceDCFirst, parser.DatePreferencePubDateFirst:
+	default:
+		return fmt.Errorf("date_preference must be %s or %s, got %q", parser.DatePreferenceDCFirst, parser.DatePreferencePubDateFirst, c.DatePreference)
+	}
+
+	switch c.MissingLinkPolicy {
+	case "", parser.MissingLinkSkip, parser.MissingLinkKeep, parser.MissingLinkError:
+	default:
+		return fmt.Errorf("missing_link_policy must be %s, %s or %s, got %q", parser.MissingLinkSkip, parser.MissingLinkKeep, parser.MissingLinkError, c.MissingLinkPolicy)
+	}
+
+	return nil
+}
+
+// Apply fills the options left unset, typically by environment variables,
+// with the configured values, so that the environment overrides the file
+func (c *Config) Apply(options service.Options) service.Options {
+	setString(&options.BaseURL, c.BaseURL)
+	if len(options.AllowedHosts) == 0 {
+		options.AllowedHosts = c.AllowedHosts
+	}
+	setString(&options.UserAgent, c.UserAgent)
+
+	setInt(&options.MaxConcurrentRequests, c.MaxConcurrentRequests)
+	setInt(&options.MaxPages, c.MaxPages)
+	setInt(&options.MaxItems, c.MaxItems)
+
+	setDuration(&options.DialTimeout, c.DialTimeout)
+	setDuration(&options.TLSHandshakeTimeout, c.TLSHandshakeTimeout)
+	setDuration(&options.ResponseHeaderTimeout, c.ResponseHeaderTimeout)
+	setDuration(&options.RequestTimeout, c.RequestTimeout)
+	setDuration(&options.PageTimeout, c.PageTimeout)
+	setDuration(&options.FetchAllTimeout, c.FetchAllTimeout)
+	setDuration(&options.CacheTTL, c.CacheTTL)
+	setDuration(&options.CacheNegativeTTL, c.CacheNegativeTTL)
+	setDuration(&options.CacheRefreshAhead, c.CacheRefreshAhead)
+
+	setInt(&options.MaxTagsPerItem, c.MaxTagsPerItem)
+	setInt(&options.MaxTitleLength, c.MaxTitleLength)
+	setString(&options.DatePreference, c.DatePreference)
+	setInt(&options.MinCommentLength, c.MinCommentLength)
+	setString(&options.MissingLinkPolicy, c.MissingLinkPolicy)
+	options.DeriveUntitled = options.DeriveUntitled || c.DeriveUntitled
+	setInt(&options.StreamThreshold, c.StreamThreshold)
+
+	return options
+}
+
+// setString sets *dst to value if *dst is empty
+func setString(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+// setInt sets *dst to value if *dst is zero
+func setInt(dst *int, value int) {
+	if *dst == 0 {
+		*dst = value
+	}
+}
+
+// setDuration sets *dst to value if *dst is zero
+func setDuration(dst *time.Duration, value Duration) {
+	if *dst == 0 {
+		*dst = time.Duration(value)
+	}
+}
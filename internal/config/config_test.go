@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/service"
+)
+
+// writeConfig writes content to a config file in a temporary directory
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSample(t *testing.T) {
+	config, err := Load(filepath.Join("testdata", "sample.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	options := config.Apply(service.Options{})
+
+	if options.BaseURL != "https://mirror.example" || options.UserAgent != "bookmarks-bot/1.0" {
+		t.Errorf("BaseURL = %q, UserAgent = %q", options.BaseURL, options.UserAgent)
+	}
+	if !reflect.DeepEqual(options.AllowedHosts, []string{"mirror.example"}) {
+		t.Errorf("AllowedHosts = %q, want the mirror", options.AllowedHosts)
+	}
+	if options.MaxConcurrentRequests != 2 || options.MaxPages != 20 || options.MaxItems != 500 || options.MaxTagsPerItem != 5 {
+		t.Errorf("limits = %d, %d, %d, %d", options.MaxConcurrentRequests, options.MaxPages, options.MaxItems, options.MaxTagsPerItem)
+	}
+	if options.DialTimeout != 2*time.Second || options.RequestTimeout != 90*time.Second || options.FetchAllTimeout != 3*time.Minute {
+		t.Errorf("timeouts = %s, %s, %s", options.DialTimeout, options.RequestTimeout, options.FetchAllTimeout)
+	}
+	if options.CacheTTL != 10*time.Minute || options.CacheNegativeTTL != -time.Second {
+		t.Errorf("cache TTLs = %s, %s", options.CacheTTL, options.CacheNegativeTTL)
+	}
+	if options.DatePreference != "pubdate_first" || options.MissingLinkPolicy != "keep" || !options.DeriveUntitled || options.StreamThreshold != -1 {
+		t.Errorf("parser options = %q, %q, %v, %d", options.DatePreference, options.MissingLinkPolicy, options.DeriveUntitled, options.StreamThreshold)
+	}
+	// Keys left out of the file keep their zero value for the defaults
+	if options.TLSHandshakeTimeout != 0 || options.MaxTitleLength != 0 {
+		t.Errorf("unset keys = %s, %d, want zero", options.TLSHandshakeTimeout, options.MaxTitleLength)
+	}
+	if err := options.Validate(); err != nil {
+		t.Errorf("applied options are invalid: %v", err)
+	}
+}
+
+func TestApplyEnvironmentOverridesFile(t *testing.T) {
+	config, err := Load(writeConfig(t, `{"base_url": "https://file.example", "allowed_hosts": ["file.example"], "cache_ttl": "10m", "max_pages": 20}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Options set from the environment win over the file
+	options := config.Apply(service.Options{BaseURL: "https://env.example", AllowedHosts: []string{"env.example"}, CacheTTL: time.Minute})
+
+	if !reflect.DeepEqual(options.AllowedHosts, []string{"env.example"}) {
+		t.Errorf("AllowedHosts = %q, want the environment's list", options.AllowedHosts)
+	}
+	if options.BaseURL != "https://env.example" || options.CacheTTL != time.Minute || options.MaxPages != 20 {
+		t.Errorf("options = %q, %s, %d, want the environment values and max_pages from the file", options.BaseURL, options.CacheTTL, options.MaxPages)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown key", `{"cache_tll": "5m"}`, `unknown field "cache_tll"`},
+		{"duration as number", `{"cache_ttl": 300}`, "duration must be a string"},
+		{"invalid duration", `{"request_timeout": "soon"}`, `invalid duration "soon"`},
+		{"negative count", `{"max_pages": -1}`, "max_pages must not be negative"},
+		{"negative timeout", `{"dial_timeout": "-1s"}`, "dial_timeout must not be negative"},
+		{"relative base URL", `{"base_url": "b.hatena.ne.jp"}`, "must be an absolute http or https URL"},
+		{"allowed host with scheme", `{"allowed_hosts": ["https://mirror.example"]}`, "allowed_hosts entry"},
+		{"empty allowed host", `{"allowed_hosts": [""]}`, "allowed_hosts entry"},
+		{"unknown date preference", `{"date_preference": "newest"}`, "date_preference must be"},
+		{"unknown missing link policy", `{"missing_link_policy": "drop"}`, "missing_link_policy must be"},
+		{"trailing data", `{} {}`, "unexpected data after the top-level object"},
+		{"not an object", `[]`, "parse config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.content)
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), path) {
+				t.Errorf("Load() error = %v, want one naming the file and containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "read config") {
+		t.Errorf("Load() error = %v, want a read error", err)
+	}
+}
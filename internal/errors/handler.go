@@ -22,26 +22,26 @@ func NewErrorHandler(logger *slog.Logger) *ErrorHandler {
 func (h *ErrorHandler) HandleNetworkError(err error) *types.MCPError {
 	h.logger.Error("Network error occurred", "error", err)
 	
-	return &types.MCPError{
+	return (&types.MCPError{
 		Code:    types.ErrorCodeNetwork,
 		Message: "Network request failed",
 		Details: map[string]interface{}{
 			"original_error": err.Error(),
 		},
-	}
+	}).WithCause(err)
 }
 
 // HandleParsingError processes RSS parsing errors
 func (h *ErrorHandler) HandleParsingError(err error) *types.MCPError {
 	h.logger.Error("RSS parsing error occurred", "error", err)
 	
-	return &types.MCPError{
+	return (&types.MCPError{
 		Code:    types.ErrorCodeParsing,
 		Message: "Failed to parse RSS feed",
 		Details: map[string]interface{}{
 			"original_error": err.Error(),
 		},
-	}
+	}).WithCause(err)
 }
 
 // HandleValidationError processes parameter validation errors
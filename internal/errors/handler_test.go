@@ -0,0 +1,39 @@
+package errors
+
+import (
+	stderrors "errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestHandlerKeepsCause(t *testing.T) {
+	h := NewErrorHandler(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cause := io.ErrUnexpectedEOF
+
+	tests := []struct {
+		name string
+		err  *types.MCPError
+		code error
+	}{
+		{"network", h.HandleNetworkError(cause), types.ErrNetwork},
+		{"parsing", h.HandleParsingError(cause), types.ErrParsing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !stderrors.Is(tt.err, cause) {
+				t.Error("errors.Is(err, cause) = false, want the cause to be kept")
+			}
+			if !stderrors.Is(tt.err, tt.code) {
+				t.Errorf("errors.Is(err, %v) = false", tt.code)
+			}
+			details := tt.err.Details.(map[string]interface{})
+			if details["original_error"] != cause.Error() {
+				t.Errorf("original_error = %v", details["original_error"])
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// DefaultFormat is the format used when none is requested
+const DefaultFormat = "json"
+
+// Formatter renders a bookmark response into a textual output format
+type Formatter interface {
+	// Render returns the rendered output and its MIME type
+	Render(response *types.GetHatenaBookmarksResponse) (output string, mimeType string, err error)
+}
+
+// Registry maps format names to formatters
+type Registry struct {
+	mu         sync.RWMutex
+	formatters map[string]Formatter
+}
+
+// NewRegistry creates a new registry with the built-in formatters registered
+func NewRegistry() *Registry {
+	r := &Registry{
+		formatters: make(map[string]Formatter),
+	}
+	r.Register(DefaultFormat, JSONFormatter{})
+	r.Register("rss", RSSFormatter{})
+	return r
+}
+
+// Register adds or replaces the formatter for a format name
+func (r *Registry) Register(name string, formatter Formatter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.formatters[strings.ToLower(name)] = formatter
+}
+
+// Get returns the formatter for a format name.
+// An empty name selects DefaultFormat; unknown names return a validation error.
+func (r *Registry) Get(name string) (Formatter, error) {
+	if name == "" {
+		name = DefaultFormat
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	formatter, ok := r.formatters[strings.ToLower(name)]
+	if !ok {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("Unsupported format %q (supported: %s)", name, strings.Join(r.namesLocked(), ", ")),
+			Details: map[string]interface{}{"format": name},
+		}
+	}
+
+	return formatter, nil
+}
+
+// Names returns the registered format names in sorted order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.namesLocked()
+}
+
+// namesLocked returns the sorted format names; the caller must hold r.mu
+func (r *Registry) namesLocked() []string {
+	names := make([]string, 0, len(r.formatters))
+	for name := range r.formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JSONFormatter renders responses as indented JSON
+type JSONFormatter struct{}
+
+// Render renders the response as indented JSON
+func (JSONFormatter) Render(response *types.GetHatenaBookmarksResponse) (string, string, error) {
+	output, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	return string(output), "application/json", nil
+}
@@ -0,0 +1,61 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// countFormatter renders only the bookmark count, as plain text
+type countFormatter struct{}
+
+func (countFormatter) Render(response *types.GetHatenaBookmarksResponse) (string, string, error) {
+	return fmt.Sprintf("%s has %d bookmarks", response.User, len(response.Bookmarks)), "text/plain", nil
+}
+
+func TestRegistryCustomFormatter(t *testing.T) {
+	r := NewRegistry()
+	r.Register("Count", countFormatter{})
+
+	formatter, err := r.Get("COUNT")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	output, mimeType, err := formatter.Render(&types.GetHatenaBookmarksResponse{
+		User:      "alice",
+		Bookmarks: []types.BookmarkItem{{Title: "A"}, {Title: "B"}},
+	})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if output != "alice has 2 bookmarks" || mimeType != "text/plain" {
+		t.Errorf("Render() = %q, %q", output, mimeType)
+	}
+
+	if want := []string{"count", "json", "rss"}; strings.Join(r.Names(), ",") != strings.Join(want, ",") {
+		t.Errorf("Names() = %q, want %q", r.Names(), want)
+	}
+}
+
+func TestRegistryGetDefault(t *testing.T) {
+	formatter, err := NewRegistry().Get("")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if _, ok := formatter.(JSONFormatter); !ok {
+		t.Errorf("Get(\"\") = %T, want JSONFormatter", formatter)
+	}
+}
+
+func TestRegistryGetUnknown(t *testing.T) {
+	_, err := NewRegistry().Get("yaml")
+	if !errors.Is(err, types.ErrValidation) {
+		t.Fatalf("Get() error = %v, want a validation error", err)
+	}
+	if want := `Unsupported format "yaml" (supported: json, rss)`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %q, want it to contain %q", err, want)
+	}
+}
@@ -0,0 +1,225 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// Key casings for JSON output
+const (
+	KeyCaseSnake = "snake"
+	KeyCaseCamel = "camel"
+)
+
+// ValidateKeyCase checks that keyCase is empty or a supported key casing
+func ValidateKeyCase(keyCase string) error {
+	switch keyCase {
+	case "", KeyCaseSnake, KeyCaseCamel:
+		return nil
+	}
+	return &types.MCPError{
+		Code:    types.ErrorCodeValidation,
+		Message: fmt.Sprintf("Invalid key_case %q: must be %s or %s", keyCase, KeyCaseSnake, KeyCaseCamel),
+		Details: map[string]interface{}{"key_case": keyCase},
+	}
+}
+
+// WithKeyCase wraps a formatter so that its JSON output uses the given key
+// casing. Non-JSON output and the default snake_case are passed through.
+func WithKeyCase(formatter Formatter, keyCase string) Formatter {
+	if keyCase != KeyCaseCamel {
+		return formatter
+	}
+	return camelCaseFormatter{formatter}
+}
+
+// camelCaseFormatter rewrites the object keys of JSON output to camelCase
+type camelCaseFormatter struct {
+	Formatter
+}
+
+// Render renders the response and rewrites its JSON keys to camelCase
+func (f camelCaseFormatter) Render(response *types.GetHatenaBookmarksResponse) (string, string, error) {
+	output, mimeType, err := f.Formatter.Render(response)
+	if err != nil || mimeType != "application/json" {
+		return output, mimeType, err
+	}
+
+	rewritten, err := CamelCaseKeys([]byte(output), reflect.TypeOf(response))
+	if err != nil {
+		return "", "", err
+	}
+	return string(rewritten), mimeType, nil
+}
+
+// CamelCaseKeys returns the JSON encoding of a value of type schema with its
+// struct field names converted from snake_case to camelCase, preserving key
+// order, and indented the same way as JSONFormatter. Keys of maps, such as
+// tag names and header names, are data and are left unchanged, as is
+// everything below an interface{} value, whose shape is not known.
+func CamelCaseKeys(data []byte, schema reflect.Type) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var compact bytes.Buffer
+	if err := rewriteValue(dec, &compact, schema); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// rewriteValue copies the next JSON value from dec to buf, converting the
+// keys of objects encoding a struct of type schema to camelCase. A nil
+// schema copies the value unchanged.
+func rewriteValue(dec *json.Decoder, buf *bytes.Buffer, schema reflect.Type) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	for schema != nil && schema.Kind() == reflect.Pointer {
+		schema = schema.Elem()
+	}
+	if schema != nil && schema.Implements(marshalerType) {
+		// Custom encodings have no known field names
+		schema = nil
+	}
+
+	var fields map[string]reflect.Type
+	var elem reflect.Type
+	if schema != nil {
+		switch schema.Kind() {
+		case reflect.Struct:
+			fields = jsonFields(schema)
+		case reflect.Map, reflect.Slice, reflect.Array:
+			elem = schema.Elem()
+		}
+	}
+
+	switch delim := token.(type) {
+	case json.Delim:
+		switch delim {
+		case '{':
+			buf.WriteByte('{')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				keyToken, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				name := keyToken.(string)
+				valueSchema := elem
+				if fields != nil {
+					fieldSchema, ok := fields[name]
+					if ok {
+						name = camelCase(name)
+					}
+					valueSchema = fieldSchema
+				}
+				key, _ := json.Marshal(name)
+				buf.Write(key)
+				buf.WriteByte(':')
+				if err := rewriteValue(dec, buf, valueSchema); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			buf.WriteByte('}')
+		case '[':
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := rewriteValue(dec, buf, elem); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			buf.WriteByte(']')
+		}
+		return nil
+	default:
+		value, err := json.Marshal(token)
+		if err != nil {
+			return err
+		}
+		buf.Write(value)
+		return nil
+	}
+}
+
+// marshalerType is the type of json.Marshaler
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// jsonFields returns the types of the fields of a struct type by their JSON
+// names, including the fields promoted from embedded structs
+func jsonFields(schema reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < schema.NumField(); i++ {
+		field := schema.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for embeddedName, embeddedType := range jsonFields(embedded) {
+					if _, ok := fields[embeddedName]; !ok {
+						fields[embeddedName] = embeddedType
+					}
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// camelCase converts a snake_case key to camelCase, e.g. bookmarked_at to
+// bookmarkedAt. Keys without underscores are returned unchanged.
+func camelCase(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+
+	parts := strings.Split(key, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
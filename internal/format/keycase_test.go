@@ -0,0 +1,131 @@
+package format
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestCamelCase(t *testing.T) {
+	tests := map[string]string{
+		"bookmarked_at":       "bookmarkedAt",
+		"date_fallback_count": "dateFallbackCount",
+		"user":                "user",
+		"trailing_":           "trailing",
+		"double__underscore":  "doubleUnderscore",
+	}
+
+	for key, want := range tests {
+		if got := camelCase(key); got != want {
+			t.Errorf("camelCase(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+// keyCaseResponse has snake_case field names at several depths and map keys
+// that look like field names
+func keyCaseResponse() *types.GetHatenaBookmarksResponse {
+	bookmark := types.BookmarkItem{Title: "A", URL: "https://example.com/a", BookmarkedAt: "2024-02-10T09:00:00+09:00", Tags: []string{"my_tag"}}
+	return &types.GetHatenaBookmarksResponse{
+		User:         "alice",
+		TotalCount:   1,
+		Bookmarks:    []types.BookmarkItem{bookmark},
+		TagTree:      map[string]*types.TagNode{"my_tag": {Bookmarks: []types.BookmarkItem{bookmark}}},
+		DebugHeaders: map[string]string{"x_request_id": "abc"},
+	}
+}
+
+func TestWithKeyCase(t *testing.T) {
+	tests := []struct {
+		keyCase string
+		want    []string
+		absent  []string
+	}{
+		{"", []string{`"bookmarked_at"`, `"total_count"`, `"tag_tree"`}, []string{`"bookmarkedAt"`}},
+		{KeyCaseSnake, []string{`"bookmarked_at"`, `"total_count"`}, []string{`"bookmarkedAt"`}},
+		{KeyCaseCamel, []string{`"bookmarkedAt"`, `"totalCount"`, `"tagTree"`, `"debugHeaders"`}, []string{`"bookmarked_at"`, `"total_count"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyCase, func(t *testing.T) {
+			output, mimeType, err := WithKeyCase(JSONFormatter{}, tt.keyCase).Render(keyCaseResponse())
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if mimeType != "application/json" || !json.Valid([]byte(output)) {
+				t.Fatalf("Render() = %q, %q, want valid JSON", output, mimeType)
+			}
+			for _, key := range tt.want {
+				if !strings.Contains(output, key) {
+					t.Errorf("output has no %s key", key)
+				}
+			}
+			for _, key := range tt.absent {
+				if strings.Contains(output, key) {
+					t.Errorf("output has a %s key", key)
+				}
+			}
+		})
+	}
+}
+
+func TestCamelCaseKeysKeepsMapKeys(t *testing.T) {
+	output, _, err := WithKeyCase(JSONFormatter{}, KeyCaseCamel).Render(keyCaseResponse())
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	var decoded struct {
+		TagTree      map[string]json.RawMessage `json:"tagTree"`
+		DebugHeaders map[string]string          `json:"debugHeaders"`
+		Bookmarks    []struct {
+			Tags []string `json:"tags"`
+		} `json:"bookmarks"`
+	}
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := decoded.TagTree["my_tag"]; !ok {
+		t.Errorf("tag tree = %v, want the my_tag key unchanged", decoded.TagTree)
+	}
+	if decoded.DebugHeaders["x_request_id"] != "abc" {
+		t.Errorf("debug headers = %v, want x_request_id unchanged", decoded.DebugHeaders)
+	}
+	if !reflect.DeepEqual(decoded.Bookmarks[0].Tags, []string{"my_tag"}) {
+		t.Errorf("tags = %v, want values unchanged", decoded.Bookmarks[0].Tags)
+	}
+	// Fields of values below a map are still rewritten
+	if !strings.Contains(string(decoded.TagTree["my_tag"]), `"bookmarkedAt"`) {
+		t.Errorf("tag tree node = %s, want camelCase bookmark fields", decoded.TagTree["my_tag"])
+	}
+}
+
+func TestWithKeyCasePassesThroughNonJSON(t *testing.T) {
+	want, _, err := RSSFormatter{}.Render(keyCaseResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, mimeType, err := WithKeyCase(RSSFormatter{}, KeyCaseCamel).Render(keyCaseResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want || mimeType == "application/json" {
+		t.Errorf("RSS output was rewritten")
+	}
+}
+
+func TestValidateKeyCase(t *testing.T) {
+	for _, keyCase := range []string{"", KeyCaseSnake, KeyCaseCamel} {
+		if err := ValidateKeyCase(keyCase); err != nil {
+			t.Errorf("ValidateKeyCase(%q) error = %v", keyCase, err)
+		}
+	}
+	if err := ValidateKeyCase("kebab"); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("ValidateKeyCase(kebab) error = %v, want a validation error", err)
+	}
+}
@@ -0,0 +1,113 @@
+package format
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// Namespaces for the extension elements written by RenderRSS
+const (
+	dcNamespace     = "http://purl.org/dc/elements/1.1/"
+	hatenaNamespace = "http://www.hatena.ne.jp/info/xmlns#"
+)
+
+// rssDocument is the RSS 2.0 document written by RenderRSS
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Hatena  string     `xml:"xmlns:hatena,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// rssChannel is the RSS 2.0 channel written by RenderRSS
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+// rssItem is a single RSS 2.0 item written by RenderRSS.
+// Tags are written both as category (for RSS readers) and dc:subject
+// (as read back by the parser).
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description,omitempty"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	GUID        *rssGUID `xml:"guid,omitempty"`
+	Categories  []string `xml:"category"`
+	Subjects    []string `xml:"dc:subject"`
+
+	BookmarkCount int `xml:"hatena:bookmarkcount,omitempty"`
+}
+
+// rssGUID is an RSS 2.0 guid element
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSSFormatter renders responses as RSS 2.0 XML
+type RSSFormatter struct{}
+
+// Render renders the response as RSS 2.0 XML
+func (RSSFormatter) Render(response *types.GetHatenaBookmarksResponse) (string, string, error) {
+	output, err := RenderRSS(response)
+	if err != nil {
+		return "", "", err
+	}
+	return output, "application/rss+xml", nil
+}
+
+// RenderRSS renders the bookmarks of a response as an RSS 2.0 document.
+// The output re-parses through parser.ParseRSSFeed into equivalent items.
+func RenderRSS(response *types.GetHatenaBookmarksResponse) (string, error) {
+	doc := rssDocument{
+		Version: "2.0",
+		DC:      dcNamespace,
+		Hatena:  hatenaNamespace,
+		Channel: rssChannel{
+			Title:       fmt.Sprintf("%s's Hatena Bookmarks", response.User),
+			Link:        fmt.Sprintf("https://b.hatena.ne.jp/%s/bookmark", response.User),
+			Description: fmt.Sprintf("Bookmarks of %s exported by hatena-bookmark-mcp", response.User),
+			Items:       make([]rssItem, 0, len(response.Bookmarks)),
+		},
+	}
+
+	for _, bookmark := range response.Bookmarks {
+		item := rssItem{
+			Title:       bookmark.Title,
+			Link:        bookmark.URL,
+			Description: bookmark.Comment,
+			Categories:  bookmark.Tags,
+			Subjects:    bookmark.Tags,
+
+			BookmarkCount: bookmark.BookmarkCount,
+		}
+
+		if t, err := time.Parse(time.RFC3339, bookmark.BookmarkedAt); err == nil {
+			item.PubDate = t.Format(time.RFC1123Z)
+		}
+
+		if bookmark.GUID != "" {
+			item.GUID = &rssGUID{Value: bookmark.GUID}
+		}
+
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	output, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", (&types.MCPError{
+			Code:    types.ErrorCodeParsing,
+			Message: fmt.Sprintf("Failed to render RSS: %v", err),
+		}).WithCause(err)
+	}
+
+	return xml.Header + string(output), nil
+}
@@ -0,0 +1,92 @@
+package format
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/parser"
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestRenderRSSRoundTrip(t *testing.T) {
+	bookmarks := []types.BookmarkItem{
+		{
+			Title:         "Go 1.22 is released & <ready>",
+			URL:           "https://go.dev/blog/go1.22?a=1&b=2",
+			BookmarkedAt:  "2024-02-10T09:15:00+09:00",
+			Tags:          []string{"go", "あとで読む"},
+			Comment:       "range over int が便利",
+			GUID:          "https://b.hatena.ne.jp/alice/20240210#bookmark-4745611221",
+			BookmarkCount: 512,
+		},
+		{
+			Title:        "No tags",
+			URL:          "https://example.com/",
+			BookmarkedAt: "2024-02-08T12:00:00Z",
+			Tags:         []string{},
+		},
+	}
+
+	output, err := RenderRSS(&types.GetHatenaBookmarksResponse{User: "alice", Bookmarks: bookmarks})
+	if err != nil {
+		t.Fatalf("RenderRSS() error = %v", err)
+	}
+
+	p := parser.NewRSSParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	data, err := p.ParseRSSFeed(context.Background(), []byte(output))
+	if err != nil {
+		t.Fatalf("ParseRSSFeed() error = %v\n%s", err, output)
+	}
+
+	if data.Title != "alice's Hatena Bookmarks" {
+		t.Errorf("Title = %q", data.Title)
+	}
+	if len(data.Items) != len(bookmarks) {
+		t.Fatalf("got %d items, want %d", len(data.Items), len(bookmarks))
+	}
+	for i, want := range bookmarks {
+		got := data.Items[i]
+		if got.Title != want.Title || got.URL != want.URL || got.BookmarkedAt != want.BookmarkedAt ||
+			got.Comment != want.Comment || got.GUID != want.GUID || got.BookmarkCount != want.BookmarkCount {
+			t.Errorf("item %d = %+v, want %+v", i, got, want)
+		}
+		if !reflect.DeepEqual(got.Tags, want.Tags) {
+			t.Errorf("item %d Tags = %q, want %q", i, got.Tags, want.Tags)
+		}
+	}
+}
+
+func TestRenderRSSWritesCategories(t *testing.T) {
+	output, err := RenderRSS(&types.GetHatenaBookmarksResponse{
+		User:      "alice",
+		Bookmarks: []types.BookmarkItem{{Title: "A", URL: "https://example.com/a", Tags: []string{"go", "xml"}}},
+	})
+	if err != nil {
+		t.Fatalf("RenderRSS() error = %v", err)
+	}
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Items   []struct {
+			Categories []string `xml:"category"`
+			PubDate    string   `xml:"pubDate"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal([]byte(output), &doc); err != nil {
+		t.Fatalf("output is not valid XML: %v", err)
+	}
+	if doc.Version != "2.0" || !strings.HasPrefix(output, xml.Header) {
+		t.Errorf("output does not start as an RSS 2.0 document:\n%s", output)
+	}
+	if want := []string{"go", "xml"}; len(doc.Items) != 1 || !reflect.DeepEqual(doc.Items[0].Categories, want) {
+		t.Errorf("items = %+v, want categories %q", doc.Items, want)
+	}
+	if doc.Items[0].PubDate != "" {
+		t.Errorf("pubDate = %q, want it left out for an unparseable date", doc.Items[0].PubDate)
+	}
+}
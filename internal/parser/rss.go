@@ -1,45 +1,197 @@
 package parser
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"hatena-bookmark-mcp/internal/types"
+	"hatena-bookmark-mcp/internal/utils"
+)
+
+// Options configures optional parser behavior
+type Options struct {
+	// MaxTagsPerItem caps the number of tags kept per item (0 = unlimited)
+	MaxTagsPerItem int
+
+	// MaxTitleLength caps the title length in runes (0 = unlimited).
+	// Truncated titles end with an ellipsis and keep the original in FullTitle.
+	MaxTitleLength int
+
+	// DatePreference selects which date wins when an item carries both
+	// dc:date and pubDate: DatePreferenceDCFirst (default) or
+	// DatePreferencePubDateFirst. The other is used if the preferred one is
+	// missing or unparseable.
+	DatePreference string
+
+	// MinCommentLength drops comments shorter than this many runes, such as
+	// single-character noise (0 = keep all)
+	MinCommentLength int
+
+	// MissingLinkPolicy selects what happens to items without a link:
+	// MissingLinkSkip (default) drops them with a warning, MissingLinkKeep
+	// keeps them with an empty URL, and MissingLinkError fails the parse
+	MissingLinkPolicy string
+
+	// DeriveUntitled replaces the title of untitled bookmarks, whose title is
+	// empty or just their URL, with one derived from the URL's host and path,
+	// keeping the original in RawTitle
+	DeriveUntitled bool
+}
+
+// Supported values of Options.DatePreference
+const (
+	DatePreferenceDCFirst      = "dc_first"
+	DatePreferencePubDateFirst = "pubdate_first"
+)
+
+// Supported values of Options.MissingLinkPolicy
+const (
+	MissingLinkSkip  = "skip"
+	MissingLinkKeep  = "keep"
+	MissingLinkError = "error"
 )
 
 // RSSParser handles RSS feed parsing
 type RSSParser struct {
-	logger *slog.Logger
+	logger  *slog.Logger
+	options Options
 }
 
 // NewRSSParser creates a new RSS parser instance
 func NewRSSParser(logger *slog.Logger) *RSSParser {
+	return NewRSSParserWithOptions(logger, Options{})
+}
+
+// NewRSSParserWithOptions creates a new RSS parser instance with the given options
+func NewRSSParserWithOptions(logger *slog.Logger, options Options) *RSSParser {
 	return &RSSParser{
-		logger: logger,
+		logger:  logger,
+		options: options,
 	}
 }
 
 // ParseRSSFeed parses RSS XML content and returns structured data
 // Supports both RSS 2.0 and RDF/RSS 1.0 formats
 func (p *RSSParser) ParseRSSFeed(ctx context.Context, xmlContent []byte) (*types.ParsedRSSData, error) {
+	// Tag every log line of this parse with the caller's request ID
+	p = &RSSParser{logger: utils.LoggerFromContext(ctx, p.logger), options: p.options}
+
 	p.logger.Debug("Starting RSS feed parsing", "content_length", len(xmlContent))
 
-	// Detect format and parse accordingly
-	if p.isRDFFormat(xmlContent) {
-		return p.parseRDFFeed(ctx, xmlContent)
+	return p.parseDetected(xmlContent, func(rdf bool) (*types.ParsedRSSData, error) {
+		if rdf {
+			return p.parseRDFFeed(ctx, xmlContent)
+		}
+		return p.parseRSS2Feed(ctx, xmlContent)
+	})
+}
+
+// parseDetected parses xmlContent with parse as the detected format and, if
+// it turns out to be of the other format, as that one. ParseRSSFeed and
+// StreamRSSFeed both go through it, so that a feed parses the same way
+// whichever of them is used.
+func (p *RSSParser) parseDetected(xmlContent []byte, parse func(rdf bool) (*types.ParsedRSSData, error)) (*types.ParsedRSSData, error) {
+	rdf := p.isRDFFormat(xmlContent)
+
+	data, err := parse(rdf)
+	if err != nil && !isRootMismatch(err) {
+		return nil, err
 	}
-	
-	return p.parseRSS2Feed(ctx, xmlContent)
+	if err == nil && !isEmptyFeed(data) {
+		return data, nil
+	}
+
+	// A well-formed document of another format (e.g. Atom) either has an
+	// unexpected root element or parses into an empty feed without error,
+	// so try the other format before giving up. Neither case has produced
+	// any items yet.
+	p.logger.Debug("Feed did not parse as the detected format, trying alternate format")
+	if data, err := parse(!rdf); err == nil && !isEmptyFeed(data) {
+		return data, nil
+	}
+
+	root := rootElement(xmlContent)
+	p.logger.Error("Feed format not recognized", "root_element", root)
+	return nil, &types.MCPError{
+		Code:    types.ErrorCodeParsing,
+		Message: "feed format not recognized",
+		Details: map[string]interface{}{"xml_length": len(xmlContent), "root_element": root},
+	}
+}
+
+// isEmptyFeed reports whether a parsed feed has neither a channel title nor
+// any items, as happens when the document is not RSS or RDF at all
+func isEmptyFeed(data *types.ParsedRSSData) bool {
+	return data.Title == "" && data.TotalItems == 0
+}
+
+// isRootMismatch reports whether err is encoding/xml's error for a document
+// whose root element is not the one expected
+func isRootMismatch(err error) bool {
+	var unmarshalErr xml.UnmarshalError
+	return errors.As(err, &unmarshalErr) && strings.HasPrefix(string(unmarshalErr), "expected element type")
 }
 
-// isRDFFormat detects if the XML content is RDF/RSS 1.0 format
+// rootElement returns the local name of the document's root element, or an
+// empty string if it cannot be read
+func rootElement(xmlContent []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(xmlContent))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if root, ok := token.(xml.StartElement); ok {
+			return root.Name.Local
+		}
+	}
+}
+
+// Namespaces identifying RDF/RSS 1.0 documents
+const (
+	rdfNamespace   = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rss10Namespace = "http://purl.org/rss/1.0/"
+)
+
+// isRDFFormat detects if the XML content is RDF/RSS 1.0 format.
+// Detection inspects the root element's namespaces rather than the "rdf:"
+// prefix, since some proxied feeds declare the namespaces differently.
 func (p *RSSParser) isRDFFormat(xmlContent []byte) bool {
-	return strings.Contains(string(xmlContent), "<rdf:RDF") || strings.Contains(string(xmlContent), "xmlns:rdf")
+	decoder := xml.NewDecoder(bytes.NewReader(xmlContent))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// Fall back to a textual check if the root cannot be read
+			return strings.Contains(string(xmlContent), "<rdf:RDF") || strings.Contains(string(xmlContent), "xmlns:rdf")
+		}
+
+		root, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if root.Name.Space == rdfNamespace || root.Name.Local == "RDF" {
+			return true
+		}
+
+		for _, attr := range root.Attr {
+			if attr.Value == rss10Namespace || attr.Value == rdfNamespace {
+				return true
+			}
+		}
+
+		return false
+	}
 }
 
 // parseRSS2Feed parses standard RSS 2.0 format (original implementation)
@@ -47,14 +199,10 @@ func (p *RSSParser) parseRSS2Feed(ctx context.Context, xmlContent []byte) (*type
 	var rss types.RSS
 	if err := xml.Unmarshal(xmlContent, &rss); err != nil {
 		p.logger.Error("Failed to unmarshal RSS XML", "error", err)
-		return nil, &types.MCPError{
-			Code:    types.ErrorCodeParsing,
-			Message: fmt.Sprintf("Failed to parse RSS XML: %v", err),
-			Details: map[string]interface{}{"xml_length": len(xmlContent)},
-		}
+		return nil, p.unmarshalError("RSS", err, xmlContent)
 	}
 
-	bookmarks, err := p.extractBookmarkItems(&rss.Channel)
+	bookmarks, warnings, err := p.extractBookmarkItems(&rss.Channel)
 	if err != nil {
 		p.logger.Error("Failed to extract bookmark items", "error", err)
 		return nil, err
@@ -65,9 +213,11 @@ func (p *RSSParser) parseRSS2Feed(ctx context.Context, xmlContent []byte) (*type
 		"item_count", len(bookmarks))
 
 	return &types.ParsedRSSData{
-		Title:     rss.Channel.Title,
-		Items:     bookmarks,
-		ItemCount: len(bookmarks),
+		Title:      rss.Channel.Title,
+		Items:      bookmarks,
+		ItemCount:  len(bookmarks),
+		TotalItems: len(rss.Channel.Items),
+		Warnings:   warnings,
 	}, nil
 }
 
@@ -76,14 +226,10 @@ func (p *RSSParser) parseRDFFeed(ctx context.Context, xmlContent []byte) (*types
 	var rdf types.RDF
 	if err := xml.Unmarshal(xmlContent, &rdf); err != nil {
 		p.logger.Error("Failed to unmarshal RDF XML", "error", err)
-		return nil, &types.MCPError{
-			Code:    types.ErrorCodeParsing,
-			Message: fmt.Sprintf("Failed to parse RDF XML: %v", err),
-			Details: map[string]interface{}{"xml_length": len(xmlContent)},
-		}
+		return nil, p.unmarshalError("RDF", err, xmlContent)
 	}
 
-	bookmarks, err := p.extractRDFBookmarkItems(rdf.Items)
+	bookmarks, warnings, err := p.extractRDFBookmarkItems(rdf.Items, rdf.Channel.Link)
 	if err != nil {
 		p.logger.Error("Failed to extract RDF bookmark items", "error", err)
 		return nil, err
@@ -94,62 +240,204 @@ func (p *RSSParser) parseRDFFeed(ctx context.Context, xmlContent []byte) (*types
 		"item_count", len(bookmarks))
 
 	return &types.ParsedRSSData{
-		Title:     rdf.Channel.Title,
-		Items:     bookmarks,
-		ItemCount: len(bookmarks),
+		Title:      rdf.Channel.Title,
+		Items:      bookmarks,
+		ItemCount:  len(bookmarks),
+		TotalItems: len(rdf.Items),
+		Warnings:   warnings,
 	}, nil
 }
 
-// extractBookmarkItems converts RSS items to bookmark items
-func (p *RSSParser) extractBookmarkItems(channel *types.Channel) ([]types.BookmarkItem, error) {
+// unmarshalError builds the PARSING_ERROR for a failed unmarshal, reporting
+// truncated documents (e.g. a connection dropped mid-transfer) distinctly
+// from malformed ones
+func (p *RSSParser) unmarshalError(feedFormat string, err error, xmlContent []byte) *types.MCPError {
+	if charset, ok := unsupportedCharset(err); ok {
+		return (&types.MCPError{
+			Code:    types.ErrorCodeParsing,
+			Message: fmt.Sprintf("unsupported or corrupt character encoding: %s", charset),
+			Details: map[string]interface{}{"xml_length": len(xmlContent), "charset": charset},
+		}).WithCause(err)
+	}
+
+	if isTruncationError(err, xmlContent) {
+		return (&types.MCPError{
+			Code:    types.ErrorCodeParsing,
+			Message: fmt.Sprintf("feed appears truncated (%d bytes read)", len(xmlContent)),
+			Details: map[string]interface{}{"xml_length": len(xmlContent), "truncated": true},
+		}).WithCause(err)
+	}
+
+	return (&types.MCPError{
+		Code:    types.ErrorCodeParsing,
+		Message: fmt.Sprintf("Failed to parse %s XML: %v", feedFormat, err),
+		Details: map[string]interface{}{"xml_length": len(xmlContent)},
+	}).WithCause(err)
+}
+
+// unsupportedCharsetPattern matches the error encoding/xml returns for a
+// document declaring a charset it cannot decode
+var unsupportedCharsetPattern = regexp.MustCompile(`xml: encoding "([^"]*)" declared`)
+
+// unsupportedCharset reports whether err is a character encoding failure,
+// returning the declared charset
+func unsupportedCharset(err error) (string, bool) {
+	match := unsupportedCharsetPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// isTruncationError reports whether an XML error indicates the document
+// ended early, including in the middle of a multi-byte character
+func isTruncationError(err error, xmlContent []byte) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var syntaxErr *xml.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return false
+	}
+	return syntaxErr.Msg == "unexpected EOF" || (syntaxErr.Msg == "invalid UTF-8" && endsMidRune(xmlContent))
+}
+
+// endsMidRune reports whether content ends with an incomplete UTF-8 sequence
+func endsMidRune(content []byte) bool {
+	start := len(content) - 1
+	for start > 0 && start > len(content)-utf8.UTFMax && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	return start >= 0 && !utf8.FullRune(content[start:])
+}
+
+// warningList collects non-fatal problems found while parsing a feed
+type warningList []string
+
+// add records a warning
+func (w *warningList) add(format string, args ...interface{}) {
+	*w = append(*w, fmt.Sprintf(format, args...))
+}
+
+// extractBookmarkItems converts RSS items to bookmark items, also returning
+// warnings about skipped items and unparseable dates. Relative item links are
+// resolved against the channel link.
+func (p *RSSParser) extractBookmarkItems(channel *types.Channel) ([]types.BookmarkItem, []string, error) {
 	bookmarks := make([]types.BookmarkItem, 0, len(channel.Items))
+	var warnings warningList
 
 	for _, item := range channel.Items {
-		bookmark, err := p.convertItemToBookmark(item)
+		bookmark, ok, err := p.convertRSSItem(item, channel.Link, &warnings)
 		if err != nil {
-			p.logger.Warn("Failed to convert RSS item to bookmark", 
-				"title", item.Title, 
-				"error", err)
-			continue
+			return nil, nil, err
+		}
+		if ok {
+			bookmarks = append(bookmarks, bookmark)
 		}
-		bookmarks = append(bookmarks, bookmark)
 	}
 
-	return bookmarks, nil
+	return bookmarks, warnings, nil
 }
 
-// extractRDFBookmarkItems converts RDF items to bookmark items
-func (p *RSSParser) extractRDFBookmarkItems(items []types.RDFItem) ([]types.BookmarkItem, error) {
+// convertRSSItem resolves the item link against channelLink and converts the
+// item, reporting false with a warning if it has to be skipped. An error is
+// returned only for a missing link under MissingLinkError.
+func (p *RSSParser) convertRSSItem(item types.Item, channelLink string, warnings *warningList) (types.BookmarkItem, bool, error) {
+	item.Link = resolveLink(channelLink, item.Link)
+	if ok, err := p.checkLink(item.Link, item.Title, warnings); !ok {
+		return types.BookmarkItem{}, false, err
+	}
+
+	bookmark, err := p.convertItemToBookmark(item, warnings)
+	if err != nil {
+		p.logger.Warn("Failed to convert RSS item to bookmark",
+			"title", item.Title,
+			"error", err)
+		warnings.add("skipped item %q: %v", item.Title, err)
+		return types.BookmarkItem{}, false, nil
+	}
+	return bookmark, true, nil
+}
+
+// extractRDFBookmarkItems converts RDF items to bookmark items, also
+// returning warnings about skipped items and unparseable dates. Relative
+// item links are resolved against channelLink.
+func (p *RSSParser) extractRDFBookmarkItems(items []types.RDFItem, channelLink string) ([]types.BookmarkItem, []string, error) {
 	bookmarks := make([]types.BookmarkItem, 0, len(items))
+	var warnings warningList
 
 	for _, item := range items {
-		bookmark, err := p.convertRDFItemToBookmark(item)
+		bookmark, ok, err := p.convertRDFItem(item, channelLink, &warnings)
 		if err != nil {
-			p.logger.Warn("Failed to convert RDF item to bookmark", 
-				"title", item.Title, 
-				"error", err)
-			continue
+			return nil, nil, err
 		}
-		bookmarks = append(bookmarks, bookmark)
+		if ok {
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+
+	return bookmarks, warnings, nil
+}
+
+// convertRDFItem resolves the item link against channelLink and converts the
+// item, reporting false with a warning if it has to be skipped. An error is
+// returned only for a missing link under MissingLinkError.
+func (p *RSSParser) convertRDFItem(item types.RDFItem, channelLink string, warnings *warningList) (types.BookmarkItem, bool, error) {
+	item.Link = resolveLink(channelLink, item.Link)
+	if ok, err := p.checkLink(item.Link, item.Title, warnings); !ok {
+		return types.BookmarkItem{}, false, err
 	}
 
-	return bookmarks, nil
+	bookmark, err := p.convertRDFItemToBookmark(item, warnings)
+	if err != nil {
+		p.logger.Warn("Failed to convert RDF item to bookmark",
+			"title", item.Title,
+			"error", err)
+		warnings.add("skipped item %q: %v", item.Title, err)
+		return types.BookmarkItem{}, false, nil
+	}
+	return bookmark, true, nil
+}
+
+// checkLink applies MissingLinkPolicy to an item's resolved link, reporting
+// whether the item should be converted
+func (p *RSSParser) checkLink(link, title string, warnings *warningList) (bool, error) {
+	if strings.TrimSpace(link) != "" {
+		return true, nil
+	}
+
+	switch p.options.MissingLinkPolicy {
+	case MissingLinkKeep:
+		return true, nil
+	case MissingLinkError:
+		p.logger.Error("Feed item has no link", "title", title)
+		return false, &types.MCPError{
+			Code:    types.ErrorCodeParsing,
+			Message: fmt.Sprintf("item %q has no link", title),
+			Details: map[string]interface{}{"title": title},
+		}
+	default:
+		p.logger.Warn("Skipping feed item without a link", "title", title)
+		warnings.add("skipped item %q: no link", title)
+		return false, nil
+	}
 }
 
 // convertRDFItemToBookmark converts a single RDF item to a bookmark
-func (p *RSSParser) convertRDFItemToBookmark(item types.RDFItem) (types.BookmarkItem, error) {
-	// Parse the RDF date (dc:date format)
-	bookmarkedAt, err := p.parseRDFDate(item.Date)
+func (p *RSSParser) convertRDFItemToBookmark(item types.RDFItem, warnings *warningList) (types.BookmarkItem, error) {
+	p.fillNamespaceVariants(&item)
+
+	// Parse the RDF date (dc:date format, or pubDate in hybrid feeds)
+	bookmarkedAt, dateFromFeed, err := p.itemDate(item.Date, item.PubDate)
 	if err != nil {
-		p.logger.Warn("Failed to parse RDF date", "date", item.Date, "error", err)
+		p.logger.Warn("Failed to parse RDF date", "date", item.Date, "pubdate", item.PubDate, "error", err)
+		warnings.add("item %q: %v; using the current time", item.Title, err)
 		bookmarkedAt = time.Now().Format(time.RFC3339)
 	}
 
-	// Extract tags from dc:subject (RDF may have single subject)
-	var tags []string
-	if item.Subject != "" {
-		tags = []string{strings.TrimSpace(item.Subject)}
-	}
+	// Extract tags from dc:subject elements
+	tags := p.limitTags(p.extractTags(item.Subjects), item.Title)
 
 	// Extract comment from description or content:encoded
 	comment := p.extractComment(item.Description)
@@ -157,37 +445,99 @@ func (p *RSSParser) convertRDFItemToBookmark(item types.RDFItem) (types.Bookmark
 		comment = p.extractComment(item.ContentEncoded)
 	}
 
-	return types.BookmarkItem{
+	bookmark := types.BookmarkItem{
 		Title:        strings.TrimSpace(item.Title),
 		URL:          strings.TrimSpace(item.Link),
 		BookmarkedAt: bookmarkedAt,
+		DateFallback: !dateFromFeed,
 		Tags:         tags,
 		Comment:      comment,
-	}, nil
+		GUID:         strings.TrimSpace(item.About),
+		EntryURL:     entryURL(item.Link),
+		Creator:      strings.TrimSpace(item.Creator),
+
+		BookmarkCount: item.BookmarkCount,
+	}
+	p.deriveUntitled(&bookmark)
+	p.limitTitle(&bookmark)
+
+	return bookmark, nil
+}
+
+// fillNamespaceVariants fills namespaced fields that encoding/xml missed because
+// the feed declared the dc or content namespace with a non-standard URI.
+// Unmatched child elements are looked up by local name instead.
+func (p *RSSParser) fillNamespaceVariants(item *types.RDFItem) {
+	var variantSubjects []string
+
+	for _, elem := range item.Others {
+		value := strings.TrimSpace(elem.Value)
+		if value == "" {
+			continue
+		}
+
+		switch elem.XMLName.Local {
+		case "date":
+			if item.Date == "" {
+				item.Date = value
+			}
+		case "creator":
+			if item.Creator == "" {
+				item.Creator = value
+			}
+		case "subject":
+			variantSubjects = append(variantSubjects, value)
+		case "encoded":
+			if item.ContentEncoded == "" {
+				item.ContentEncoded = value
+			}
+		default:
+			continue
+		}
+
+		p.logger.Debug("Matched element with non-standard namespace",
+			"local_name", elem.XMLName.Local,
+			"namespace", elem.XMLName.Space)
+	}
+
+	if len(item.Subjects) == 0 {
+		item.Subjects = variantSubjects
+	}
 }
 
 // convertItemToBookmark converts a single RSS item to a bookmark
-func (p *RSSParser) convertItemToBookmark(item types.Item) (types.BookmarkItem, error) {
-	// Parse the date
-	bookmarkedAt, err := p.parseDate(item.PubDate)
+func (p *RSSParser) convertItemToBookmark(item types.Item, warnings *warningList) (types.BookmarkItem, error) {
+	// Parse the date (pubDate, or dc:date in hybrid feeds)
+	bookmarkedAt, dateFromFeed, err := p.itemDate(item.DCDate, item.PubDate)
 	if err != nil {
-		p.logger.Warn("Failed to parse date", "pubdate", item.PubDate, "error", err)
+		p.logger.Warn("Failed to parse date", "date", item.DCDate, "pubdate", item.PubDate, "error", err)
+		warnings.add("item %q: %v; using the current time", item.Title, err)
 		bookmarkedAt = time.Now().Format(time.RFC3339)
 	}
 
 	// Extract tags from dc:subject elements
-	tags := p.extractTags(item.Subjects)
+	tags := p.limitTags(p.extractTags(item.Subjects), item.Title)
 
 	// Extract comment from description
 	comment := p.extractComment(item.Description)
 
-	return types.BookmarkItem{
+	bookmark := types.BookmarkItem{
 		Title:        strings.TrimSpace(item.Title),
 		URL:          strings.TrimSpace(item.Link),
 		BookmarkedAt: bookmarkedAt,
+		DateFallback: !dateFromFeed,
 		Tags:         tags,
 		Comment:      comment,
-	}, nil
+		GUID:         strings.TrimSpace(item.GUID),
+		EntryURL:     entryURL(item.Link),
+		Creator:      strings.TrimSpace(item.Creator),
+
+		BookmarkCount: item.BookmarkCount,
+	}
+	p.deriveUntitled(&bookmark)
+	p.limitTitle(&bookmark)
+
+	return bookmark, nil
 }
 
 // extractTags processes dc:subject elements to extract tag strings
@@ -204,6 +554,109 @@ func (p *RSSParser) extractTags(subjects []string) []string {
 	return tags
 }
 
+// limitTags truncates the tag list to MaxTagsPerItem, keeping first-seen order
+func (p *RSSParser) limitTags(tags []string, title string) []string {
+	if p.options.MaxTagsPerItem <= 0 || len(tags) <= p.options.MaxTagsPerItem {
+		return tags
+	}
+
+	p.logger.Debug("Truncating item tags",
+		"title", title,
+		"tag_count", len(tags),
+		"max_tags", p.options.MaxTagsPerItem)
+
+	return tags[:p.options.MaxTagsPerItem]
+}
+
+// resolveLink resolves a relative item link against the channel link.
+// Absolute links, and links that cannot be resolved, are returned as is.
+func resolveLink(channelLink, link string) string {
+	link = strings.TrimSpace(link)
+
+	ref, err := url.Parse(link)
+	if err != nil || ref.IsAbs() || link == "" {
+		return link
+	}
+
+	base, err := url.Parse(strings.TrimSpace(channelLink))
+	if err != nil || !base.IsAbs() {
+		return link
+	}
+
+	return base.ResolveReference(ref).String()
+}
+
+// entryBaseURL is the prefix of Hatena Bookmark entry pages
+const entryBaseURL = "https://b.hatena.ne.jp/entry/"
+
+// entryURL derives the Hatena Bookmark entry page of an article URL:
+// entry/{host/path} for http and entry/s/{host/path} for https.
+// Other URLs yield an empty string.
+func entryURL(link string) string {
+	link = strings.TrimSpace(link)
+	if i := strings.Index(link, "#"); i >= 0 {
+		link = link[:i]
+	}
+
+	switch {
+	case strings.HasPrefix(link, "https://"):
+		return entryBaseURL + "s/" + strings.TrimPrefix(link, "https://")
+	case strings.HasPrefix(link, "http://"):
+		return entryBaseURL + strings.TrimPrefix(link, "http://")
+	default:
+		return ""
+	}
+}
+
+// deriveUntitled gives untitled bookmarks a readable title such as
+// "example.com/blog/post" when DeriveUntitled is set
+func (p *RSSParser) deriveUntitled(bookmark *types.BookmarkItem) {
+	if !p.options.DeriveUntitled || (bookmark.Title != "" && bookmark.Title != bookmark.URL) {
+		return
+	}
+
+	title := untitledTitle(bookmark.URL)
+	if title == "" {
+		return
+	}
+
+	bookmark.RawTitle = bookmark.Title
+	bookmark.Title = title
+}
+
+// untitledTitle derives a title from the host and path of a link, without
+// a leading "www." or trailing slash, or returns an empty string if the link
+// has no host
+func untitledTitle(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+
+	return strings.TrimPrefix(u.Hostname(), "www.") + strings.TrimRight(u.Path, "/")
+}
+
+// titleEllipsis marks a truncated title
+const titleEllipsis = "…"
+
+// limitTitle truncates the title to MaxTitleLength runes, ellipsis included,
+// and keeps the original title in FullTitle
+func (p *RSSParser) limitTitle(bookmark *types.BookmarkItem) {
+	maxLength := p.options.MaxTitleLength
+	if maxLength <= 0 || utf8.RuneCountInString(bookmark.Title) <= maxLength {
+		return
+	}
+
+	runes := []rune(bookmark.Title)
+	bookmark.FullTitle = bookmark.Title
+	bookmark.Title = string(runes[:maxLength-1]) + titleEllipsis
+
+	p.logger.Debug("Truncating item title",
+		"url", bookmark.URL,
+		"title_length", len(runes),
+		"max_title_length", maxLength)
+}
+
 // extractComment extracts user comment from RSS description
 func (p *RSSParser) extractComment(description string) string {
 	// Hatena Bookmark RSS often includes user comments in the description
@@ -220,6 +673,10 @@ func (p *RSSParser) extractComment(description string) string {
 	if len(comment) > 500 {
 		return ""
 	}
+
+	if utf8.RuneCountInString(comment) < p.options.MinCommentLength {
+		return ""
+	}
 	
 	return comment
 }
@@ -230,18 +687,60 @@ func (p *RSSParser) stripHTMLTags(text string) string {
 	return re.ReplaceAllString(text, "")
 }
 
+// itemDate parses an item's date from dc:date or pubDate, trying them in the
+// order given by DatePreference and falling back to the other. An item with
+// neither date gets the current time, and fromFeed reports false.
+func (p *RSSParser) itemDate(dcDate, pubDate string) (bookmarkedAt string, fromFeed bool, err error) {
+	type candidate struct {
+		value string
+		parse func(string) (string, error)
+	}
+	candidates := []candidate{{dcDate, p.parseRDFDate}, {pubDate, p.parseDate}}
+	if p.options.DatePreference == DatePreferencePubDateFirst {
+		candidates[0], candidates[1] = candidates[1], candidates[0]
+	}
+
+	var firstErr error
+	for _, c := range candidates {
+		value := strings.TrimSpace(c.value)
+		if value == "" {
+			continue
+		}
+
+		bookmarkedAt, err := c.parse(value)
+		if err == nil {
+			return bookmarkedAt, true, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return "", false, firstErr
+	}
+	return time.Now().Format(time.RFC3339), false, nil
+}
+
 // parseDate converts various date formats to ISO 8601
 func (p *RSSParser) parseDate(dateString string) (string, error) {
 	if dateString == "" {
 		return time.Now().Format(time.RFC3339), nil
 	}
 
-	// Common RSS date formats to try
+	// Common RSS date formats to try.
+	// Two-digit years ("06") are interpreted by time.Parse as 1969-2068,
+	// i.e. 00-68 map to 2000-2068, following the RFC 2822 convention.
 	formats := []string{
 		time.RFC1123,     // "Mon, 02 Jan 2006 15:04:05 MST"
 		time.RFC1123Z,    // "Mon, 02 Jan 2006 15:04:05 -0700"
+		"Mon, 2 Jan 2006 15:04:05 -0700", // Single-digit day, emitted by Hatena
+		"Mon, 2 Jan 2006 15:04:05 MST",   // Single-digit day with zone name
+		"Mon, 2 Jan 06 15:04:05 -0700",   // Two-digit year with weekday
+		"Mon, 2 Jan 06 15:04:05 MST",     // Two-digit year with weekday and zone name
 		time.RFC822,      // "02 Jan 06 15:04 MST"
 		time.RFC822Z,     // "02 Jan 06 15:04 -0700"
+		"2 Jan 06 15:04 -0700", // Two-digit year with single-digit day
 		time.RFC3339,     // "2006-01-02T15:04:05Z07:00"
 		"2006-01-02 15:04:05", // Common alternative format
 	}
@@ -0,0 +1,708 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// newTestParser returns a parser with the given options that logs nowhere
+func newTestParser(options Options) *RSSParser {
+	return NewRSSParserWithOptions(slog.New(slog.NewTextHandler(io.Discard, nil)), options)
+}
+
+// readFixture returns the contents of a file in testdata
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	return content
+}
+
+// rdfFeed wraps items in an RDF/RSS 1.0 document declaring the namespaces
+// Hatena uses
+func rdfFeed(items ...string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns="http://purl.org/rss/1.0/"
+ xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+ xmlns:content="http://purl.org/rss/1.0/modules/content/"
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:hatena="http://www.hatena.ne.jp/info/xmlns#">
+<channel rdf:about="https://b.hatena.ne.jp/alice/bookmark">
+  <title>alice's bookmarks</title>
+  <link>https://b.hatena.ne.jp/alice/bookmark</link>
+</channel>
+` + strings.Join(items, "\n") + `
+</rdf:RDF>`
+}
+
+// rssFeed wraps items in an RSS 2.0 document
+func rssFeed(items ...string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+  <title>alice's bookmarks</title>
+  <link>https://b.hatena.ne.jp/alice/bookmark</link>
+` + strings.Join(items, "\n") + `
+</channel>
+</rss>`
+}
+
+// rdfItem returns an RDF item linking to link, followed by the given
+// child elements
+func rdfItem(link, title string, children ...string) string {
+	return `<item rdf:about="` + link + `"><title>` + title + `</title><link>` + link + `</link>` +
+		strings.Join(children, "") + `</item>`
+}
+
+// rssItem returns an RSS 2.0 item linking to link, followed by the given
+// child elements
+func rssItem(link, title string, children ...string) string {
+	return `<item><title>` + title + `</title><link>` + link + `</link>` +
+		strings.Join(children, "") + `</item>`
+}
+
+// mustParse parses content with the given options, failing the test on error
+func mustParse(t *testing.T, options Options, content string) *types.ParsedRSSData {
+	t.Helper()
+
+	data, err := newTestParser(options).ParseRSSFeed(context.Background(), []byte(content))
+	if err != nil {
+		t.Fatalf("ParseRSSFeed() error = %v", err)
+	}
+	return data
+}
+
+func TestParseRSSFeedFixture(t *testing.T) {
+	data := mustParse(t, Options{}, string(readFixture(t, "hatena_rdf.xml")))
+
+	if data.Title != "aliceのブックマーク" {
+		t.Errorf("Title = %q", data.Title)
+	}
+	if data.ItemCount != 3 || data.TotalItems != 3 {
+		t.Fatalf("ItemCount, TotalItems = %d, %d, want 3, 3", data.ItemCount, data.TotalItems)
+	}
+
+	first := data.Items[0]
+	if first.URL != "https://go.dev/blog/go1.22" {
+		t.Errorf("URL = %q", first.URL)
+	}
+	if first.BookmarkedAt != "2024-02-10T09:15:00+09:00" {
+		t.Errorf("BookmarkedAt = %q", first.BookmarkedAt)
+	}
+	if want := []string{"go", "programming", "release"}; !reflect.DeepEqual(first.Tags, want) {
+		t.Errorf("Tags = %q, want %q", first.Tags, want)
+	}
+	if first.Comment != "range over int が便利" {
+		t.Errorf("Comment = %q", first.Comment)
+	}
+	if first.BookmarkCount != 512 || first.Creator != "alice" {
+		t.Errorf("BookmarkCount, Creator = %d, %q", first.BookmarkCount, first.Creator)
+	}
+}
+
+func TestParseRSSFeedMaxTagsPerItem(t *testing.T) {
+	tags := `<dc:subject>go</dc:subject><dc:subject>rss</dc:subject><dc:subject> </dc:subject><dc:subject>xml</dc:subject>`
+	feeds := map[string]string{
+		"rdf": rdfFeed(rdfItem("https://example.com/", "Example", tags)),
+		"rss": rssFeed(rssItem("https://example.com/", "Example", tags)),
+	}
+
+	tests := []struct {
+		name    string
+		maxTags int
+		want    []string
+	}{
+		{"unlimited", 0, []string{"go", "rss", "xml"}},
+		{"cap keeps the first tags", 2, []string{"go", "rss"}},
+		{"cap counts only non-empty tags", 3, []string{"go", "rss", "xml"}},
+		{"cap above tag count", 10, []string{"go", "rss", "xml"}},
+	}
+
+	for format, feed := range feeds {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				data := mustParse(t, Options{MaxTagsPerItem: tt.maxTags}, feed)
+
+				if got := data.Items[0].Tags; !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Tags = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestParseRSSFeedVariantNamespaces(t *testing.T) {
+	data := mustParse(t, Options{}, string(readFixture(t, "rdf_variant_namespaces.xml")))
+
+	if len(data.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(data.Items))
+	}
+
+	variant := data.Items[0]
+	if variant.BookmarkedAt != "2024-02-10T09:15:00+09:00" || variant.DateFallback {
+		t.Errorf("BookmarkedAt = %q (fallback %v), want the variant dc:date", variant.BookmarkedAt, variant.DateFallback)
+	}
+	if variant.Creator != "alice" {
+		t.Errorf("Creator = %q, want alice", variant.Creator)
+	}
+	if want := []string{"go", "xml"}; !reflect.DeepEqual(variant.Tags, want) {
+		t.Errorf("Tags = %q, want %q", variant.Tags, want)
+	}
+	if variant.Comment != "from content:encoded" {
+		t.Errorf("Comment = %q, want the variant content:encoded", variant.Comment)
+	}
+
+	mixed := data.Items[1]
+	if mixed.BookmarkedAt != "2024-02-08T12:00:00+09:00" {
+		t.Errorf("BookmarkedAt = %q, want the standard dc:date", mixed.BookmarkedAt)
+	}
+	if want := []string{"standard"}; !reflect.DeepEqual(mixed.Tags, want) {
+		t.Errorf("Tags = %q, want only the standard subjects %q", mixed.Tags, want)
+	}
+	if mixed.Comment != "from description" {
+		t.Errorf("Comment = %q", mixed.Comment)
+	}
+}
+
+func TestParseRSSFeedGUID(t *testing.T) {
+	rss := mustParse(t, Options{}, rssFeed(
+		rssItem("https://example.com/a", "A", `<guid isPermaLink="false"> hatena-bookmark-4745611221 </guid>`),
+		rssItem("https://example.com/b", "B"),
+	))
+	if rss.Items[0].GUID != "hatena-bookmark-4745611221" {
+		t.Errorf("RSS GUID = %q, want the trimmed guid", rss.Items[0].GUID)
+	}
+	if rss.Items[1].GUID != "" {
+		t.Errorf("RSS GUID = %q, want empty without a guid", rss.Items[1].GUID)
+	}
+
+	rdf := mustParse(t, Options{}, string(readFixture(t, "hatena_rdf.xml")))
+	if want := "https://b.hatena.ne.jp/alice/20240210#bookmark-4745611221"; rdf.Items[0].GUID != want {
+		t.Errorf("RDF GUID = %q, want rdf:about %q", rdf.Items[0].GUID, want)
+	}
+}
+
+func TestIsRDFFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		want bool
+	}{
+		{"rdf prefix", rdfFeed(), true},
+		{"default RDF namespace", string(readFixture(t, "rdf_default_namespace.xml")), true},
+		{"other prefix", `<r:RDF xmlns:r="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/>`, true},
+		{"RSS 1.0 namespace on root", `<feed xmlns="http://purl.org/rss/1.0/"/>`, true},
+		{"RSS 2.0", rssFeed(), false},
+		{"RSS 2.0 mentioning RDF in content", rssFeed(rssItem("https://example.com/", "The &lt;rdf:RDF&gt; element")), false},
+		{"Atom", `<feed xmlns="http://www.w3.org/2005/Atom"/>`, false},
+	}
+
+	p := newTestParser(Options{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.isRDFFormat([]byte(tt.xml)); got != tt.want {
+				t.Errorf("isRDFFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRSSFeedDefaultRDFNamespace(t *testing.T) {
+	data := mustParse(t, Options{}, string(readFixture(t, "rdf_default_namespace.xml")))
+
+	if data.Title != "alice's bookmarks" || len(data.Items) != 1 {
+		t.Fatalf("Title = %q, %d items, want the channel and one item", data.Title, len(data.Items))
+	}
+	item := data.Items[0]
+	if item.URL != "https://example.com/unprefixed" || item.BookmarkedAt != "2024-02-10T09:15:00+09:00" {
+		t.Errorf("item = %+v", item)
+	}
+	if want := []string{"rdf"}; !reflect.DeepEqual(item.Tags, want) {
+		t.Errorf("Tags = %q, want %q", item.Tags, want)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"Sat, 10 Feb 2024 09:15:00 +0900", "2024-02-10T09:15:00+09:00", false},
+		{"Sat, 10 Feb 2024 00:15:00 GMT", "2024-02-10T00:15:00Z", false},
+		{"Mon, 5 Feb 2024 09:15:00 +0900", "2024-02-05T09:15:00+09:00", false},
+		{"Mon, 5 Feb 2024 09:15:00 UTC", "2024-02-05T09:15:00Z", false},
+		{"Sat, 10 Feb 24 09:15:00 +0900", "2024-02-10T09:15:00+09:00", false},
+		{"Mon, 5 Feb 24 09:15:00 GMT", "2024-02-05T09:15:00Z", false},
+		{"Sun, 5 Feb 68 09:15:00 +0000", "2068-02-05T09:15:00Z", false},
+		{"Fri, 5 Feb 99 09:15:00 +0000", "1999-02-05T09:15:00Z", false},
+		{"10 Feb 24 09:15 +0900", "2024-02-10T09:15:00+09:00", false},
+		{"5 Feb 24 09:15 +0900", "2024-02-05T09:15:00+09:00", false},
+		{"2024-02-10T09:15:00+09:00", "2024-02-10T09:15:00+09:00", false},
+		{"2024-02-10 09:15:00", "2024-02-10T09:15:00Z", false},
+		{"last Tuesday", "", true},
+	}
+
+	p := newTestParser(Options{})
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := p.parseDate(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseDate() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseDate() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRSSFeedUnparseableDate(t *testing.T) {
+	data := mustParse(t, Options{}, rssFeed(rssItem("https://example.com/", "Example", `<pubDate>someday</pubDate>`)))
+
+	if len(data.Items) != 1 {
+		t.Fatalf("got %d items, want the item kept", len(data.Items))
+	}
+	if !data.Items[0].DateFallback {
+		t.Error("DateFallback = false, want true for an unparseable date")
+	}
+	if len(data.Warnings) != 1 || !strings.Contains(data.Warnings[0], "could not parse date: someday") {
+		t.Errorf("Warnings = %q", data.Warnings)
+	}
+}
+
+func TestParseRSSFeedTruncated(t *testing.T) {
+	fixture := readFixture(t, "hatena_rdf.xml")
+	truncated := fixture[:len(fixture)/2]
+
+	_, err := newTestParser(Options{}).ParseRSSFeed(context.Background(), truncated)
+
+	var mcpErr *types.MCPError
+	if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeParsing {
+		t.Fatalf("error = %v, want a parsing error", err)
+	}
+	if want := fmt.Sprintf("feed appears truncated (%d bytes read)", len(truncated)); mcpErr.Message != want {
+		t.Errorf("Message = %q, want %q", mcpErr.Message, want)
+	}
+	if details, _ := mcpErr.Details.(map[string]interface{}); details["truncated"] != true {
+		t.Errorf("Details = %v, want truncated: true", mcpErr.Details)
+	}
+	var syntaxErr *xml.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("error = %v, want the XML syntax error as its cause", err)
+	}
+}
+
+func TestParseRSSFeedMalformed(t *testing.T) {
+	_, err := newTestParser(Options{}).ParseRSSFeed(context.Background(), []byte(rssFeed(`<item><title>A</title></wrong></item>`)))
+
+	var mcpErr *types.MCPError
+	if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeParsing {
+		t.Fatalf("error = %v, want a parsing error", err)
+	}
+	if strings.Contains(mcpErr.Message, "truncated") {
+		t.Errorf("Message = %q, want a malformed feed reported as such", mcpErr.Message)
+	}
+}
+
+func TestParseRSSFeedTruncatedAtEveryByte(t *testing.T) {
+	fixture := readFixture(t, "hatena_rdf.xml")
+	p := newTestParser(Options{})
+
+	// Past the root start tag, every cut must be reported as truncation,
+	// including cuts inside the Japanese text
+	start := bytes.Index(fixture, []byte("<channel"))
+	end := bytes.LastIndex(fixture, []byte("</rdf:RDF>"))
+	for n := start; n < end; n++ {
+		_, err := p.ParseRSSFeed(context.Background(), fixture[:n])
+
+		var mcpErr *types.MCPError
+		if !errors.As(err, &mcpErr) {
+			t.Fatalf("cut at %d: error = %v, want a parsing error", n, err)
+		}
+		if details, _ := mcpErr.Details.(map[string]interface{}); details["truncated"] != true {
+			t.Fatalf("cut at %d: error = %v, want truncation reported", n, err)
+		}
+	}
+}
+
+func TestParseRSSFeedMaxTitleLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		title         string
+		maxLength     int
+		wantTitle     string
+		wantFullTitle string
+	}{
+		{"unlimited", "あいうえおかきくけこ", 0, "あいうえおかきくけこ", ""},
+		{"at the cap", "あいうえお", 5, "あいうえお", ""},
+		{"one rune over", "あいうえおか", 5, "あいうえ…", "あいうえおか"},
+		{"mixed widths", "Go言語とRSS", 4, "Go言…", "Go言語とRSS"},
+		{"cap of one", "日本", 1, "…", "日本"},
+		{"emoji", "🍣🍣🍣", 2, "🍣…", "🍣🍣🍣"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustParse(t, Options{MaxTitleLength: tt.maxLength}, rdfFeed(rdfItem("https://example.com/", tt.title)))
+
+			item := data.Items[0]
+			if item.Title != tt.wantTitle || item.FullTitle != tt.wantFullTitle {
+				t.Errorf("Title, FullTitle = %q, %q, want %q, %q", item.Title, item.FullTitle, tt.wantTitle, tt.wantFullTitle)
+			}
+			if !utf8.ValidString(item.Title) {
+				t.Errorf("Title %q is not valid UTF-8", item.Title)
+			}
+		})
+	}
+}
+
+func TestEntryURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://go.dev/blog/go1.22", "https://b.hatena.ne.jp/entry/s/go.dev/blog/go1.22"},
+		{"http://example.com/a?b=c", "https://b.hatena.ne.jp/entry/example.com/a?b=c"},
+		{" https://example.com/page#section ", "https://b.hatena.ne.jp/entry/s/example.com/page"},
+		{"https://example.com/", "https://b.hatena.ne.jp/entry/s/example.com/"},
+		{"ftp://example.com/file", ""},
+		{"/relative", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			if got := entryURL(tt.link); got != tt.want {
+				t.Errorf("entryURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRSSFeedEntryURL(t *testing.T) {
+	data := mustParse(t, Options{}, string(readFixture(t, "hatena_rdf.xml")))
+
+	if want := "https://b.hatena.ne.jp/entry/s/go.dev/blog/go1.22"; data.Items[0].EntryURL != want {
+		t.Errorf("EntryURL = %q, want %q", data.Items[0].EntryURL, want)
+	}
+}
+
+func TestParseRSSFeedDatePreference(t *testing.T) {
+	const (
+		dcDate  = `<dc:date>2024-02-10T09:15:00+09:00</dc:date>`
+		pubDate = `<pubDate>Fri, 09 Feb 2024 12:00:00 +0900</pubDate>`
+	)
+
+	tests := []struct {
+		name       string
+		preference string
+		children   []string
+		want       string
+	}{
+		{"default prefers dc:date", "", []string{pubDate, dcDate}, "2024-02-10T09:15:00+09:00"},
+		{"dc_first", DatePreferenceDCFirst, []string{pubDate, dcDate}, "2024-02-10T09:15:00+09:00"},
+		{"pubdate_first", DatePreferencePubDateFirst, []string{dcDate, pubDate}, "2024-02-09T12:00:00+09:00"},
+		{"pubdate_first without pubDate", DatePreferencePubDateFirst, []string{dcDate}, "2024-02-10T09:15:00+09:00"},
+		{"dc_first without dc:date", DatePreferenceDCFirst, []string{pubDate}, "2024-02-09T12:00:00+09:00"},
+		{"unparseable preferred date", DatePreferenceDCFirst, []string{`<dc:date>yesterday</dc:date>`, pubDate}, "2024-02-09T12:00:00+09:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustParse(t, Options{DatePreference: tt.preference}, rssFeed(rssItem("https://example.com/", "Example", tt.children...)))
+
+			item := data.Items[0]
+			if item.BookmarkedAt != tt.want || item.DateFallback {
+				t.Errorf("BookmarkedAt = %q (fallback %v), want %q", item.BookmarkedAt, item.DateFallback, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveLink(t *testing.T) {
+	tests := []struct {
+		name        string
+		channelLink string
+		link        string
+		want        string
+	}{
+		{"absolute path", "https://b.hatena.ne.jp/alice/bookmark", "/entry/1", "https://b.hatena.ne.jp/entry/1"},
+		{"relative path", "https://b.hatena.ne.jp/alice/bookmark", "entry/1", "https://b.hatena.ne.jp/alice/entry/1"},
+		{"protocol relative", "https://b.hatena.ne.jp/alice/bookmark", "//example.com/a", "https://example.com/a"},
+		{"already absolute", "https://b.hatena.ne.jp/alice/bookmark", "http://example.com/a", "http://example.com/a"},
+		{"surrounding space", "https://b.hatena.ne.jp/alice/bookmark", "  /entry/1 ", "https://b.hatena.ne.jp/entry/1"},
+		{"empty link", "https://b.hatena.ne.jp/alice/bookmark", "", ""},
+		{"relative channel link", "/alice/bookmark", "/entry/1", "/entry/1"},
+		{"no channel link", "", "/entry/1", "/entry/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveLink(tt.channelLink, tt.link); got != tt.want {
+				t.Errorf("resolveLink(%q, %q) = %q, want %q", tt.channelLink, tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRSSFeedRelativeLinks(t *testing.T) {
+	feeds := map[string]string{
+		"rdf": rdfFeed(rdfItem("/entry/1", "relative"), rdfItem("https://example.com/a", "absolute")),
+		"rss": rssFeed(rssItem("/entry/1", "relative"), rssItem("https://example.com/a", "absolute")),
+	}
+
+	for name, feed := range feeds {
+		t.Run(name, func(t *testing.T) {
+			data := mustParse(t, Options{}, feed)
+
+			var got []string
+			for _, item := range data.Items {
+				got = append(got, item.URL)
+			}
+			want := []string{"https://b.hatena.ne.jp/entry/1", "https://example.com/a"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("URLs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestExtractCommentMinLength(t *testing.T) {
+	tests := []struct {
+		name        string
+		minLength   int
+		description string
+		want        string
+	}{
+		{"default keeps one character", 0, "w", "w"},
+		{"below minimum", 3, "ok", ""},
+		{"at minimum", 3, "良い記事", "良い記事"},
+		{"multibyte counted as runes", 4, "良い記事", "良い記事"},
+		{"multibyte below minimum", 5, "良い記事", ""},
+		{"markup not counted", 3, "<b>ok</b>", ""},
+		{"surrounding space not counted", 3, "  ok  ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(Options{MinCommentLength: tt.minLength})
+			if got := p.extractComment(tt.description); got != tt.want {
+				t.Errorf("extractComment(%q) = %q, want %q", tt.description, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRSSFeedMinCommentLength(t *testing.T) {
+	data := mustParse(t, Options{MinCommentLength: 2}, rdfFeed(
+		rdfItem("https://example.com/a", "noise", "<description>w</description>"),
+		rdfItem("https://example.com/b", "kept", "<description>good</description>"),
+	))
+
+	if len(data.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(data.Items))
+	}
+	if got := data.Items[0].Comment; got != "" {
+		t.Errorf("short comment = %q, want it dropped", got)
+	}
+	if got := data.Items[1].Comment; got != "good" {
+		t.Errorf("comment = %q, want %q", got, "good")
+	}
+}
+
+func TestParseRSSFeedUnsupportedCharset(t *testing.T) {
+	for _, charset := range []string{"EUC-JP", "x-unknown"} {
+		t.Run(charset, func(t *testing.T) {
+			content := strings.Replace(rdfFeed(rdfItem("https://example.com/a", "A")), `encoding="UTF-8"`, `encoding="`+charset+`"`, 1)
+
+			_, err := newTestParser(Options{}).ParseRSSFeed(context.Background(), []byte(content))
+
+			var mcpErr *types.MCPError
+			if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeParsing {
+				t.Fatalf("error = %v, want a PARSING_ERROR", err)
+			}
+			if want := "unsupported or corrupt character encoding: " + charset; mcpErr.Message != want {
+				t.Errorf("Message = %q, want %q", mcpErr.Message, want)
+			}
+			details, _ := mcpErr.Details.(map[string]interface{})
+			if got := details["charset"]; got != charset {
+				t.Errorf("Details[charset] = %v, want %q", got, charset)
+			}
+		})
+	}
+}
+
+func TestUnsupportedCharset(t *testing.T) {
+	if _, ok := unsupportedCharset(errors.New("XML syntax error on line 3: unexpected EOF")); ok {
+		t.Error("unsupportedCharset() reported a syntax error as an encoding failure")
+	}
+}
+
+func TestParseRSSFeedUnrecognizedFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		root string
+	}{
+		{"Atom", `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>alice</title>
+<entry><title>A</title><link href="https://example.com/a"/><updated>2024-02-10T00:00:00Z</updated></entry>
+</feed>`, "feed"},
+		{"HTML", `<html><head><title>Hatena</title></head><body></body></html>`, "html"},
+		{"RSS without channel", `<rss version="2.0"></rss>`, "rss"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestParser(Options{}).ParseRSSFeed(context.Background(), []byte(tt.xml))
+
+			var mcpErr *types.MCPError
+			if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeParsing || mcpErr.Message != "feed format not recognized" {
+				t.Fatalf("error = %v, want PARSING_ERROR \"feed format not recognized\"", err)
+			}
+			details, _ := mcpErr.Details.(map[string]interface{})
+			if got := details["root_element"]; got != tt.root {
+				t.Errorf("Details[root_element] = %v, want %q", got, tt.root)
+			}
+		})
+	}
+}
+
+func TestParseRSSFeedAlternateFormat(t *testing.T) {
+	// The RSS 1.0 namespace on the root makes this RSS 2.0 feed look like
+	// RDF, so it only parses through the alternate parser
+	content := strings.Replace(rssFeed(rssItem("https://example.com/a", "A")),
+		`<rss version="2.0"`, `<rss version="2.0" xmlns:rss1="http://purl.org/rss/1.0/"`, 1)
+
+	p := newTestParser(Options{})
+	if !p.isRDFFormat([]byte(content)) {
+		t.Fatal("feed is not detected as RDF")
+	}
+
+	data := mustParse(t, Options{}, content)
+	if data.Title != "alice's bookmarks" || len(data.Items) != 1 || data.Items[0].URL != "https://example.com/a" {
+		t.Errorf("data = %+v, want the RSS 2.0 channel and item", data)
+	}
+}
+
+func TestParseRSSFeedMissingLinkPolicy(t *testing.T) {
+	feeds := map[string]string{
+		"rdf": rdfFeed(rdfItem("https://example.com/a", "linked"), rdfItem("", "unlinked"), rdfItem("  ", "blank")),
+		"rss": rssFeed(rssItem("https://example.com/a", "linked"), rssItem("", "unlinked"), rssItem("  ", "blank")),
+	}
+
+	tests := []struct {
+		policy       string
+		wantTitles   []string
+		wantWarnings int
+		wantErr      bool
+	}{
+		{"", []string{"linked"}, 2, false},
+		{MissingLinkSkip, []string{"linked"}, 2, false},
+		{MissingLinkKeep, []string{"linked", "unlinked", "blank"}, 0, false},
+		{MissingLinkError, nil, 0, true},
+	}
+
+	for name, feed := range feeds {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.policy, func(t *testing.T) {
+				data, err := newTestParser(Options{MissingLinkPolicy: tt.policy}).ParseRSSFeed(context.Background(), []byte(feed))
+				if tt.wantErr {
+					var mcpErr *types.MCPError
+					if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeParsing || mcpErr.Message != `item "unlinked" has no link` {
+						t.Errorf("error = %v, want a PARSING_ERROR naming the unlinked item", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ParseRSSFeed() error = %v", err)
+				}
+
+				var titles []string
+				for _, item := range data.Items {
+					titles = append(titles, item.Title)
+				}
+				if !reflect.DeepEqual(titles, tt.wantTitles) {
+					t.Errorf("items = %q, want %q", titles, tt.wantTitles)
+				}
+				if len(data.Warnings) != tt.wantWarnings {
+					t.Errorf("warnings = %q, want %d", data.Warnings, tt.wantWarnings)
+				}
+				if data.TotalItems != 3 {
+					t.Errorf("TotalItems = %d, want 3", data.TotalItems)
+				}
+			})
+		}
+	}
+}
+
+func TestUntitledTitle(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/blog/post", "example.com/blog/post"},
+		{"https://www.example.com/blog/", "example.com/blog"},
+		{"https://example.com/", "example.com"},
+		{"https://example.com:8080/a?q=1#top", "example.com/a"},
+		{"/relative/path", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := untitledTitle(tt.link); got != tt.want {
+			t.Errorf("untitledTitle(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestParseRSSFeedDeriveUntitled(t *testing.T) {
+	tests := []struct {
+		name         string
+		derive       bool
+		link         string
+		title        string
+		wantTitle    string
+		wantRawTitle string
+	}{
+		{"title equals link", true, "https://www.example.com/blog/post/", "https://www.example.com/blog/post/", "example.com/blog/post", "https://www.example.com/blog/post/"},
+		{"empty title", true, "https://example.com/a", "", "example.com/a", ""},
+		{"real title", true, "https://example.com/a", "An article", "An article", ""},
+		{"disabled", false, "https://example.com/a", "https://example.com/a", "https://example.com/a", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for format, feed := range map[string]string{
+				"rdf": rdfFeed(rdfItem(tt.link, tt.title)),
+				"rss": rssFeed(rssItem(tt.link, tt.title)),
+			} {
+				data := mustParse(t, Options{DeriveUntitled: tt.derive}, feed)
+
+				item := data.Items[0]
+				if item.Title != tt.wantTitle || item.RawTitle != tt.wantRawTitle {
+					t.Errorf("%s: Title, RawTitle = %q, %q, want %q, %q", format, item.Title, item.RawTitle, tt.wantTitle, tt.wantRawTitle)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"hatena-bookmark-mcp/internal/types"
+	"hatena-bookmark-mcp/internal/utils"
+)
+
+// StreamRSSFeed parses RSS XML content like ParseRSSFeed, but decodes items
+// one at a time and passes each bookmark to emit as soon as it is converted,
+// instead of building the whole document in memory. Parsing stops early if
+// ctx is canceled or emit returns an error. The returned data has no Items.
+// The format is detected and retried like in ParseRSSFeed, but relative item
+// links are only resolved against a channel link that precedes the items.
+func (p *RSSParser) StreamRSSFeed(ctx context.Context, xmlContent []byte, emit func(types.BookmarkItem) error) (*types.ParsedRSSData, error) {
+	p = &RSSParser{logger: utils.LoggerFromContext(ctx, p.logger), options: p.options}
+
+	return p.parseDetected(xmlContent, func(rdf bool) (*types.ParsedRSSData, error) {
+		return p.streamFeed(ctx, xmlContent, rdf, emit)
+	})
+}
+
+// streamFeed walks the document with xml.Decoder, decoding the channel
+// title and link and each item individually. Elements are matched by local
+// name, as xml.Unmarshal does for the RSS and RDF types.
+func (p *RSSParser) streamFeed(ctx context.Context, xmlContent []byte, rdf bool, emit func(types.BookmarkItem) error) (*types.ParsedRSSData, error) {
+	feedFormat, rootName := "RSS", "rss"
+	if rdf {
+		feedFormat, rootName = "RDF", "RDF"
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(xmlContent))
+	data := &types.ParsedRSSData{}
+	var warnings warningList
+	var channelLink string
+
+	// failed wraps a decoding error like the unmarshal path does
+	failed := func(err error) (*types.ParsedRSSData, error) {
+		p.logger.Error(fmt.Sprintf("Failed to stream %s XML", feedFormat), "error", err)
+		return nil, p.unmarshalError(feedFormat, err, xmlContent)
+	}
+
+	// emitItem passes a converted item on, counting it even if skipped
+	emitItem := func(bookmark types.BookmarkItem, ok bool, err error) error {
+		data.TotalItems++
+		if err != nil || !ok {
+			return err
+		}
+		data.ItemCount++
+		return emit(bookmark)
+	}
+
+	depth := 0
+	sawRoot, inChannel := false, false
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return failed(err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				sawRoot = true
+				if t.Name.Local != rootName || (rdf && t.Name.Space != rdfNamespace) {
+					return failed(xml.UnmarshalError(fmt.Sprintf("expected element type <%s> but have <%s>", rootName, t.Name.Local)))
+				}
+				continue
+			}
+
+			var decodeErr, emitErr error
+			switch {
+			case rdf && depth == 2 && t.Name.Local == "channel":
+				var channel types.RDFChannel
+				decodeErr = decoder.DecodeElement(&channel, &t)
+				data.Title, channelLink = channel.Title, channel.Link
+			case rdf && depth == 2 && t.Name.Local == "item":
+				var item types.RDFItem
+				if decodeErr = decoder.DecodeElement(&item, &t); decodeErr == nil {
+					emitErr = emitItem(p.convertRDFItem(item, channelLink, &warnings))
+				}
+			case !rdf && depth == 2 && t.Name.Local == "channel":
+				inChannel = true
+				continue
+			case inChannel && depth == 3 && t.Name.Local == "title":
+				decodeErr = decoder.DecodeElement(&data.Title, &t)
+			case inChannel && depth == 3 && t.Name.Local == "link":
+				decodeErr = decoder.DecodeElement(&channelLink, &t)
+			case inChannel && depth == 3 && t.Name.Local == "item":
+				var item types.Item
+				if decodeErr = decoder.DecodeElement(&item, &t); decodeErr == nil {
+					emitErr = emitItem(p.convertRSSItem(item, channelLink, &warnings))
+				}
+			default:
+				decodeErr = decoder.Skip()
+			}
+			// The element, including its end tag, has been consumed
+			depth--
+
+			if decodeErr != nil {
+				return failed(decodeErr)
+			}
+			if emitErr != nil {
+				return nil, emitErr
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				inChannel = false
+			}
+			depth--
+		}
+	}
+
+	if !sawRoot {
+		return failed(io.EOF)
+	}
+
+	p.logger.Info(fmt.Sprintf("Successfully streamed %s feed", feedFormat),
+		"title", data.Title,
+		"item_count", data.ItemCount)
+
+	data.Warnings = warnings
+	return data, nil
+}
@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// streamAll streams content, collecting the emitted items into the result
+func streamAll(t testing.TB, options Options, content []byte) (*types.ParsedRSSData, error) {
+	t.Helper()
+
+	items := []types.BookmarkItem{}
+	data, err := newTestParser(options).StreamRSSFeed(context.Background(), content, func(item types.BookmarkItem) error {
+		items = append(items, item)
+		return nil
+	})
+	if data != nil {
+		data.Items = items
+	}
+	return data, err
+}
+
+// largeFeed returns an RDF feed of n dated and tagged items
+func largeFeed(n int) []byte {
+	items := make([]string, n)
+	for i := range items {
+		link := fmt.Sprintf("https://example.com/articles/%d", i)
+		items[i] = rdfItem(link, fmt.Sprintf("Article %d", i),
+			"<description>a comment worth keeping</description>",
+			fmt.Sprintf("<dc:date>2024-02-10T09:%02d:%02d+09:00</dc:date>", i/60%60, i%60),
+			"<dc:subject>go</dc:subject><dc:subject>xml</dc:subject>",
+			fmt.Sprintf("<hatena:bookmarkcount>%d</hatena:bookmarkcount>", i%500))
+	}
+	return []byte(rdfFeed(items...))
+}
+
+func TestStreamRSSFeedMatchesParseRSSFeed(t *testing.T) {
+	feeds := map[string][]byte{
+		"hatena fixture":             readFixture(t, "hatena_rdf.xml"),
+		"default namespace fixture":  readFixture(t, "rdf_default_namespace.xml"),
+		"variant namespaces fixture": readFixture(t, "rdf_variant_namespaces.xml"),
+		"large RDF":                  largeFeed(200),
+		"RSS 2.0": []byte(rssFeed(
+			rssItem("https://example.com/a", "A", "<pubDate>Sat, 10 Feb 2024 09:00:00 +0900</pubDate><dc:subject>go</dc:subject>"),
+			rssItem("/relative", "Relative", "<pubDate>Sat, 10 Feb 2024 08:00:00 +0900</pubDate>"),
+			rssItem("", "No link", "<pubDate>Sat, 10 Feb 2024 07:00:00 +0900</pubDate>"),
+		)),
+		// The RSS 1.0 namespace makes this RSS 2.0 feed look like RDF
+		"RSS 2.0 detected as RDF": []byte(strings.Replace(rssFeed(rssItem("https://example.com/a", "A", "<pubDate>Sat, 10 Feb 2024 09:00:00 +0900</pubDate>")),
+			`<rss version="2.0"`, `<rss version="2.0" xmlns:rss1="http://purl.org/rss/1.0/"`, 1)),
+		"RDF with skipped item": []byte(rdfFeed(
+			rdfItem("https://example.com/a", "A", "<dc:date>2024-02-10T09:00:00+09:00</dc:date>"),
+			rdfItem("", "No link", "<dc:date>2024-02-10T08:00:00+09:00</dc:date>"),
+		)),
+	}
+	options := Options{MaxTagsPerItem: 1, MaxTitleLength: 10}
+
+	for name, content := range feeds {
+		t.Run(name, func(t *testing.T) {
+			want, err := newTestParser(options).ParseRSSFeed(context.Background(), content)
+			if err != nil {
+				t.Fatalf("ParseRSSFeed() error = %v", err)
+			}
+			got, err := streamAll(t, options, content)
+			if err != nil {
+				t.Fatalf("StreamRSSFeed() error = %v", err)
+			}
+
+			if got.Title != want.Title || got.ItemCount != want.ItemCount || got.TotalItems != want.TotalItems {
+				t.Errorf("streamed title %q, %d of %d items, want %q, %d of %d",
+					got.Title, got.ItemCount, got.TotalItems, want.Title, want.ItemCount, want.TotalItems)
+			}
+			if !reflect.DeepEqual(got.Items, want.Items) {
+				t.Errorf("streamed items differ:\n got %+v\nwant %+v", got.Items, want.Items)
+			}
+			if !reflect.DeepEqual(got.Warnings, want.Warnings) {
+				t.Errorf("streamed warnings = %q, want %q", got.Warnings, want.Warnings)
+			}
+		})
+	}
+}
+
+func TestStreamRSSFeedErrorsMatchParseRSSFeed(t *testing.T) {
+	content := largeFeed(3)
+	feeds := map[string][]byte{
+		"truncated": content[:len(content)/2],
+		"malformed": []byte(rdfFeed(rdfItem("https://example.com/a", "A", "<dc:date>"))),
+		"empty":     nil,
+		"Atom": []byte(`<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>alice</title></feed>`),
+		"RSS without channel": []byte(`<rss version="2.0"></rss>`),
+	}
+
+	for name, content := range feeds {
+		t.Run(name, func(t *testing.T) {
+			_, parseErr := newTestParser(Options{}).ParseRSSFeed(context.Background(), content)
+			_, streamErr := streamAll(t, Options{}, content)
+
+			var want, got *types.MCPError
+			if !errors.As(parseErr, &want) || !errors.As(streamErr, &got) {
+				t.Fatalf("errors = %v and %v, want MCP errors", parseErr, streamErr)
+			}
+			if got.Code != want.Code || got.Message != want.Message {
+				t.Errorf("streamed error = %s %q, want %s %q", got.Code, got.Message, want.Code, want.Message)
+			}
+		})
+	}
+}
+
+func TestStreamRSSFeedStopsEarly(t *testing.T) {
+	content := largeFeed(10)
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		emitted := 0
+		_, err := newTestParser(Options{}).StreamRSSFeed(ctx, content, func(types.BookmarkItem) error {
+			emitted++
+			if emitted == 2 {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) || emitted != 2 {
+			t.Errorf("error = %v after %d items, want context.Canceled after 2", err, emitted)
+		}
+	})
+
+	t.Run("emit error", func(t *testing.T) {
+		stop := errors.New("enough")
+		emitted := 0
+		_, err := newTestParser(Options{}).StreamRSSFeed(context.Background(), content, func(types.BookmarkItem) error {
+			emitted++
+			return stop
+		})
+		if !errors.Is(err, stop) || emitted != 1 {
+			t.Errorf("error = %v after %d items, want the emit error after 1", err, emitted)
+		}
+	})
+}
+
+func TestStreamRSSFeedRelativeLinkBeforeChannel(t *testing.T) {
+	// Only a channel link that precedes the items can be streamed
+	content := strings.Replace(rssFeed(rssItem("/relative", "Relative")),
+		"<link>https://b.hatena.ne.jp/alice/bookmark</link>\n", "", 1)
+	content = strings.Replace(content, "</channel>", "<link>https://b.hatena.ne.jp/alice/bookmark</link></channel>", 1)
+
+	data, err := streamAll(t, Options{}, []byte(content))
+	if err != nil {
+		t.Fatalf("StreamRSSFeed() error = %v", err)
+	}
+	if len(data.Items) != 1 || data.Items[0].URL != "/relative" {
+		t.Errorf("items = %+v, want the link left relative", data.Items)
+	}
+}
+
+func BenchmarkParseRSSFeed(b *testing.B) {
+	content := largeFeed(5000)
+	p := newTestParser(Options{})
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(content)))
+	for i := 0; i < b.N; i++ {
+		if _, err := p.ParseRSSFeed(context.Background(), content); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamRSSFeed(b *testing.B) {
+	content := largeFeed(5000)
+	p := newTestParser(Options{})
+	discard := func(types.BookmarkItem) error { return nil }
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(content)))
+	for i := 0; i < b.N; i++ {
+		if _, err := p.StreamRSSFeed(context.Background(), content, discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestWSSEHeader(t *testing.T) {
+	nonce := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	now := time.Date(2024, 2, 10, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+
+	got := wsseHeader("alice", "secret-key", nonce, now)
+
+	want := `UsernameToken Username="alice", PasswordDigest="JKEvoBbquKv8W3FirEP5irgqSnw=", Nonce="AAECAwQFBgcICQoLDA0ODw==", Created="2024-02-10T00:00:00Z"`
+	if got != want {
+		t.Errorf("wsseHeader() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAuthHeaders(t *testing.T) {
+	nonce := bytes.Repeat([]byte{0}, wsseNonceLength)
+	now := time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		options Options
+		want    http.Header
+	}{
+		{"none", Options{}, http.Header{}},
+		{"bearer", Options{AuthMode: AuthModeBearer, AuthToken: "token"}, http.Header{
+			"Authorization": {"Bearer token"},
+		}},
+		{"wsse", Options{AuthMode: AuthModeWSSE, AuthUsername: "alice", AuthToken: "secret-key"}, http.Header{
+			"Authorization": {`WSSE profile="UsernameToken"`},
+			"X-Wsse":        {wsseHeader("alice", "secret-key", nonce, now)},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			got := http.Header{}
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, name := range []string{"Authorization", "X-Wsse"} {
+					if value := r.Header.Get(name); value != "" {
+						got.Set(name, value)
+					}
+				}
+				w.Write([]byte(rdfFeed()))
+			}
+			tt.options.NonceSource = bytes.NewReader(nonce)
+			tt.options.Clock = func() time.Time { return now }
+			s := newTestService(t, http.HandlerFunc(handler), tt.options)
+
+			mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(got) != len(tt.want) {
+				t.Fatalf("headers = %v, want %v", got, tt.want)
+			}
+			for name := range tt.want {
+				if got.Get(name) != tt.want.Get(name) {
+					t.Errorf("%s = %q, want %q", name, got.Get(name), tt.want.Get(name))
+				}
+			}
+		})
+	}
+}
@@ -2,146 +2,1488 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
+	"hatena-bookmark-mcp/internal/analysis"
 	"hatena-bookmark-mcp/internal/parser"
 	"hatena-bookmark-mcp/internal/types"
+	"hatena-bookmark-mcp/internal/utils"
 )
 
+// Limits for the number of tags get_tag_counts returns
+const (
+	DefaultTagCountsTopN = 50
+	MaxTagCountsTopN     = 1000
+)
+
+// jst is Japan Standard Time, the timezone Hatena uses for dates
+var jst = time.FixedZone("JST", 9*60*60)
+
+// Limits for the number of suggested tags
+const (
+	DefaultSuggestTagsTopN = 10
+	MaxSuggestTagsTopN     = 100
+)
+
+// MaxRawXMLBytes caps the size of raw XML input to GetBookmarks
+const MaxRawXMLBytes = 5 << 20
+
+// DefaultTopDomains is the number of most frequent domains get_domain_count returns
+const DefaultTopDomains = 5
+
+// Limits on the number of domains get_recent_domains returns
+const (
+	DefaultRecentDomains = 10
+	MaxRecentDomains     = 50
+)
+
+// Limits on the sections get_user_overview returns
+const (
+	DefaultOverviewTopN        = 10
+	DefaultOverviewRecentCount = 5
+	MaxOverviewRecentCount     = 50
+)
+
+// Limits on the pages compare_users fetches per user
+const (
+	DefaultComparePages = 3
+	MaxComparePages     = 10
+)
+
+// Grouping keys reported by get_domain_count
+const (
+	GroupByHost = "host"
+	GroupByPath = "path"
+)
+
+// Limits for comment keyword analysis
+const (
+	DefaultCommentKeywordsTopN      = 20
+	MaxCommentKeywordsTopN          = 100
+	DefaultCommentKeywordsMinLength = 2
+)
+
+// maxLoggedBodyBytes limits how much of a response body is logged
+const maxLoggedBodyBytes = 2048
+
 // BookmarkService handles Hatena Bookmark API interactions
 type BookmarkService struct {
-	baseURL    string
-	logger     *slog.Logger
-	client     *http.Client
-	rssParser  *parser.RSSParser
+	baseURL   string
+	logger    *slog.Logger
+	client    *http.Client
+	rssParser *parser.RSSParser
+	validator *utils.Validator
+	options   Options
+
+	// resolveClient resolves shortened URLs without following redirects
+	// off shortener hosts
+	resolveClient *http.Client
+
+	// requestSlots limits in-flight HTTP requests across all operations
+	requestSlots chan struct{}
+
+	// inflight coalesces concurrent fetches of the same feed URL
+	inflight flightGroup
+
+	// cache holds GetBookmarks fetch results by parameters, or is nil if
+	// caching is disabled
+	cache *utils.Cache
+
+	// cacheNamespace isolates this service's entries in a shared cache
+	cacheNamespace string
+
+	// stopCleanup stops the cache cleanup goroutine
+	stopCleanup func()
+
+	// refreshSlots limits background refreshes of hot cache entries
+	refreshSlots chan struct{}
+
+	// refreshes tracks running background refreshes
+	refreshes sync.WaitGroup
+}
+
+// maxConcurrentRefreshes caps background refreshes of hot cache entries.
+// Their requests also count against MaxConcurrentRequests.
+const maxConcurrentRefreshes = 2
+
+// cachedFetch is a GetBookmarks fetch result, as kept in the cache. A
+// negative entry for a feed that does not exist holds only err.
+type cachedFetch struct {
+	data        *types.ParsedRSSData
+	truncatedBy string
+	lastPage    int
+	morePages   bool
+
+	err error
+}
+
+// clone copies the fetch result so that its bookmarks can be modified
+// without affecting the original
+func (f *cachedFetch) clone() *cachedFetch {
+	clone := *f
+	clone.data = cloneParsedData(f.data)
+	return &clone
+}
+
+// NewBookmarkService creates a new bookmark service instance
+func NewBookmarkService(logger *slog.Logger) *BookmarkService {
+	return NewBookmarkServiceWithOptions(logger, Options{})
+}
+
+// NewBookmarkServiceWithOptions creates a new bookmark service instance with the given options
+func NewBookmarkServiceWithOptions(logger *slog.Logger, options Options) *BookmarkService {
+	options = options.withDefaults()
+
+	cache := options.Cache
+	stopCleanup := func() {}
+	if cache == nil && options.CacheTTL > 0 {
+		cache = utils.NewCacheWithOptions(utils.CacheOptions{
+			TTL:         options.CacheTTL,
+			NegativeTTL: options.CacheNegativeTTL,
+			Clock:       options.Clock,
+		})
+		stopCleanup = cache.StartCleanup(options.CacheTTL, logger)
+	}
+
+	return &BookmarkService{
+		baseURL: options.BaseURL,
+		logger:  logger,
+		client:    newHTTPClient(options),
+		rssParser: parser.NewRSSParserWithOptions(logger, options.parserOptions()),
+		validator: utils.NewValidator(),
+		options:   options,
+
+		resolveClient:  newResolveClient(options),
+		requestSlots:   make(chan struct{}, options.MaxConcurrentRequests),
+		cache:          cache,
+		cacheNamespace: options.cacheNamespace(),
+		stopCleanup:    stopCleanup,
+		refreshSlots:   make(chan struct{}, maxConcurrentRefreshes),
+	}
+}
+
+// Close stops the service's background work, such as cache cleanup. The
+// service must not be used afterwards.
+func (s *BookmarkService) Close() {
+	s.stopCleanup()
+}
+
+// GetBookmarks retrieves bookmarks from Hatena Bookmark RSS feed
+func (s *BookmarkService) GetBookmarks(ctx context.Context, params types.GetHatenaBookmarksParams) (*types.GetHatenaBookmarksResponse, error) {
+	s.log(ctx).Info("Getting bookmarks", 
+		"username", params.Username,
+		"tag", params.Tag,
+		"date", params.Date,
+		"url", params.URL,
+		"page", params.Page)
+
+	params, err := s.prepareParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse RSS content from raw XML, or fetch one or all pages. The fetch
+	// is cloned since the steps below modify bookmarks in place.
+	fetch, _, err := s.loadFetch(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	fetch = fetch.clone()
+	parsedData, truncatedBy := fetch.data, fetch.truncatedBy
+	lastPage, morePages := fetch.lastPage, fetch.morePages
+
+	// Build response
+	response := &types.GetHatenaBookmarksResponse{
+		SchemaVersion: types.SchemaVersion,
+		User:          params.Username,
+		Page:          s.getPageOrDefault(params.Page),
+		TotalCount:    len(parsedData.Items),
+		Bookmarks:     parsedData.Items,
+		Warnings:      parsedData.Warnings,
+
+		Truncated:   truncatedBy != "",
+		TruncatedBy: truncatedBy,
+		Errors:      parsedData.PageErrors,
+	}
+
+	// A non-empty page may be followed by another
+	if params.RawXML == "" && !params.FetchAll && len(parsedData.Items) > 0 && morePages {
+		response.NextCursor = encodeCursor(params, lastPage)
+	}
+
+	// Report whether paging found enough bookmarks
+	if params.MinResults > 0 {
+		met := len(parsedData.Items) >= params.MinResults
+		response.MinResultsMet = &met
+	}
+
+	// Collapse re-bookmarked duplicates if requested
+	if params.CollapseDuplicateTitles {
+		response.Bookmarks = collapseDuplicateTitles(response.Bookmarks)
+		response.TotalCount = len(response.Bookmarks)
+	}
+
+	// Count the bookmarks whose date is the parse time, not a feed date
+	response.DateFallbackCount = countDateFallbacks(response.Bookmarks)
+
+	// Lowercase tags if requested, before sorting them
+	if params.LowercaseTags {
+		lowercaseTags(response.Bookmarks)
+	}
+
+	// Sort tags within each bookmark if requested
+	if params.SortTags {
+		sortTags(response.Bookmarks)
+	}
+
+	// Resolve shortened URLs if requested
+	if params.ResolveShortURLs {
+		response.ChecksLimited = s.resolveShortURLs(ctx, response.Bookmarks)
+	}
+
+	// Score and sort bookmarks if requested
+	if params.IncludeScore || params.SortBy == SortByScore {
+		scoreBookmarks(response.Bookmarks, s.options.Clock())
+	}
+	switch params.SortBy {
+	case SortByScore:
+		sortByScore(response.Bookmarks)
+	case SortByDate:
+		sortByDate(response.Bookmarks)
+	}
+
+	// Add UNIX timestamps before formatting can change BookmarkedAt
+	if params.IncludeEpoch {
+		addEpochs(response.Bookmarks)
+	}
+
+	// Group bookmarks by date if requested
+	if params.GroupByDate {
+		response.DateGroups = groupByDate(response.Bookmarks)
+		response.Bookmarks = nil
+	}
+
+	// Attach response headers for troubleshooting if requested
+	if params.IncludeHeaders {
+		response.DebugHeaders = parsedData.ResponseHeaders
+	}
+
+	// Format timestamps last, since sorting and grouping parse them
+	if params.DateFormat != "" || params.Timezone != "" {
+		// Both were checked by validateParams
+		layout, _ := dateLayout(params.DateFormat)
+		location, _ := dateLocation(params.Timezone)
+
+		formatDates(response.Bookmarks, layout, location)
+		for i := range response.DateGroups {
+			formatDates(response.DateGroups[i].Bookmarks, layout, location)
+		}
+	}
+
+	// Nest bookmarks by hierarchical tag if requested; the tree holds copies,
+	// so it is built once the bookmarks are final
+	if params.TagTree {
+		separator := params.TagSeparator
+		if separator == "" {
+			separator = DefaultTagSeparator
+		}
+		response.TagTree, response.Bookmarks = buildTagTree(response.Bookmarks, separator)
+	}
+
+	// Add filters if any were applied; Filtered is always set so that
+	// clients can tell "no filter" from "filter matched nothing"
+	if params.Tag != "" || params.Date != "" || params.URL != "" {
+		response.Filtered = true
+		response.Filters = &types.FilterParams{
+			Tag:  params.Tag,
+			Date: params.Date,
+			URL:  params.URL,
+		}
+	}
+
+	// Treat an empty result as an error if requested; this runs after all
+	// filters so a filtered-to-empty result also triggers it
+	if params.ErrorOnEmpty && response.TotalCount == 0 {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeAPI,
+			Message: "no bookmarks found",
+			Details: map[string]interface{}{
+				"username": params.Username,
+				"page":     response.Page,
+			},
+		}
+	}
+
+	s.log(ctx).Info("Successfully retrieved bookmarks", 
+		"username", params.Username,
+		"count", len(parsedData.Items))
+
+	return response, nil
+}
+
+// fetchBookmarks parses params.RawXML, or fetches the page or pages params
+// select, and returns the result before any of the response options apply
+func (s *BookmarkService) fetchBookmarks(ctx context.Context, params types.GetHatenaBookmarksParams) (*cachedFetch, error) {
+	fetch := &cachedFetch{lastPage: s.getPageOrDefault(params.Page), morePages: true}
+
+	var err error
+	switch {
+	case params.RawXML != "":
+		fetch.data, err = s.parseFeed(ctx, []byte(params.RawXML))
+		if err == nil {
+			s.applyTagAliases(fetch.data.Items)
+		}
+	case params.FetchAll:
+		fetch.data, fetch.truncatedBy, err = s.fetchAllPages(ctx, params, true)
+	case params.MinResults > 0:
+		fetch.data, fetch.lastPage, fetch.morePages, err = s.fetchMinResults(ctx, params)
+	default:
+		// Build request URL
+		var requestURL string
+		requestURL, err = s.buildRequestURL(params)
+		if err != nil {
+			return nil, err
+		}
+		s.log(ctx).Debug("Built request URL", "url", requestURL)
+
+		fetch.data, err = s.fetchAndParse(ctx, requestURL)
+		if err == nil && params.AnnotatePage {
+			annotatePage(fetch.data.Items, s.getPageOrDefault(params.Page))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// An exact URL filter that matched nothing is often a non-canonical URL
+	if params.URL != "" && len(fetch.data.Items) == 0 {
+		if hint := s.urlFilterHint(ctx, params); hint != "" {
+			fetch.data.Warnings = append(fetch.data.Warnings, hint)
+		}
+	}
+
+	return fetch, nil
+}
+
+// prepareParams normalizes params, resumes from their cursor if given and
+// validates the result
+func (s *BookmarkService) prepareParams(params types.GetHatenaBookmarksParams) (types.GetHatenaBookmarksParams, error) {
+	// Normalize the username and URL filter before validation and use
+	params.Username = strings.TrimSpace(params.Username)
+	params.URL = normalizeURLFilter(strings.TrimSpace(params.URL))
+
+	// Resume from a cursor's filters and page if given
+	if params.Cursor != "" {
+		var err error
+		if params, err = applyCursor(params); err != nil {
+			return params, err
+		}
+	}
+
+	if err := s.validateParams(params); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+
+// loadFetch returns a recent fetch for params from the cache unless
+// bypassed, and otherwise fetches and caches it. It reports whether the
+// result came from the cache. The result is shared with the cache and must
+// be cloned before it is modified.
+func (s *BookmarkService) loadFetch(ctx context.Context, params types.GetHatenaBookmarksParams) (*cachedFetch, bool, error) {
+	cacheKey := ""
+	if s.cache != nil && params.RawXML == "" {
+		cacheKey = utils.GenerateCacheKey(s.cacheNamespace, params)
+	}
+	if cacheKey != "" && !params.NoCache {
+		if value, negative, ok := s.cache.Lookup(cacheKey); ok {
+			fetch := value.(*cachedFetch)
+			s.log(ctx).Debug("Serving bookmarks from cache", "username", params.Username, "negative", negative)
+			s.refreshAhead(ctx, cacheKey, params)
+			return fetch, true, fetch.err
+		}
+	}
+
+	fetch, err := s.fetchBookmarks(ctx, params)
+	s.storeFetch(ctx, cacheKey, fetch, err)
+	return fetch, false, err
+}
+
+// refreshAhead refetches a hot cache entry in the background if it is
+// about to expire, leaving the caller to be served the cached result. The
+// refresh is skipped when refreshSlots are all taken; a failed refresh
+// leaves the entry to expire as usual.
+func (s *BookmarkService) refreshAhead(ctx context.Context, key string, params types.GetHatenaBookmarksParams) {
+	if s.options.CacheRefreshAhead <= 0 || !s.cache.ClaimRefresh(key, s.options.CacheRefreshAhead) {
+		return
+	}
+
+	select {
+	case s.refreshSlots <- struct{}{}:
+	default:
+		s.cache.ReleaseRefresh(key)
+		return
+	}
+
+	// The refresh outlives the request that triggered it
+	ctx = context.WithoutCancel(ctx)
+	s.refreshes.Add(1)
+	go func() {
+		defer s.refreshes.Done()
+		defer func() { <-s.refreshSlots }()
+
+		s.log(ctx).Debug("Refreshing cached bookmarks ahead of expiry", "username", params.Username)
+		fetch, err := s.fetchBookmarks(ctx, params)
+		if err != nil {
+			s.log(ctx).Warn("Failed to refresh cached bookmarks", "username", params.Username, "error", err)
+		}
+		s.storeFetch(ctx, key, fetch, err)
+	}()
+}
+
+// WarmResult is the outcome of warming the cache for one parameter set
+type WarmResult struct {
+	Params types.GetHatenaBookmarksParams
+	// Cached reports that a result was already cached and nothing was fetched
+	Cached bool
+	Err    error
+}
+
+// Warm fetches and caches the given parameter sets ahead of use, such as at
+// startup for frequently queried users. At most WarmConcurrency sets are
+// fetched at once, consecutive fetches start at least WarmInterval apart, and
+// their requests share the service-wide request limit. Once ctx is done, no
+// further fetches start and the remaining sets fail with ctx's error.
+// Results are returned in the order of params.
+func (s *BookmarkService) Warm(ctx context.Context, params []types.GetHatenaBookmarksParams) []WarmResult {
+	results := make([]WarmResult, len(params))
+	if s.cache == nil {
+		for i := range params {
+			results[i] = WarmResult{Params: params[i], Err: errors.New("caching is disabled")}
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	warming := make(chan struct{}, s.options.WarmConcurrency)
+
+	var pace <-chan time.Time
+	if s.options.WarmInterval > 0 {
+		ticker := time.NewTicker(s.options.WarmInterval)
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	dispatched := 0
+	for i := range params {
+		results[i].Params = params[i]
+
+		prepared, err := s.prepareParams(params[i])
+		if err == nil && prepared.RawXML != "" {
+			err = errors.New("raw_xml results are not cached")
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		// Warming should populate the cache even if asked to bypass it
+		prepared.NoCache = false
+
+		if dispatched > 0 && pace != nil {
+			select {
+			case <-pace:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() == nil {
+			select {
+			case warming <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		dispatched++
+		wg.Add(1)
+		go func(result *WarmResult) {
+			defer wg.Done()
+			defer func() { <-warming }()
+
+			_, result.Cached, result.Err = s.loadFetch(ctx, prepared)
+		}(&results[i])
+	}
+
+	wg.Wait()
+	return results
+}
+
+// storeFetch caches the outcome of fetchBookmarks under key, unless key is
+// empty. Complete results with bookmarks are cached for CacheTTL. Empty
+// results and missing feeds are cached as negative entries for the shorter
+// CacheNegativeTTL, so that a user who just started bookmarking is not
+// served an empty result for long. Results cut short by a failure and other
+// errors are not cached.
+func (s *BookmarkService) storeFetch(ctx context.Context, key string, fetch *cachedFetch, err error) {
+	switch {
+	case key == "":
+	case err != nil:
+		if isNotFound(err) {
+			s.log(ctx).Debug("Caching missing feed as a negative entry")
+			s.cache.SetNegative(key, &cachedFetch{err: err})
+		}
+	case len(fetch.data.PageErrors) > 0 || fetch.truncatedBy == TruncatedByOverallTimeout:
+	case len(fetch.data.Items) == 0:
+		s.cache.SetNegative(key, fetch.clone())
+	default:
+		s.cache.Set(key, fetch.clone())
+	}
+}
+
+// InvalidateCache drops the cached results of username's get_hatena_bookmarks
+// calls, for clients that changed bookmarks and want fresh data
+func (s *BookmarkService) InvalidateCache(ctx context.Context, username string) (*types.InvalidateCacheResponse, error) {
+	username = strings.TrimSpace(username)
+	if err := s.validator.ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	invalidated := 0
+	if s.cache != nil {
+		invalidated = s.cache.InvalidateUser(s.cacheNamespace, username)
+	}
+	s.log(ctx).Info("Invalidated cached bookmarks", "username", username, "count", invalidated)
+
+	return &types.InvalidateCacheResponse{User: username, Invalidated: invalidated}, nil
+}
+
+// isNotFound reports whether err is the API_ERROR for a 404 response
+func isNotFound(err error) bool {
+	var mcpErr *types.MCPError
+	if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeAPI {
+		return false
+	}
+	details, ok := mcpErr.Details.(map[string]interface{})
+	return ok && details["status_code"] == http.StatusNotFound
+}
+
+// GetAllTagged retrieves every bookmark with the given tag by merging the
+// query-style feed ({username}/rss?tag=) and the path-style tag feed
+// ({username}/{tag}/rss). Hatena serves these from different endpoints whose
+// results can differ, so both are fetched and merged, deduplicated by GUID or URL.
+func (s *BookmarkService) GetAllTagged(ctx context.Context, username, tag string) (*types.GetHatenaBookmarksResponse, error) {
+	s.log(ctx).Info("Getting all tagged bookmarks", "username", username, "tag", tag)
+
+	params := types.GetHatenaBookmarksParams{Username: username, Tag: tag}
+	if err := s.validateParams(params); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(tag) == "" {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "Tag is required",
+			Details: map[string]interface{}{"field": "tag"},
+		}
+	}
+
+	queryURL, err := s.buildRequestURL(params)
+	if err != nil {
+		return nil, err
+	}
+
+	pathURL, err := s.buildTagPathURL(username, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	queryData, err := s.fetchAndParse(ctx, queryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	pathData, err := s.fetchAndParse(ctx, pathURL)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeBookmarks(append(queryData.Items, pathData.Items...))
+
+	s.log(ctx).Info("Successfully merged tagged bookmarks",
+		"username", username,
+		"query_count", len(queryData.Items),
+		"path_count", len(pathData.Items),
+		"merged_count", len(merged))
+
+	return &types.GetHatenaBookmarksResponse{
+		SchemaVersion: types.SchemaVersion,
+		User:          username,
+		Page:          1,
+		TotalCount:    len(merged),
+		Filters:       &types.FilterParams{Tag: tag},
+		Filtered:      true,
+		Bookmarks:     merged,
+	}, nil
+}
+
+// GetReadLater returns a user's bookmarks carrying the read-later tag, oldest
+// first. If olderThanDays is positive, only bookmarks made more than that many
+// days ago are returned. A user without the tag gets an empty list.
+func (s *BookmarkService) GetReadLater(ctx context.Context, username string, olderThanDays int) (*types.ReadLaterResponse, error) {
+	if olderThanDays < 0 {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "older_than_days must not be negative",
+			Details: map[string]interface{}{"older_than_days": olderThanDays},
+		}
+	}
+
+	tag := s.options.ReadLaterTag
+	items, err := s.FetchAll(ctx, types.GetHatenaBookmarksParams{Username: username, Tag: tag})
+	if err != nil {
+		return nil, err
+	}
+
+	// The tag feed is authoritative, but guard against loosely matched items
+	bookmarks := make([]types.BookmarkItem, 0, len(items))
+	for _, item := range items {
+		if analysis.HasTag(item, s.options.canonicalTag(tag)) {
+			bookmarks = append(bookmarks, item)
+		}
+	}
+
+	if olderThanDays > 0 {
+		cutoff := s.options.Clock().AddDate(0, 0, -olderThanDays)
+		bookmarks = olderThan(bookmarks, cutoff)
+	}
+	sortByDateOldestFirst(bookmarks)
+
+	return &types.ReadLaterResponse{
+		User:          strings.TrimSpace(username),
+		Tag:           tag,
+		OlderThanDays: olderThanDays,
+		Count:         len(bookmarks),
+		Bookmarks:     bookmarks,
+	}, nil
+}
+
+// DebugParse fetches a single feed page and returns the parser's output as
+// is, without the filtering, sorting or grouping GetBookmarks applies
+func (s *BookmarkService) DebugParse(ctx context.Context, params types.GetHatenaBookmarksParams) (*types.ParsedRSSData, error) {
+	params.Username = strings.TrimSpace(params.Username)
+	params.URL = strings.TrimSpace(params.URL)
+
+	if err := s.validateParams(params); err != nil {
+		return nil, err
+	}
+
+	requestURL, err := s.buildRequestURL(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.fetchAndParse(ctx, requestURL)
+}
+
+// FetchAll retrieves bookmarks across pages, starting at page 1, until an
+// empty page is returned or MaxPages or MaxItems is reached. Results are
+// deduplicated. Timeouts report whether a single page request or the overall
+// deadline fired.
+func (s *BookmarkService) FetchAll(ctx context.Context, params types.GetHatenaBookmarksParams) ([]types.BookmarkItem, error) {
+	params.Username = strings.TrimSpace(params.Username)
+	params.URL = strings.TrimSpace(params.URL)
+
+	if err := s.validateParams(params); err != nil {
+		return nil, err
+	}
+
+	data, _, err := s.fetchAllPages(ctx, params, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Items, nil
+}
+
+// fetchAllPages fetches pages starting at page 1 until an empty page, bounded
+// by MaxPages, MaxItems and FetchAllTimeout. It returns the merged data and,
+// if a bound stopped it early, the TruncatedBy reason. A limit is reported
+// only if bookmarks were actually left out: reaching MaxPages probes the next
+// page, and MaxItems counts bookmarks after deduplication. With allowPartial, the
+// overall deadline firing after at least one page yields the pages fetched so
+// far instead of an error, as does any page failing with params.BestEffort,
+// in which case the failure is recorded in PageErrors.
+func (s *BookmarkService) fetchAllPages(ctx context.Context, params types.GetHatenaBookmarksParams, allowPartial bool) (*types.ParsedRSSData, string, error) {
+	if s.options.FetchAllTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.options.FetchAllTimeout)
+		defer cancel()
+	}
+
+	data := &types.ParsedRSSData{}
+	truncatedBy := ""
+
+	for page := 1; ; page++ {
+		params.Page = page
+		if page > s.options.MaxPages {
+			if s.hasMorePages(ctx, params, data.Items) {
+				truncatedBy = TruncatedByMaxPages
+			}
+			break
+		}
+
+		requestURL, err := s.buildRequestURL(params)
+		if err != nil {
+			return nil, "", err
+		}
+
+		parsedData, err := s.fetchPage(ctx, requestURL)
+		if err != nil {
+			err = s.fetchAllError(ctx, err, page)
+			if allowPartial && page > 1 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				truncatedBy = TruncatedByOverallTimeout
+				break
+			}
+			if allowPartial && page > 1 && params.BestEffort {
+				truncatedBy = TruncatedByPageError
+				data.PageErrors = append(data.PageErrors, pageError(err, page))
+				break
+			}
+			return nil, "", err
+		}
+
+		if len(parsedData.Items) == 0 {
+			break
+		}
+		if params.AnnotatePage {
+			annotatePage(parsedData.Items, page)
+		}
+		data.Title = parsedData.Title
+		data.Items = dedupBookmarks(append(data.Items, parsedData.Items...))
+		data.TotalItems += parsedData.TotalItems
+		data.Warnings = append(data.Warnings, parsedData.Warnings...)
+		data.ResponseHeaders = parsedData.ResponseHeaders
+
+		if page%s.options.ProgressLogInterval == 0 {
+			s.log(ctx).Info(fmt.Sprintf("Fetched page %d, %d bookmarks so far", page, len(data.Items)),
+				"username", params.Username,
+				"page", page,
+				"bookmark_count", len(data.Items))
+		}
+
+		// Exactly MaxItems bookmarks is not a truncation; the next page
+		// tells whether any were left out
+		if len(data.Items) > s.options.MaxItems {
+			truncatedBy = TruncatedByMaxItems
+			break
+		}
+	}
+
+	if len(data.Items) > s.options.MaxItems {
+		data.Items = data.Items[:s.options.MaxItems]
+	}
+	data.ItemCount = len(data.Items)
+
+	if truncatedBy != "" {
+		s.log(ctx).Warn("Stopped fetching pages early",
+			"username", params.Username,
+			"reason", truncatedBy,
+			"count", len(data.Items))
+	}
+
+	s.log(ctx).Info("Fetched all pages",
+		"username", params.Username,
+		"count", len(data.Items))
+
+	return data, truncatedBy, nil
+}
+
+// hasMorePages reports whether the page params.Page, just past MaxPages, has
+// bookmarks not among collected. A failed probe is assumed to have some, so
+// that the result is still reported as truncated.
+func (s *BookmarkService) hasMorePages(ctx context.Context, params types.GetHatenaBookmarksParams, collected []types.BookmarkItem) bool {
+	requestURL, err := s.buildRequestURL(params)
+	if err != nil {
+		return true
+	}
+
+	parsedData, err := s.fetchPage(ctx, requestURL)
+	if err != nil {
+		s.log(ctx).Debug("Failed to probe the page after max_pages", "page", params.Page, "error", err)
+		return true
+	}
+	merged := dedupBookmarks(append(slices.Clone(collected), parsedData.Items...))
+	return len(merged) > len(collected)
+}
+
+// GetTagCounts returns the tags used most across all of a user's bookmarks,
+// ranked by the number of bookmarks carrying them. With withFirstSeen, each
+// tag also records the date of its earliest bookmark. topN defaults to
+// DefaultTagCountsTopN when zero.
+func (s *BookmarkService) GetTagCounts(ctx context.Context, username string, topN int, withFirstSeen bool) (*types.TagCountsResponse, error) {
+	if topN < 0 || topN > MaxTagCountsTopN {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("top_n must be between 0 and %d", MaxTagCountsTopN),
+			Details: map[string]interface{}{"top_n": topN},
+		}
+	}
+	if topN == 0 {
+		topN = DefaultTagCountsTopN
+	}
+
+	items, err := s.FetchAll(ctx, types.GetHatenaBookmarksParams{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := analysis.TopTags(items, topN)
+	if withFirstSeen {
+		firstSeen := analysis.TagFirstSeen(items)
+		for i := range tags {
+			tags[i].FirstSeen = firstSeen[tags[i].Tag]
+		}
+	}
+
+	return &types.TagCountsResponse{
+		User:           strings.TrimSpace(username),
+		TotalBookmarks: len(items),
+		Tags:           tags,
+	}, nil
+}
+
+// GetTodaysBookmarks retrieves the bookmarks a user added today, where
+// "today" is the current date in JST (Hatena's timezone)
+func (s *BookmarkService) GetTodaysBookmarks(ctx context.Context, username string) (*types.GetHatenaBookmarksResponse, error) {
+	today := s.options.Clock().In(jst).Format("20060102")
+	s.log(ctx).Debug("Resolved today's date", "date", today)
+
+	return s.GetBookmarks(ctx, types.GetHatenaBookmarksParams{
+		Username: username,
+		Date:     today,
+	})
+}
+
+// fetchMinResults fetches pages from params.Page on until at least
+// params.MinResults bookmarks are collected, an empty page is reached or
+// MaxPages pages or MaxItems bookmarks have been fetched. It returns the
+// merged data, the last page fetched with bookmarks and whether more pages
+// may follow it.
+func (s *BookmarkService) fetchMinResults(ctx context.Context, params types.GetHatenaBookmarksParams) (*types.ParsedRSSData, int, bool, error) {
+	data := &types.ParsedRSSData{Items: []types.BookmarkItem{}}
+	start := s.getPageOrDefault(params.Page)
+	lastPage := start
+
+	for page := start; page < start+s.options.MaxPages; page++ {
+		params.Page = page
+		requestURL, err := s.buildRequestURL(params)
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		parsedData, err := s.fetchPage(ctx, requestURL)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if len(parsedData.Items) == 0 {
+			return s.finishMinResults(ctx, params, data), lastPage, false, nil
+		}
+		if params.AnnotatePage {
+			annotatePage(parsedData.Items, page)
+		}
+
+		lastPage = page
+		data.Title = parsedData.Title
+		data.Items = dedupBookmarks(append(data.Items, parsedData.Items...))
+		data.TotalItems += parsedData.TotalItems
+		data.Warnings = append(data.Warnings, parsedData.Warnings...)
+		data.ResponseHeaders = parsedData.ResponseHeaders
+
+		if len(data.Items) >= params.MinResults || len(data.Items) >= s.options.MaxItems {
+			break
+		}
+	}
+
+	return s.finishMinResults(ctx, params, data), lastPage, true, nil
+}
+
+// finishMinResults caps the bookmarks collected by fetchMinResults at
+// MaxItems and logs the outcome
+func (s *BookmarkService) finishMinResults(ctx context.Context, params types.GetHatenaBookmarksParams, data *types.ParsedRSSData) *types.ParsedRSSData {
+	if len(data.Items) > s.options.MaxItems {
+		data.Items = data.Items[:s.options.MaxItems]
+	}
+	data.ItemCount = len(data.Items)
+
+	s.log(ctx).Info("Fetched pages for min_results",
+		"username", params.Username,
+		"min_results", params.MinResults,
+		"count", len(data.Items))
+
+	return data
+}
+
+// fetchPage fetches and parses one FetchAll page, bounded by PageTimeout
+func (s *BookmarkService) fetchPage(ctx context.Context, requestURL string) (*types.ParsedRSSData, error) {
+	if s.options.PageTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.options.PageTimeout)
+		defer cancel()
+	}
+
+	return s.fetchAndParse(ctx, requestURL)
+}
+
+// fetchAllError distinguishes the overall deadline from a single page
+// request timing out; other errors are returned unchanged
+func (s *BookmarkService) fetchAllError(ctx context.Context, err error, page int) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		s.log(ctx).Warn("Overall fetch deadline exceeded", "completed_pages", page-1)
+		return (&types.MCPError{
+			Code:    types.ErrorCodeNetwork,
+			Message: fmt.Sprintf("overall fetch deadline exceeded after %d pages", page-1),
+			Details: map[string]interface{}{"completed_pages": page - 1},
+		}).WithCause(err)
+	case isTimeout(err):
+		s.log(ctx).Warn("Page request timed out", "page", page)
+		return (&types.MCPError{
+			Code:    types.ErrorCodeNetwork,
+			Message: fmt.Sprintf("page %d request timed out", page),
+			Details: map[string]interface{}{"page": page},
+		}).WithCause(err)
+	}
+	return err
+}
+
+// pageError converts a failed page fetch into the MCPError reported by a
+// best-effort fetch, noting the page in its details
+func pageError(err error, page int) *types.MCPError {
+	details := map[string]interface{}{"page": page}
+
+	var mcpErr *types.MCPError
+	if !errors.As(err, &mcpErr) {
+		return (&types.MCPError{
+			Code:    types.ErrorCodeNetwork,
+			Message: fmt.Sprintf("page %d: %v", page, err),
+			Details: details,
+		}).WithCause(err)
+	}
+
+	if existing, ok := mcpErr.Details.(map[string]interface{}); ok {
+		for key, value := range existing {
+			details[key] = value
+		}
+	}
+	return (&types.MCPError{
+		Code:    mcpErr.Code,
+		Message: fmt.Sprintf("page %d: %s", page, mcpErr.Message),
+		Details: details,
+	}).WithCause(err)
+}
+
+// isTimeout reports whether err was caused by a deadline or client timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// GetBookmarkStats computes aggregate statistics over a user's bookmarks
+func (s *BookmarkService) GetBookmarkStats(ctx context.Context, username string) (*types.BookmarkStats, error) {
+	items, err := s.FetchAll(ctx, types.GetHatenaBookmarksParams{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := analysis.ComputeStats(items)
+	stats.User = username
+
+	return stats, nil
+}
+
+// GetDomainCount returns the number of distinct hosts across a user's
+// bookmarks and the DefaultTopDomains most frequent ones. With byPath,
+// bookmarks are grouped by host and path instead, ignoring query strings.
+func (s *BookmarkService) GetDomainCount(ctx context.Context, username string, byPath bool) (*types.DomainCountResponse, error) {
+	items, err := s.FetchAll(ctx, types.GetHatenaBookmarksParams{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	groupBy := GroupByHost
+	distinct, top := analysis.TopDomains(items, DefaultTopDomains)
+	if byPath {
+		groupBy = GroupByPath
+		distinct, top = analysis.TopPaths(items, DefaultTopDomains)
+	}
+
+	return &types.DomainCountResponse{
+		User:            username,
+		GroupBy:         groupBy,
+		TotalBookmarks:  len(items),
+		DistinctDomains: distinct,
+		TopDomains:      top,
+	}, nil
+}
+
+// GetUserOverview assembles a profile view of a user from one bounded fetch
+// of all pages: the bookmark total, top tags and domains, monthly activity
+// and the recentCount most recent bookmarks. recentCount defaults to
+// DefaultOverviewRecentCount when zero.
+func (s *BookmarkService) GetUserOverview(ctx context.Context, username string, recentCount int) (*types.UserOverview, error) {
+	if recentCount < 0 || recentCount > MaxOverviewRecentCount {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("recent_count must be between 0 and %d", MaxOverviewRecentCount),
+			Details: map[string]interface{}{"recent_count": recentCount},
+		}
+	}
+	if recentCount == 0 {
+		recentCount = DefaultOverviewRecentCount
+	}
+
+	params := types.GetHatenaBookmarksParams{Username: strings.TrimSpace(username)}
+	if err := s.validateParams(params); err != nil {
+		return nil, err
+	}
+
+	data, truncatedBy, err := s.fetchAllPages(ctx, params, true)
+	if err != nil {
+		return nil, err
+	}
+	items := data.Items
+
+	_, topDomains := analysis.TopDomains(items, DefaultOverviewTopN)
+
+	recent := append([]types.BookmarkItem(nil), items...)
+	sortByDate(recent)
+	if len(recent) > recentCount {
+		recent = recent[:recentCount]
+	}
+
+	return &types.UserOverview{
+		User:            params.Username,
+		TotalBookmarks:  len(items),
+		Truncated:       truncatedBy != "",
+		TopTags:         analysis.TopTags(items, DefaultOverviewTopN),
+		TopDomains:      topDomains,
+		Activity:        analysis.MonthlyActivity(items),
+		RecentBookmarks: recent,
+	}, nil
+}
+
+// GetRecentDomains returns the hosts a user bookmarked most recently, newest
+// first and without duplicates, each with its latest bookmark. Pages are
+// fetched only until limit hosts are found, up to MaxPages. limit defaults
+// to DefaultRecentDomains when zero.
+func (s *BookmarkService) GetRecentDomains(ctx context.Context, username string, limit int) (*types.RecentDomainsResponse, error) {
+	if limit < 0 || limit > MaxRecentDomains {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("limit must be between 0 and %d", MaxRecentDomains),
+			Details: map[string]interface{}{"limit": limit},
+		}
+	}
+	if limit == 0 {
+		limit = DefaultRecentDomains
+	}
+
+	params := types.GetHatenaBookmarksParams{Username: strings.TrimSpace(username)}
+	if err := s.validateParams(params); err != nil {
+		return nil, err
+	}
+
+	var items []types.BookmarkItem
+	domains := make([]types.RecentDomain, 0)
+	for page := 1; page <= s.options.MaxPages; page++ {
+		params.Page = page
+		requestURL, err := s.buildRequestURL(params)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := s.fetchPage(ctx, requestURL)
+		if err != nil {
+			return nil, err
+		}
+		if len(data.Items) == 0 {
+			break
+		}
+
+		items = dedupBookmarks(append(items, data.Items...))
+		sortByDate(items)
+		domains = analysis.RecentDomains(items, limit)
+		if len(domains) >= limit {
+			break
+		}
+	}
+
+	return &types.RecentDomainsResponse{
+		User:    params.Username,
+		Count:   len(domains),
+		Domains: domains,
+	}, nil
 }
 
-// NewBookmarkService creates a new bookmark service instance
-func NewBookmarkService(logger *slog.Logger) *BookmarkService {
-	return &BookmarkService{
-		baseURL: "https://b.hatena.ne.jp",
-		logger:  logger,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		rssParser: parser.NewRSSParser(logger),
+// FilterBookmarks returns the bookmarks on one feed page that have, or lack,
+// a comment and tags. A nil criterion matches every bookmark.
+func (s *BookmarkService) FilterBookmarks(ctx context.Context, username string, hasComment, hasTags *bool, page int) (*types.FilterBookmarksResponse, error) {
+	result, err := s.GetBookmarks(ctx, types.GetHatenaBookmarksParams{Username: username, Page: page})
+	if err != nil {
+		return nil, err
 	}
+
+	matched := filterByPresence(result.Bookmarks, hasComment, hasTags)
+
+	return &types.FilterBookmarksResponse{
+		User:         result.User,
+		Page:         result.Page,
+		HasComment:   hasComment,
+		HasTags:      hasTags,
+		TotalCount:   len(result.Bookmarks),
+		MatchedCount: len(matched),
+		Bookmarks:    matched,
+	}, nil
 }
 
-// GetBookmarks retrieves bookmarks from Hatena Bookmark RSS feed
-func (s *BookmarkService) GetBookmarks(ctx context.Context, params types.GetHatenaBookmarksParams) (*types.GetHatenaBookmarksResponse, error) {
-	s.logger.Info("Getting bookmarks", 
-		"username", params.Username,
-		"tag", params.Tag,
-		"date", params.Date,
-		"url", params.URL,
-		"page", params.Page)
+// CompareUsers compares the URLs two users bookmarked within their first
+// pages feed pages, returning the shared URLs, the number unique to each
+// user and the Jaccard similarity of the two sets. pages defaults to
+// DefaultComparePages when zero.
+func (s *BookmarkService) CompareUsers(ctx context.Context, usernameA, usernameB string, pages int) (*types.CompareUsersResponse, error) {
+	if pages < 0 || pages > MaxComparePages {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("within_pages must be between 0 and %d", MaxComparePages),
+			Details: map[string]interface{}{"within_pages": pages},
+		}
+	}
+	if pages == 0 {
+		pages = DefaultComparePages
+	}
+
+	itemsA, err := s.fetchPages(ctx, strings.TrimSpace(usernameA), pages)
+	if err != nil {
+		return nil, err
+	}
+	itemsB, err := s.fetchPages(ctx, strings.TrimSpace(usernameB), pages)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, onlyA, onlyB := analysis.URLOverlap(itemsA, itemsB)
+
+	return &types.CompareUsersResponse{
+		UserA:       strings.TrimSpace(usernameA),
+		UserB:       strings.TrimSpace(usernameB),
+		Pages:       pages,
+		SharedCount: len(shared),
+		SharedURLs:  shared,
+		UniqueA:     onlyA,
+		UniqueB:     onlyB,
+		Similarity:  analysis.Jaccard(len(shared), onlyA, onlyB),
+	}, nil
+}
 
-	// Validate parameters
+// fetchPages fetches up to pages feed pages of a user, stopping at the first
+// empty page
+func (s *BookmarkService) fetchPages(ctx context.Context, username string, pages int) ([]types.BookmarkItem, error) {
+	params := types.GetHatenaBookmarksParams{Username: username}
 	if err := s.validateParams(params); err != nil {
 		return nil, err
 	}
 
-	// Build request URL
-	requestURL := s.buildRequestURL(params)
-	s.logger.Debug("Built request URL", "url", requestURL)
+	items := make([]types.BookmarkItem, 0)
+	for page := 1; page <= pages; page++ {
+		params.Page = page
+		requestURL, err := s.buildRequestURL(params)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := s.fetchPage(ctx, requestURL)
+		if err != nil {
+			return nil, err
+		}
+		if len(data.Items) == 0 {
+			break
+		}
+		items = append(items, data.Items...)
+	}
+
+	return items, nil
+}
+
+// SuggestTags returns the tags most frequently co-occurring with tag across a
+// user's bookmarks. topN defaults to DefaultSuggestTagsTopN when zero.
+func (s *BookmarkService) SuggestTags(ctx context.Context, username, tag string, topN int) (*types.SuggestTagsResponse, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "Tag is required",
+			Details: map[string]interface{}{"field": "tag"},
+		}
+	}
+
+	if topN < 0 || topN > MaxSuggestTagsTopN {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("top_n must be between 0 and %d", MaxSuggestTagsTopN),
+			Details: map[string]interface{}{"top_n": topN},
+		}
+	}
+	if topN == 0 {
+		topN = DefaultSuggestTagsTopN
+	}
+
+	items, err := s.FetchAll(ctx, types.GetHatenaBookmarksParams{Username: username, Tag: tag})
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.SuggestTagsResponse{
+		User:        username,
+		Tag:         tag,
+		Suggestions: analysis.CoOccurringTags(items, s.options.canonicalTag(tag), topN),
+	}, nil
+}
+
+// GetCommentKeywords returns the most frequent words across the comments of a
+// user's bookmarks. topN and minLength default to DefaultCommentKeywordsTopN
+// and DefaultCommentKeywordsMinLength when zero.
+func (s *BookmarkService) GetCommentKeywords(ctx context.Context, username string, topN, minLength int) (*types.CommentKeywordsResponse, error) {
+	if topN < 0 || topN > MaxCommentKeywordsTopN {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("top_n must be between 0 and %d", MaxCommentKeywordsTopN),
+			Details: map[string]interface{}{"top_n": topN},
+		}
+	}
+	if topN == 0 {
+		topN = DefaultCommentKeywordsTopN
+	}
+
+	if minLength < 0 {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "min_length must not be negative",
+			Details: map[string]interface{}{"min_length": minLength},
+		}
+	}
+	if minLength == 0 {
+		minLength = DefaultCommentKeywordsMinLength
+	}
 
-	// Make HTTP request
-	xmlContent, err := s.fetchRSSFeed(ctx, requestURL)
+	items, err := s.FetchAll(ctx, types.GetHatenaBookmarksParams{Username: username})
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse RSS content
-	parsedData, err := s.rssParser.ParseRSSFeed(ctx, xmlContent)
+	return &types.CommentKeywordsResponse{
+		User:     username,
+		Keywords: analysis.CommentKeywords(items, topN, minLength),
+	}, nil
+}
+
+// fetchAndParse fetches the RSS feed at requestURL and parses it.
+// Concurrent calls for the same URL share a single fetch. The shared fetch
+// keeps the values of the first caller's context, such as its request ID,
+// but not its cancellation: it is bounded by RequestTimeout instead, and
+// each caller stops waiting when its own context is done.
+func (s *BookmarkService) fetchAndParse(ctx context.Context, requestURL string) (*types.ParsedRSSData, error) {
+	data, shared, err := s.inflight.do(ctx, requestURL, func() (*types.ParsedRSSData, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.options.RequestTimeout)
+		defer cancel()
+
+		xmlContent, headers, err := s.fetchRSSFeed(fetchCtx, requestURL)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := s.parseFeed(fetchCtx, xmlContent)
+		if err != nil {
+			return nil, err
+		}
+		data.ResponseHeaders = headers
+
+		return data, nil
+	})
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+			return nil, (&types.MCPError{
+				Code:    types.ErrorCodeNetwork,
+				Message: fmt.Sprintf("Request cancelled while waiting for the feed: %v", err),
+				Details: map[string]interface{}{"url": requestURL},
+			}).WithCause(err)
+		}
 		return nil, err
 	}
 
-	// Build response
-	response := &types.GetHatenaBookmarksResponse{
-		User:       params.Username,
-		Page:       s.getPageOrDefault(params.Page),
-		TotalCount: len(parsedData.Items),
-		Bookmarks:  parsedData.Items,
+	if shared {
+		s.log(ctx).Debug("Shared in-flight fetch result", "url", requestURL)
 	}
 
-	// Add filters if any were applied
-	if params.Tag != "" || params.Date != "" || params.URL != "" {
-		response.Filters = &types.FilterParams{
-			Tag:  params.Tag,
-			Date: params.Date,
-			URL:  params.URL,
-		}
+	data = cloneParsedData(data)
+	s.applyTagAliases(data.Items)
+	return data, nil
+}
+
+// parseFeed parses a feed document. Documents larger than StreamThreshold
+// are decoded one item at a time with StreamRSSFeed, which never holds the
+// whole decoded document next to the bookmarks and stops as soon as ctx is
+// done; smaller ones are unmarshaled whole with ParseRSSFeed. Both detect
+// the format alike, so a feed parses the same either way.
+func (s *BookmarkService) parseFeed(ctx context.Context, xmlContent []byte) (*types.ParsedRSSData, error) {
+	threshold := s.options.StreamThreshold
+	if threshold < 0 || len(xmlContent) <= threshold {
+		return s.rssParser.ParseRSSFeed(ctx, xmlContent)
 	}
 
-	s.logger.Info("Successfully retrieved bookmarks", 
-		"username", params.Username,
-		"count", len(parsedData.Items))
+	s.log(ctx).Debug("Streaming large feed", "content_length", len(xmlContent), "threshold", threshold)
 
-	return response, nil
+	items := []types.BookmarkItem{}
+	data, err := s.rssParser.StreamRSSFeed(ctx, xmlContent, func(item types.BookmarkItem) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	data.Items = items
+
+	return data, nil
 }
 
 // validateParams validates the input parameters
 func (s *BookmarkService) validateParams(params types.GetHatenaBookmarksParams) error {
-	if strings.TrimSpace(params.Username) == "" {
+	// Validate all fields at once so clients can fix every problem in one go
+	if err := s.validator.ValidateAll(params); err != nil {
+		return err
+	}
+
+	// Validate sort order if provided
+	if params.SortBy != "" && params.SortBy != SortByScore && params.SortBy != SortByDate {
 		return &types.MCPError{
 			Code:    types.ErrorCodeValidation,
-			Message: "Username is required",
-			Details: map[string]interface{}{"field": "username"},
+			Message: fmt.Sprintf("Unsupported sort_by value %q (supported: %q, %q)", params.SortBy, SortByScore, SortByDate),
+			Details: map[string]interface{}{"sort_by": params.SortBy},
 		}
 	}
 
-	// Validate username format (alphanumeric and hyphens only)
-	if !isValidUsername(params.Username) {
+	// Raw XML replaces fetching, so feed filters and paging cannot apply
+	if params.RawXML != "" {
+		if err := validateRawXML(params); err != nil {
+			return err
+		}
+	}
+
+	// min_results pages through the feed itself
+	if params.MinResults < 0 {
 		return &types.MCPError{
 			Code:    types.ErrorCodeValidation,
-			Message: "Username must contain only alphanumeric characters and hyphens",
-			Details: map[string]interface{}{"username": params.Username},
+			Message: "min_results must not be negative",
+			Details: map[string]interface{}{"min_results": params.MinResults},
 		}
 	}
-
-	// Validate date format if provided
-	if params.Date != "" && !isValidDateFormat(params.Date) {
+	if params.MinResults > 0 && (params.FetchAll || params.RawXML != "") {
 		return &types.MCPError{
 			Code:    types.ErrorCodeValidation,
-			Message: "Date must be in YYYYMMDD format",
-			Details: map[string]interface{}{"date": params.Date},
+			Message: "min_results cannot be combined with fetch_all or raw_xml",
+			Details: map[string]interface{}{"min_results": params.MinResults},
 		}
 	}
 
-	// Validate URL format if provided
-	if params.URL != "" && !isValidURL(params.URL) {
+	// Bookmarks can be grouped one way only
+	if params.GroupByDate && params.TagTree {
 		return &types.MCPError{
 			Code:    types.ErrorCodeValidation,
-			Message: "Invalid URL format",
-			Details: map[string]interface{}{"url": params.URL},
+			Message: "group_by_date cannot be combined with tag_tree",
+			Details: map[string]interface{}{"group_by_date": true, "tag_tree": true},
 		}
 	}
 
-	// Validate page number
-	if params.Page < 0 {
+	// Fetching all pages always starts at page 1
+	if params.FetchAll && (params.Page > 1 || params.Cursor != "") {
 		return &types.MCPError{
 			Code:    types.ErrorCodeValidation,
-			Message: "Page number must be positive",
+			Message: "page and cursor cannot be combined with fetch_all",
 			Details: map[string]interface{}{"page": params.Page},
 		}
 	}
 
+	// Validate output date format and timezone if provided
+	if _, err := dateLayout(params.DateFormat); err != nil {
+		return err
+	}
+	if _, err := dateLocation(params.Timezone); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateRawXML checks raw XML input and rejects parameters that only
+// apply when fetching
+func validateRawXML(params types.GetHatenaBookmarksParams) error {
+	if strings.TrimSpace(params.RawXML) == "" {
+		return &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "raw_xml must not be empty",
+			Details: map[string]interface{}{"field": "raw_xml"},
+		}
+	}
+
+	if len(params.RawXML) > MaxRawXMLBytes {
+		return &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("raw_xml must be %d bytes or less", MaxRawXMLBytes),
+			Details: map[string]interface{}{"field": "raw_xml", "length": len(params.RawXML)},
+		}
+	}
+
+	if params.Tag != "" || params.Date != "" || params.URL != "" || params.Page > 1 || params.FetchAll || params.Cursor != "" {
+		return &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "raw_xml cannot be combined with tag, date, url, page, cursor or fetch_all",
+			Details: map[string]interface{}{"field": "raw_xml"},
+		}
+	}
+
 	return nil
 }
 
 // buildRequestURL constructs the RSS feed URL with query parameters
-func (s *BookmarkService) buildRequestURL(params types.GetHatenaBookmarksParams) string {
+func (s *BookmarkService) buildRequestURL(params types.GetHatenaBookmarksParams) (string, error) {
+	// Escaping an already-encoded username would double-encode it
+	if utils.IsPercentEncoded(params.Username) {
+		return "", s.validator.ValidateUsername(params.Username)
+	}
+
+	username, err := sanitizePathSegment("username", params.Username)
+	if err != nil {
+		return "", err
+	}
+
 	// Base URL: https://b.hatena.ne.jp/{username}/rss
-	baseURL := fmt.Sprintf("%s/%s/rss", s.baseURL, params.Username)
+	baseURL := fmt.Sprintf("%s/%s/rss", s.baseURL, username)
 
 	// Build query parameters
 	query := url.Values{}
@@ -163,42 +1505,90 @@ func (s *BookmarkService) buildRequestURL(params types.GetHatenaBookmarksParams)
 	}
 
 	if len(query) > 0 {
-		return baseURL + "?" + query.Encode()
+		return baseURL + "?" + query.Encode(), nil
 	}
 
-	return baseURL
+	return baseURL, nil
 }
 
-// fetchRSSFeed makes HTTP request to get RSS content
-func (s *BookmarkService) fetchRSSFeed(ctx context.Context, requestURL string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+// buildTagPathURL constructs the path-style tag feed URL
+func (s *BookmarkService) buildTagPathURL(username, tag string) (string, error) {
+	usernameSegment, err := sanitizePathSegment("username", username)
 	if err != nil {
-		return nil, &types.MCPError{
-			Code:    types.ErrorCodeNetwork,
-			Message: fmt.Sprintf("Failed to create request: %v", err),
-			Details: map[string]interface{}{"url": requestURL},
+		return "", err
+	}
+
+	tagSegment, err := sanitizePathSegment("tag", tag)
+	if err != nil {
+		return "", err
+	}
+
+	// Path URL: https://b.hatena.ne.jp/{username}/{tag}/rss
+	return fmt.Sprintf("%s/%s/%s/rss", s.baseURL, usernameSegment, tagSegment), nil
+}
+
+// sanitizePathSegment percent-encodes user input embedded in a URL path so
+// that characters such as "/" cannot change the request path. Control
+// characters are rejected outright.
+func sanitizePathSegment(field, value string) (string, error) {
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return "", &types.MCPError{
+				Code:    types.ErrorCodeValidation,
+				Message: fmt.Sprintf("%s must not contain control characters", field),
+				Details: map[string]interface{}{"field": field, "value": value},
+			}
 		}
 	}
 
-	// Set User-Agent to be respectful
-	req.Header.Set("User-Agent", "hatena-bookmark-mcp/1.0")
+	return url.PathEscape(value), nil
+}
 
-	resp, err := s.client.Do(req)
+// fetchRSSFeed makes HTTP request to get RSS content. It also returns the
+// response headers selected by debugHeaders.
+func (s *BookmarkService) fetchRSSFeed(ctx context.Context, requestURL string) ([]byte, map[string]string, error) {
+	return s.fetchBody(ctx, requestURL, s.options.Accept)
+}
+
+// fetchJSON makes HTTP request to a JSON API such as the entry and star APIs
+func (s *BookmarkService) fetchJSON(ctx context.Context, requestURL string) ([]byte, error) {
+	body, _, err := s.fetchBody(ctx, requestURL, "application/json")
+	return body, err
+}
+
+// fetchBody makes a GET request asking for the accept media types and
+// returns the decoded body along with the headers selected by debugHeaders
+func (s *BookmarkService) fetchBody(ctx context.Context, requestURL, accept string) ([]byte, map[string]string, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, requestURL)
 	if err != nil {
-		return nil, &types.MCPError{
-			Code:    types.ErrorCodeNetwork,
-			Message: fmt.Sprintf("Failed to fetch RSS feed: %v", err),
-			Details: map[string]interface{}{"url": requestURL},
-		}
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := s.send(req)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			s.logger.Debug("Failed to close response body", "error", err)
+			s.log(ctx).Debug("Failed to close response body", "error", err)
 		}
 	}()
 
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		// A 503 maintenance notice is reported as such; the body is only
+		// read as far as isMaintenancePage looks
+		if reader, err := s.responseBody(resp); err == nil {
+			body, _ := io.ReadAll(io.LimitReader(reader, maintenanceScanBytes))
+			if isMaintenancePage(resp, body) {
+				s.log(ctx).Warn("Hatena is under maintenance", "url", redactURL(resp.Request.URL))
+				return nil, nil, maintenanceError(resp, requestURL)
+			}
+		}
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, &types.MCPError{
+		return nil, nil, &types.MCPError{
 			Code:    types.ErrorCodeAPI,
 			Message: fmt.Sprintf("API returned status %d", resp.StatusCode),
 			Details: map[string]interface{}{
@@ -208,56 +1598,242 @@ func (s *BookmarkService) fetchRSSFeed(ctx context.Context, requestURL string) (
 		}
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	var body []byte
+	reader, err := s.responseBody(resp)
+	if err == nil {
+		body, err = io.ReadAll(reader)
+	}
 	if err != nil {
-		return nil, &types.MCPError{
+		return nil, nil, (&types.MCPError{
 			Code:    types.ErrorCodeNetwork,
 			Message: fmt.Sprintf("Failed to read response body: %v", err),
 			Details: map[string]interface{}{"url": requestURL},
-		}
+		}).WithCause(err)
+	}
+
+	if s.options.LogHTTPBodies {
+		s.log(ctx).Debug("Received HTTP response",
+			"status_code", resp.StatusCode,
+			"headers", redactHeaders(resp.Header),
+			"body_length", len(body),
+			"body", truncateBody(body, maxLoggedBodyBytes))
+	}
+
+	// During maintenance Hatena may also serve an HTML notice with status 200
+	if isMaintenancePage(resp, body) {
+		s.log(ctx).Warn("Hatena is under maintenance", "url", redactURL(resp.Request.URL))
+		return nil, nil, maintenanceError(resp, requestURL)
 	}
 
-	return body, nil
+	return body, debugHeaders(resp), nil
 }
 
-// getPageOrDefault returns the page number or default value
-func (s *BookmarkService) getPageOrDefault(page int) int {
-	if page <= 0 {
-		return 1
+// newRequest creates an outgoing request to an allowed host with the
+// User-Agent and authentication headers set
+func (s *BookmarkService) newRequest(ctx context.Context, method, requestURL string) (*http.Request, error) {
+	if err := s.checkHost(ctx, requestURL); err != nil {
+		return nil, err
 	}
-	return page
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, nil)
+	if err != nil {
+		return nil, (&types.MCPError{
+			Code:    types.ErrorCodeNetwork,
+			Message: fmt.Sprintf("Failed to create request: %v", err),
+			Details: map[string]interface{}{"url": requestURL},
+		}).WithCause(err)
+	}
+
+	// Set User-Agent to be respectful
+	req.Header.Set("User-Agent", s.options.UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	// Feed URLs already select RSS through their path or mode=rss, but ask
+	// for it explicitly too in case an endpoint negotiates content. Callers
+	// of JSON APIs override it.
+	req.Header.Set("Accept", s.options.Accept)
+
+	// Forward the tool call's request ID so that gateway and server logs
+	// can be correlated with ours; calls without one get one per request
+	requestID := utils.RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = utils.NewRequestID()
+		s.log(ctx).Debug("Generated request ID for outgoing request",
+			"request_id", requestID,
+			"url", redactURL(req.URL))
+	}
+	req.Header.Set(utils.RequestIDHeader, requestID)
+
+	if err := s.setAuthHeaders(req); err != nil {
+		return nil, (&types.MCPError{
+			Code:    types.ErrorCodeNetwork,
+			Message: fmt.Sprintf("Failed to authenticate request: %v", err),
+			Details: map[string]interface{}{"url": requestURL},
+		}).WithCause(err)
+	}
+
+	return req, nil
+}
+
+// send issues the request once a request slot is available. The slot is
+// held until the response body is closed.
+func (s *BookmarkService) send(req *http.Request) (*http.Response, error) {
+	requestURL := req.URL.String()
+
+	if s.options.LogHTTPBodies {
+		s.log(req.Context()).Debug("Sending HTTP request",
+			"method", req.Method,
+			"url", redactURL(req.URL),
+			"headers", redactHeaders(req.Header))
+	}
+
+	release, err := s.acquireRequestSlot(req.Context())
+	if err != nil {
+		return nil, (&types.MCPError{
+			Code:    types.ErrorCodeNetwork,
+			Message: fmt.Sprintf("Request cancelled while waiting for a connection slot: %v", err),
+			Details: map[string]interface{}{"url": requestURL},
+		}).WithCause(err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		release()
+		if authErr := authRequiredError(err, requestURL); authErr != nil {
+			s.log(req.Context()).Warn("Request redirected to a login page", "url", redactURL(req.URL))
+			return nil, authErr
+		}
+		if hostErr := redirectHostValidationError(err, requestURL); hostErr != nil {
+			s.log(req.Context()).Warn("Blocked redirect to disallowed host", "url", redactURL(req.URL))
+			return nil, hostErr
+		}
+		return nil, (&types.MCPError{
+			Code:    types.ErrorCodeNetwork,
+			Message: fmt.Sprintf("Failed to fetch RSS feed: %v", err),
+			Details: map[string]interface{}{"url": requestURL},
+		}).WithCause(err)
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
 }
 
-// Validation helper functions
+// releasingBody releases a request slot when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+// Close closes the body and releases the request slot
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// CheckUser reports whether a user's feed exists without downloading and
+// parsing its items. A HEAD request is tried first, falling back to a GET
+// whose body is discarded if HEAD is not supported.
+func (s *BookmarkService) CheckUser(ctx context.Context, username string) (*types.CheckUserResponse, error) {
+	username = strings.TrimSpace(username)
+	if err := s.validator.ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	requestURL, err := s.buildRequestURL(types.GetHatenaBookmarksParams{Username: username})
+	if err != nil {
+		return nil, err
+	}
 
-func isValidUsername(username string) bool {
-	// Username should contain only alphanumeric characters and hyphens
-	for _, r := range username {
-		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
-			return false
+	status, err := s.probe(ctx, http.MethodHead, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented {
+		if status, err = s.probe(ctx, http.MethodGet, requestURL); err != nil {
+			return nil, err
 		}
 	}
-	return len(username) > 0
+
+	response := &types.CheckUserResponse{User: username, StatusCode: status}
+	switch status {
+	case http.StatusOK:
+		exists := true
+		response.Exists = &exists
+	case http.StatusNotFound:
+		exists := false
+		response.Exists = &exists
+	}
+
+	s.log(ctx).Info("Checked user feed", "username", username, "status_code", status)
+
+	return response, nil
+}
+
+// probe issues a request and returns its status code, discarding the body
+func (s *BookmarkService) probe(ctx context.Context, method, requestURL string) (int, error) {
+	req, err := s.newRequest(ctx, method, requestURL)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.send(req)
+	if err != nil {
+		return 0, err
+	}
+	if err := resp.Body.Close(); err != nil {
+		s.log(ctx).Debug("Failed to close response body", "error", err)
+	}
+
+	return resp.StatusCode, nil
 }
 
-func isValidDateFormat(date string) bool {
-	// Check if date is in YYYYMMDD format
-	if len(date) != 8 {
-		return false
+// checkHost rejects request URLs whose host is not in the allowlist,
+// protecting deployments against a base URL pointing at internal addresses
+func (s *BookmarkService) checkHost(ctx context.Context, requestURL string) error {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return (&types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("Invalid request URL: %v", err),
+			Details: map[string]interface{}{"url": requestURL},
+		}).WithCause(err)
 	}
-	
-	for _, r := range date {
-		if r < '0' || r > '9' {
-			return false
+
+	if !s.options.isAllowedHost(u.Hostname()) {
+		s.log(ctx).Warn("Blocked request to disallowed host", "host", u.Hostname())
+		return &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("Requests to host %q are not allowed", u.Hostname()),
+			Details: map[string]interface{}{"host": u.Hostname()},
 		}
 	}
-	
-	// Additional validation could be added here to check if it's a valid date
-	return true
+
+	return nil
+}
+
+// acquireRequestSlot blocks until an HTTP request may be issued without
+// exceeding MaxConcurrentRequests. The returned function releases the slot.
+func (s *BookmarkService) acquireRequestSlot(ctx context.Context) (func(), error) {
+	select {
+	case s.requestSlots <- struct{}{}:
+		return func() { <-s.requestSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// getPageOrDefault returns the page number or default value
+func (s *BookmarkService) getPageOrDefault(page int) int {
+	if page <= 0 {
+		return 1
+	}
+	return page
 }
 
-func isValidURL(urlStr string) bool {
-	// Basic URL validation
-	u, err := url.Parse(urlStr)
-	return err == nil && u.Scheme != "" && u.Host != ""
-}
\ No newline at end of file
+// log returns the service logger annotated with the request ID carried by
+// ctx, so that all log lines of one tool call can be grouped
+func (s *BookmarkService) log(ctx context.Context) *slog.Logger {
+	return utils.LoggerFromContext(ctx, s.logger)
+}
@@ -0,0 +1,2178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+	"hatena-bookmark-mcp/internal/utils"
+)
+
+// testLogger returns a logger that discards its output
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// newTestService starts an httptest server with handler and returns a
+// service fetching from it. Requests to the server's host are allowed, so
+// the host allowlist still applies to every other host.
+func newTestService(t *testing.T, handler http.Handler, options Options) *BookmarkService {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	options.BaseURL = server.URL
+	options.AllowedHosts = append(options.AllowedHosts, "127.0.0.1")
+
+	s := NewBookmarkServiceWithOptions(testLogger(), options)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// testClock is a manually advanced clock for cache tests
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// newTestClock returns a clock set to a fixed time
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 2, 10, 9, 0, 0, 0, time.UTC)}
+}
+
+// Now returns the current test time
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testItem describes a feed item served by the test server
+type testItem struct {
+	Title   string
+	Link    string
+	Date    string // dc:date; defaults to 2024-02-10T09:00:00+09:00
+	Comment string
+	Tags    []string
+	Count   int
+}
+
+// rdfFeed renders items as a Hatena-style RDF feed
+func rdfFeed(items ...testItem) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns="http://purl.org/rss/1.0/"
+ xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:hatena="http://www.hatena.ne.jp/info/xmlns#">
+<channel rdf:about="https://b.hatena.ne.jp/alice/bookmark"><title>alice's bookmarks</title><link>https://b.hatena.ne.jp/alice/bookmark</link></channel>
+`)
+	for _, item := range items {
+		date := item.Date
+		if date == "" {
+			date = "2024-02-10T09:00:00+09:00"
+		}
+		fmt.Fprintf(&b, `<item rdf:about="%[2]s"><title>%[1]s</title><link>%[2]s</link><description>%[3]s</description><dc:date>%[4]s</dc:date>`,
+			item.Title, item.Link, item.Comment, date)
+		for _, tag := range item.Tags {
+			fmt.Fprintf(&b, `<dc:subject>%s</dc:subject>`, tag)
+		}
+		fmt.Fprintf(&b, "<hatena:bookmarkcount>%d</hatena:bookmarkcount></item>\n", item.Count)
+	}
+	b.WriteString(`</rdf:RDF>`)
+	return b.String()
+}
+
+// serveFeed returns a handler serving feed for every request, counting the
+// requests in hits if it is not nil
+func serveFeed(feed string, hits *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hits != nil {
+			hits.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		io.WriteString(w, feed)
+	}
+}
+
+// pagedFeeds returns a handler serving pages[n-1] for ?page=n, and an empty
+// feed past the last page
+func pagedFeeds(pages ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			fmt.Sscanf(p, "%d", &page)
+		}
+		if page < 1 || page > len(pages) {
+			io.WriteString(w, rdfFeed())
+			return
+		}
+		io.WriteString(w, pages[page-1])
+	}
+}
+
+// mustGetBookmarks calls GetBookmarks, failing the test on error
+func mustGetBookmarks(t *testing.T, s *BookmarkService, params types.GetHatenaBookmarksParams) *types.GetHatenaBookmarksResponse {
+	t.Helper()
+
+	response, err := s.GetBookmarks(context.Background(), params)
+	if err != nil {
+		t.Fatalf("GetBookmarks() error = %v", err)
+	}
+	return response
+}
+
+// bookmarkURLs returns the URLs of bookmarks in order
+func bookmarkURLs(bookmarks []types.BookmarkItem) []string {
+	urls := make([]string, len(bookmarks))
+	for i, bookmark := range bookmarks {
+		urls[i] = bookmark.URL
+	}
+	return urls
+}
+
+func TestGetBookmarksAppliesParserOptions(t *testing.T) {
+	feed := rdfFeed(testItem{
+		Title: "An article with a rather long title",
+		Link:  "https://example.com/a",
+		Tags:  []string{"go", "rss", "xml"},
+	})
+	s := newTestService(t, serveFeed(feed, nil), Options{MaxTagsPerItem: 2, MaxTitleLength: 10})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+
+	bookmark := response.Bookmarks[0]
+	if want := []string{"go", "rss"}; !reflect.DeepEqual(bookmark.Tags, want) {
+		t.Errorf("Tags = %q, want %q", bookmark.Tags, want)
+	}
+	if bookmark.Title != "An articl…" {
+		t.Errorf("Title = %q, want the title cut to 10 runes", bookmark.Title)
+	}
+}
+
+func TestGetBookmarksSchemaVersion(t *testing.T) {
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a"})
+	s := newTestService(t, serveFeed(feed, nil), Options{})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"schema_version":"` + types.SchemaVersion + `",`; !strings.HasPrefix(string(encoded), want) {
+		t.Errorf("response = %s, want it to start with %s", encoded, want)
+	}
+}
+
+func TestGetBookmarksGroupByDate(t *testing.T) {
+	feed := rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Date: "2024-02-10T09:00:00+09:00"},
+		testItem{Title: "B", Link: "https://example.com/b", Date: "2024-02-09T09:00:00+09:00"},
+	)
+	s := newTestService(t, serveFeed(feed, nil), Options{})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", GroupByDate: true})
+
+	if response.Bookmarks != nil {
+		t.Errorf("Bookmarks = %v, want nil when grouped", response.Bookmarks)
+	}
+	if len(response.DateGroups) != 2 || response.DateGroups[0].Date != "2024-02-10" {
+		t.Errorf("DateGroups = %+v, want two groups, newest first", response.DateGroups)
+	}
+	if response.TotalCount != 2 {
+		t.Errorf("TotalCount = %d, want 2", response.TotalCount)
+	}
+}
+
+func TestGetAllTagged(t *testing.T) {
+	queryFeed := rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"go"}},
+		testItem{Title: "B", Link: "https://example.com/b", Tags: []string{"go"}},
+	)
+	pathFeed := rdfFeed(
+		testItem{Title: "B", Link: "https://example.com/b", Tags: []string{"go"}},
+		testItem{Title: "C", Link: "https://example.com/c", Tags: []string{"go"}},
+	)
+	var paths []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/alice/rss":
+			io.WriteString(w, queryFeed)
+		case "/alice/go/rss":
+			io.WriteString(w, pathFeed)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	s := newTestService(t, http.HandlerFunc(handler), Options{})
+
+	response, err := s.GetAllTagged(context.Background(), "alice", "go")
+	if err != nil {
+		t.Fatalf("GetAllTagged() error = %v", err)
+	}
+
+	want := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}
+	if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, want) {
+		t.Errorf("URLs = %q, want %q", got, want)
+	}
+	if response.TotalCount != 3 || !response.Filtered || response.Filters.Tag != "go" {
+		t.Errorf("TotalCount = %d, Filtered = %v, Filters = %+v", response.TotalCount, response.Filtered, response.Filters)
+	}
+	if wantPaths := []string{"/alice/rss?tag=go", "/alice/go/rss"}; !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("requested %q, want %q", paths, wantPaths)
+	}
+}
+
+func TestGetAllTaggedRequiresTag(t *testing.T) {
+	s := newTestService(t, serveFeed(rdfFeed(), nil), Options{})
+
+	_, err := s.GetAllTagged(context.Background(), "alice", "  ")
+	if !errors.Is(err, types.ErrValidation) {
+		t.Errorf("GetAllTagged() error = %v, want a validation error", err)
+	}
+}
+
+func TestGetBookmarksURLFilter(t *testing.T) {
+	var query string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("url")
+		io.WriteString(w, rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}))
+	}
+	s := newTestService(t, http.HandlerFunc(handler), Options{})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", URL: "  https://example.com/a\n"})
+	if query != "https://example.com/a" {
+		t.Errorf("url query = %q, want the trimmed filter", query)
+	}
+	if response.Filters == nil || response.Filters.URL != "https://example.com/a" {
+		t.Errorf("Filters = %+v, want the trimmed filter", response.Filters)
+	}
+
+	_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice", URL: "ftp://example.com/"})
+	if !errors.Is(err, types.ErrValidation) {
+		t.Errorf("GetBookmarks() error = %v, want a validation error", err)
+	}
+}
+
+func TestGetBookmarksSortByScore(t *testing.T) {
+	feed := rdfFeed(
+		testItem{Title: "Old popular", Link: "https://example.com/old", Date: "2023-01-01T00:00:00Z", Count: 1000},
+		testItem{Title: "New", Link: "https://example.com/new", Date: "2024-02-29T00:00:00Z", Count: 10},
+		testItem{Title: "New popular", Link: "https://example.com/popular", Date: "2024-02-28T00:00:00Z", Count: 500},
+	)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestService(t, serveFeed(feed, nil), Options{Clock: func() time.Time { return now }})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", SortBy: SortByScore})
+
+	want := []string{"https://example.com/popular", "https://example.com/new", "https://example.com/old"}
+	if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+	for _, bookmark := range response.Bookmarks {
+		if bookmark.Score <= 0 {
+			t.Errorf("%s: Score = %v, want it set", bookmark.URL, bookmark.Score)
+		}
+	}
+}
+
+func TestGetTagCountsFirstSeen(t *testing.T) {
+	s := newTestService(t, pagedFeeds(
+		rdfFeed(
+			testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"go"}, Date: "2024-02-10T09:00:00+09:00"},
+			testItem{Title: "B", Link: "https://example.com/b", Tags: []string{"go", "xml"}, Date: "2024-01-15T09:00:00+09:00"},
+		),
+		// Older bookmarks are on later pages; the earliest date must win
+		rdfFeed(
+			testItem{Title: "C", Link: "https://example.com/c", Tags: []string{"go"}, Date: "2023-06-01T12:00:00Z"},
+			testItem{Title: "D", Link: "https://example.com/d", Tags: []string{"xml"}, Date: "2023-12-31T23:00:00-05:00"},
+		),
+	), Options{})
+
+	response, err := s.GetTagCounts(context.Background(), "alice", 0, true)
+	if err != nil {
+		t.Fatalf("GetTagCounts() error = %v", err)
+	}
+
+	want := []types.TagCount{
+		{Tag: "go", Count: 3, FirstSeen: "2023-06-01T12:00:00Z"},
+		{Tag: "xml", Count: 2, FirstSeen: "2023-12-31T23:00:00-05:00"},
+	}
+	if response.User != "alice" || response.TotalBookmarks != 4 || !reflect.DeepEqual(response.Tags, want) {
+		t.Errorf("response = %+v, want tags %v", response, want)
+	}
+
+	// first_seen is only computed on request
+	response, err = s.GetTagCounts(context.Background(), "alice", 1, false)
+	if err != nil {
+		t.Fatalf("GetTagCounts() error = %v", err)
+	}
+	if !reflect.DeepEqual(response.Tags, []types.TagCount{{Tag: "go", Count: 3}}) {
+		t.Errorf("Tags = %v, want go without first_seen", response.Tags)
+	}
+
+	if _, err := s.GetTagCounts(context.Background(), "alice", MaxTagCountsTopN+1, false); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("top_n over the limit error = %v, want a validation error", err)
+	}
+}
+
+func TestGetBookmarksCache(t *testing.T) {
+	clock := newTestClock()
+	var hits atomic.Int32
+	s := newTestService(t, serveFeed(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}), &hits),
+		Options{Clock: clock.Now, CacheTTL: 5 * time.Minute})
+	params := types.GetHatenaBookmarksParams{Username: "alice", Page: 1}
+
+	first := mustGetBookmarks(t, s, params)
+	// Changing a response must not change what the cache serves
+	first.Bookmarks[0].Title = "changed"
+
+	second := mustGetBookmarks(t, s, params)
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("server hits = %d, want the second call served from the cache", got)
+	}
+	if second.Bookmarks[0].Title != "A" {
+		t.Errorf("cached title = %q, want A", second.Bookmarks[0].Title)
+	}
+
+	// Different parameters are cached separately
+	mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Page: 2})
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server hits = %d, want another page fetched", got)
+	}
+
+	// NoCache fetches again and refreshes the entry for later calls
+	noCache := params
+	noCache.NoCache = true
+	mustGetBookmarks(t, s, noCache)
+	if got := hits.Load(); got != 3 {
+		t.Errorf("server hits = %d, want no_cache to bypass the cache", got)
+	}
+	clock.Advance(4 * time.Minute)
+	mustGetBookmarks(t, s, params)
+	if got := hits.Load(); got != 3 {
+		t.Errorf("server hits = %d, want the entry stored by no_cache served", got)
+	}
+
+	clock.Advance(time.Minute)
+	mustGetBookmarks(t, s, params)
+	if got := hits.Load(); got != 4 {
+		t.Errorf("server hits = %d, want a refetch after CacheTTL", got)
+	}
+}
+
+func TestGetBookmarksCacheDisabled(t *testing.T) {
+	var hits atomic.Int32
+	s := newTestService(t, serveFeed(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}), &hits), Options{CacheTTL: -1})
+	params := types.GetHatenaBookmarksParams{Username: "alice", Page: 1}
+
+	mustGetBookmarks(t, s, params)
+	mustGetBookmarks(t, s, params)
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server hits = %d, want every call fetched", got)
+	}
+}
+
+func TestBuildTagPathURL(t *testing.T) {
+	s := NewBookmarkServiceWithOptions(testLogger(), Options{})
+	defer s.Close()
+
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{"go", "https://b.hatena.ne.jp/alice/go/rss", false},
+		{"あとで読む", "https://b.hatena.ne.jp/alice/%E3%81%82%E3%81%A8%E3%81%A7%E8%AA%AD%E3%82%80/rss", false},
+		{"c/c++", "https://b.hatena.ne.jp/alice/c%2Fc++/rss", false},
+		{"../admin", "https://b.hatena.ne.jp/alice/..%2Fadmin/rss", false},
+		{"a?b#c", "https://b.hatena.ne.jp/alice/a%3Fb%23c/rss", false},
+		{"new\nline", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := s.buildTagPathURL("alice", tt.tag)
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrValidation) {
+					t.Errorf("error = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("buildTagPathURL() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildRequestURLEscapesQuery(t *testing.T) {
+	s := NewBookmarkServiceWithOptions(testLogger(), Options{})
+	defer s.Close()
+
+	got, err := s.buildRequestURL(types.GetHatenaBookmarksParams{Username: "alice", Tag: "a&b=c", Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://b.hatena.ne.jp/alice/rss?page=2&tag=a%26b%3Dc"; got != want {
+		t.Errorf("buildRequestURL() = %q, want %q", got, want)
+	}
+}
+
+func TestGetTodaysBookmarksUsesJST(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2024, 2, 10, 14, 59, 0, 0, time.UTC), "20240210"},
+		// Already the next day in Japan
+		{time.Date(2024, 2, 10, 15, 0, 0, 0, time.UTC), "20240211"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.now.Format(time.RFC3339), func(t *testing.T) {
+			var date string
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				date = r.URL.Query().Get("date")
+				io.WriteString(w, rdfFeed())
+			}
+			s := newTestService(t, http.HandlerFunc(handler), Options{Clock: func() time.Time { return tt.now }})
+
+			response, err := s.GetTodaysBookmarks(context.Background(), "alice")
+			if err != nil {
+				t.Fatalf("GetTodaysBookmarks() error = %v", err)
+			}
+			if date != tt.want {
+				t.Errorf("requested date = %q, want %q", date, tt.want)
+			}
+			if response.Filters == nil || response.Filters.Date != tt.want {
+				t.Errorf("Filters = %+v, want date %s", response.Filters, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBookmarkStatsFetchesAllPages(t *testing.T) {
+	s := newTestService(t, pagedFeeds(
+		rdfFeed(
+			testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"go"}, Comment: "read"},
+			testItem{Title: "B", Link: "https://example.com/b", Tags: []string{"go", "xml"}},
+		),
+		rdfFeed(
+			// Repeated across pages, counted once
+			testItem{Title: "B", Link: "https://example.com/b", Tags: []string{"go", "xml"}},
+			testItem{Title: "C", Link: "https://go.dev/c"},
+		),
+	), Options{})
+
+	stats, err := s.GetBookmarkStats(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("GetBookmarkStats() error = %v", err)
+	}
+
+	if stats.User != "alice" || stats.TotalBookmarks != 3 || stats.DistinctTags != 2 || stats.DistinctDomains != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestFetchAllProgressLogInterval(t *testing.T) {
+	var pages []string
+	for i := 1; i <= 5; i++ {
+		link := fmt.Sprintf("https://example.com/%d", i)
+		pages = append(pages, rdfFeed(testItem{Title: link, Link: link}))
+	}
+	s := newTestService(t, pagedFeeds(pages...), Options{ProgressLogInterval: 2})
+	var logs bytes.Buffer
+	s.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	if _, err := s.FetchAll(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"}); err != nil {
+		t.Fatalf("FetchAll() error = %v", err)
+	}
+
+	var logged []string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "Fetched page ") {
+			logged = append(logged, line[strings.Index(line, "Fetched page "):strings.Index(line, " bookmarks so far")])
+		}
+	}
+	if want := []string{"Fetched page 2, 2", "Fetched page 4, 4"}; !reflect.DeepEqual(logged, want) {
+		t.Errorf("progress lines = %q, want %q", logged, want)
+	}
+}
+
+func TestGetBookmarksCachesEmptyResultBriefly(t *testing.T) {
+	clock := newTestClock()
+	var hits atomic.Int32
+	s := newTestService(t, serveFeed(rdfFeed(), &hits), Options{
+		Clock:            clock.Now,
+		CacheTTL:         5 * time.Minute,
+		CacheNegativeTTL: 30 * time.Second,
+	})
+	params := types.GetHatenaBookmarksParams{Username: "alice", Page: 99}
+
+	mustGetBookmarks(t, s, params)
+	mustGetBookmarks(t, s, params)
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("server hits = %d, want the empty result cached", got)
+	}
+
+	clock.Advance(30 * time.Second)
+	mustGetBookmarks(t, s, params)
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server hits = %d, want a refetch after CacheNegativeTTL", got)
+	}
+}
+
+func TestGetBookmarksCachesMissingFeed(t *testing.T) {
+	var hits atomic.Int32
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.NotFound(w, r)
+	}), Options{})
+	params := types.GetHatenaBookmarksParams{Username: "nobody"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.GetBookmarks(context.Background(), params); !errors.Is(err, types.ErrAPI) {
+			t.Fatalf("GetBookmarks() error = %v, want an API error", err)
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hits = %d, want the 404 cached", got)
+	}
+}
+
+func TestGetBookmarksDoesNotCacheServerErrors(t *testing.T) {
+	var hits atomic.Int32
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}), Options{})
+	params := types.GetHatenaBookmarksParams{Username: "alice"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.GetBookmarks(context.Background(), params); err == nil {
+			t.Fatal("GetBookmarks() succeeded, want an error")
+		}
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server hits = %d, want every request to reach the server", got)
+	}
+}
+
+func TestSuggestTags(t *testing.T) {
+	var requestedTag string
+	feed := rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"go", "programming"}},
+		testItem{Title: "B", Link: "https://example.com/b", Tags: []string{"programming", "go", "release"}},
+		testItem{Title: "C", Link: "https://example.com/c", Tags: []string{"go", "programming"}},
+	)
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			requestedTag = r.URL.Query().Get("tag")
+			io.WriteString(w, feed)
+			return
+		}
+		io.WriteString(w, rdfFeed())
+	}), Options{})
+
+	response, err := s.SuggestTags(context.Background(), "alice", " go ", 1)
+	if err != nil {
+		t.Fatalf("SuggestTags() error = %v", err)
+	}
+	if requestedTag != "go" {
+		t.Errorf("requested tag = %q, want go", requestedTag)
+	}
+	if want := []types.TagCount{{Tag: "programming", Count: 3}}; !reflect.DeepEqual(response.Suggestions, want) {
+		t.Errorf("Suggestions = %v, want %v", response.Suggestions, want)
+	}
+}
+
+func TestSuggestTagsValidation(t *testing.T) {
+	s := NewBookmarkServiceWithOptions(testLogger(), Options{})
+	defer s.Close()
+
+	tests := []struct {
+		name string
+		tag  string
+		topN int
+	}{
+		{"missing tag", " ", 0},
+		{"negative top_n", "go", -1},
+		{"top_n above maximum", "go", MaxSuggestTagsTopN + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.SuggestTags(context.Background(), "alice", tt.tag, tt.topN); !errors.Is(err, types.ErrValidation) {
+				t.Errorf("error = %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+func TestInvalidateCache(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		io.WriteString(w, rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}))
+	}), Options{})
+
+	for _, username := range []string{"bob", "bobby", "bob", "bobby"} {
+		mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: username})
+	}
+
+	response, err := s.InvalidateCache(context.Background(), " bob ")
+	if err != nil {
+		t.Fatalf("InvalidateCache() error = %v", err)
+	}
+	if response.User != "bob" || response.Invalidated != 1 {
+		t.Errorf("response = %+v, want bob with 1 entry invalidated", response)
+	}
+
+	mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "bob"})
+	mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "bobby"})
+	if want := map[string]int{"/bob/rss": 2, "/bobby/rss": 1}; !reflect.DeepEqual(hits, want) {
+		t.Errorf("server hits = %v, want %v", hits, want)
+	}
+}
+
+func TestGetBookmarksErrorOnEmpty(t *testing.T) {
+	// Hatena applies the url filter, so the server does the filtering here
+	filtering := func(feed string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body := feed
+			if r.URL.Query().Get("url") != "" {
+				body = rdfFeed()
+			}
+			io.WriteString(w, body)
+		}
+	}
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a"})
+
+	tests := []struct {
+		name         string
+		feed         string
+		url          string
+		errorOnEmpty bool
+		wantErr      bool
+	}{
+		{"empty, toggle off", rdfFeed(), "", false, false},
+		{"empty, toggle on", rdfFeed(), "", true, true},
+		{"filtered to empty, toggle off", feed, "https://example.com/other", false, false},
+		{"filtered to empty, toggle on", feed, "https://example.com/other", true, true},
+		{"not empty, toggle on", feed, "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, filtering(tt.feed), Options{})
+
+			response, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{
+				Username:     "alice",
+				URL:          tt.url,
+				ErrorOnEmpty: tt.errorOnEmpty,
+			})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("GetBookmarks() error = %v", err)
+				}
+				if response.Bookmarks == nil {
+					t.Error("Bookmarks = nil, want an empty list")
+				}
+				return
+			}
+
+			var mcpErr *types.MCPError
+			if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeAPI || mcpErr.Message != "no bookmarks found" {
+				t.Errorf("error = %v, want API_ERROR \"no bookmarks found\"", err)
+			}
+		})
+	}
+}
+
+func TestCheckUser(t *testing.T) {
+	exists, missing := true, false
+
+	tests := []struct {
+		name        string
+		headStatus  int
+		getStatus   int
+		wantStatus  int
+		wantExists  *bool
+		wantMethods []string
+	}{
+		{"exists", http.StatusOK, 0, http.StatusOK, &exists, []string{"HEAD"}},
+		{"missing", http.StatusNotFound, 0, http.StatusNotFound, &missing, []string{"HEAD"}},
+		{"unknown", http.StatusServiceUnavailable, 0, http.StatusServiceUnavailable, nil, []string{"HEAD"}},
+		{"HEAD not allowed", http.StatusMethodNotAllowed, http.StatusOK, http.StatusOK, &exists, []string{"HEAD", "GET"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var methods []string
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				if r.Method == http.MethodHead {
+					w.WriteHeader(tt.headStatus)
+					return
+				}
+				w.WriteHeader(tt.getStatus)
+				io.WriteString(w, rdfFeed())
+			}
+			s := newTestService(t, http.HandlerFunc(handler), Options{})
+
+			response, err := s.CheckUser(context.Background(), " alice ")
+			if err != nil {
+				t.Fatalf("CheckUser() error = %v", err)
+			}
+			if response.User != "alice" || response.StatusCode != tt.wantStatus {
+				t.Errorf("response = %+v, want alice with status %d", response, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(response.Exists, tt.wantExists) {
+				t.Errorf("Exists = %v, want %v", response.Exists, tt.wantExists)
+			}
+			if !reflect.DeepEqual(methods, tt.wantMethods) {
+				t.Errorf("methods = %q, want %q", methods, tt.wantMethods)
+			}
+		})
+	}
+}
+
+func TestCheckUserValidatesUsername(t *testing.T) {
+	var hits atomic.Int32
+	s := newTestService(t, serveFeed(rdfFeed(), &hits), Options{})
+
+	if _, err := s.CheckUser(context.Background(), "../admin"); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("error = %v, want a validation error", err)
+	}
+	if hits.Load() != 0 {
+		t.Error("an invalid username reached the server")
+	}
+}
+
+func TestGetCommentKeywords(t *testing.T) {
+	s := newTestService(t, pagedFeeds(rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Comment: "Go generics"},
+		testItem{Title: "B", Link: "https://example.com/b", Comment: "generics again"},
+	)), Options{})
+
+	response, err := s.GetCommentKeywords(context.Background(), "alice", 1, 0)
+	if err != nil {
+		t.Fatalf("GetCommentKeywords() error = %v", err)
+	}
+	if want := []types.WordCount{{Word: "generics", Count: 2}}; !reflect.DeepEqual(response.Keywords, want) {
+		t.Errorf("Keywords = %v, want %v", response.Keywords, want)
+	}
+
+	for _, args := range [][2]int{{-1, 0}, {MaxCommentKeywordsTopN + 1, 0}, {0, -1}} {
+		if _, err := s.GetCommentKeywords(context.Background(), "alice", args[0], args[1]); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("top_n %d, min_length %d: error = %v, want a validation error", args[0], args[1], err)
+		}
+	}
+}
+
+func TestGetAllTaggedUnionsTags(t *testing.T) {
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tags := []string{"go", "a", "b"}
+		if r.URL.Path == "/alice/go/rss" {
+			tags = []string{"go", "B", "c"}
+		}
+		io.WriteString(w, rdfFeed(testItem{Title: "X", Link: "https://example.com/x", Tags: tags}))
+	}), Options{})
+
+	response, err := s.GetAllTagged(context.Background(), "alice", "go")
+	if err != nil {
+		t.Fatalf("GetAllTagged() error = %v", err)
+	}
+	if len(response.Bookmarks) != 1 {
+		t.Fatalf("got %d bookmarks, want 1", len(response.Bookmarks))
+	}
+	if want := []string{"go", "a", "b", "c"}; !reflect.DeepEqual(response.Bookmarks[0].Tags, want) {
+		t.Errorf("Tags = %q, want %q", response.Bookmarks[0].Tags, want)
+	}
+}
+
+// stallingFeeds returns a handler serving a one-item feed for pages before
+// stallPage and stalling on later pages. Fetches are shared and outlive the
+// caller that gave up, so the stall is bounded to let the server close.
+func stallingFeeds(stallPage int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := 1
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		if page >= stallPage {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(500 * time.Millisecond):
+			}
+			return
+		}
+		link := fmt.Sprintf("https://example.com/%d", page)
+		io.WriteString(w, rdfFeed(testItem{Title: link, Link: link}))
+	}
+}
+
+func TestFetchAllTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{"page timeout", Options{PageTimeout: 50 * time.Millisecond, FetchAllTimeout: time.Minute}, "page 3 request timed out"},
+		{"overall timeout", Options{PageTimeout: time.Minute, FetchAllTimeout: 100 * time.Millisecond}, "overall fetch deadline exceeded after 2 pages"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, stallingFeeds(3), tt.options)
+
+			_, err := s.FetchAll(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+
+			var mcpErr *types.MCPError
+			if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeNetwork {
+				t.Fatalf("error = %v, want a network error", err)
+			}
+			if mcpErr.Message != tt.want {
+				t.Errorf("Message = %q, want %q", mcpErr.Message, tt.want)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want it to wrap context.DeadlineExceeded", err)
+			}
+		})
+	}
+}
+
+func TestGetBookmarksCollapseDuplicateTitles(t *testing.T) {
+	feed := rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"go"}},
+		testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"xml"}},
+		testItem{Title: "A", Link: "https://example.com/other"},
+	)
+
+	for _, collapse := range []bool{false, true} {
+		s := newTestService(t, serveFeed(feed, nil), Options{})
+
+		response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", CollapseDuplicateTitles: collapse})
+
+		want := 3
+		if collapse {
+			want = 2
+		}
+		if response.TotalCount != want || len(response.Bookmarks) != want {
+			t.Errorf("collapse %v: TotalCount = %d with %d bookmarks, want %d", collapse, response.TotalCount, len(response.Bookmarks), want)
+		}
+	}
+}
+
+func TestGetReadLater(t *testing.T) {
+	feed := rdfFeed(
+		testItem{Title: "Fresh", Link: "https://example.com/fresh", Date: "2024-02-28T09:00:00+09:00", Tags: []string{"あとで読む"}},
+		testItem{Title: "Stale", Link: "https://example.com/stale", Date: "2024-01-10T09:00:00+09:00", Tags: []string{"あとで読む", "go"}},
+		testItem{Title: "Older", Link: "https://example.com/older", Date: "2023-12-01T09:00:00+09:00", Tags: []string{"go", "あとで読む"}},
+		// Loosely matched by the feed, but without the tag
+		testItem{Title: "Untagged", Link: "https://example.com/untagged", Date: "2023-11-01T09:00:00+09:00", Tags: []string{"go"}},
+	)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		olderThanDays int
+		want          []string
+	}{
+		{"all, oldest first", 0, []string{"https://example.com/older", "https://example.com/stale", "https://example.com/fresh"}},
+		{"stale only", 30, []string{"https://example.com/older", "https://example.com/stale"}},
+		{"nothing that old", 365, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestedTag string
+			s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+					io.WriteString(w, rdfFeed())
+					return
+				}
+				requestedTag = r.URL.Query().Get("tag")
+				io.WriteString(w, feed)
+			}), Options{Clock: func() time.Time { return now }})
+
+			response, err := s.GetReadLater(context.Background(), "alice", tt.olderThanDays)
+			if err != nil {
+				t.Fatalf("GetReadLater() error = %v", err)
+			}
+			if requestedTag != DefaultReadLaterTag {
+				t.Errorf("requested tag = %q, want %q", requestedTag, DefaultReadLaterTag)
+			}
+			if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bookmarks = %q, want %q", got, tt.want)
+			}
+			if response.Count != len(tt.want) || response.Tag != DefaultReadLaterTag {
+				t.Errorf("Count = %d, Tag = %q", response.Count, response.Tag)
+			}
+		})
+	}
+}
+
+func TestGetReadLaterCustomTag(t *testing.T) {
+	var requestedTag string
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedTag = r.URL.Query().Get("tag")
+		io.WriteString(w, rdfFeed())
+	}), Options{ReadLaterTag: "toread"})
+
+	response, err := s.GetReadLater(context.Background(), "alice", 0)
+	if err != nil {
+		t.Fatalf("GetReadLater() error = %v", err)
+	}
+	if requestedTag != "toread" || response.Bookmarks == nil || response.Count != 0 {
+		t.Errorf("requested tag %q, response = %+v, want an empty list for toread", requestedTag, response)
+	}
+
+	if _, err := s.GetReadLater(context.Background(), "alice", -1); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("error = %v, want a validation error", err)
+	}
+}
+
+func TestSharedCacheIsolatesConfigurations(t *testing.T) {
+	cache := utils.NewCache(time.Minute, nil)
+	params := types.GetHatenaBookmarksParams{Username: "alice"}
+
+	var hitsA, hitsB atomic.Int32
+	a := newTestService(t, serveFeed(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}), &hitsA), Options{Cache: cache})
+	b := newTestService(t, serveFeed(rdfFeed(testItem{Title: "B", Link: "https://example.com/b"}), &hitsB), Options{Cache: cache})
+
+	if got := mustGetBookmarks(t, a, params).Bookmarks[0].Title; got != "A" {
+		t.Errorf("service A got %q, want A", got)
+	}
+	if got := mustGetBookmarks(t, b, params).Bookmarks[0].Title; got != "B" {
+		t.Errorf("service B got %q, want its own feed, not A's cached result", got)
+	}
+	mustGetBookmarks(t, a, params)
+	if hitsA.Load() != 1 || hitsB.Load() != 1 {
+		t.Errorf("server hits = %d, %d, want each fetched once", hitsA.Load(), hitsB.Load())
+	}
+	if cache.Len() != 2 {
+		t.Errorf("cache holds %d entries, want 2", cache.Len())
+	}
+}
+
+func TestSharedCacheIsolatesCredentials(t *testing.T) {
+	cache := utils.NewCache(time.Minute, nil)
+	var hits atomic.Int32
+	server := httptest.NewServer(serveFeed(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}), &hits))
+	defer server.Close()
+
+	newService := func(token string) *BookmarkService {
+		s := NewBookmarkServiceWithOptions(testLogger(), Options{
+			BaseURL:      server.URL,
+			AllowedHosts: []string{"127.0.0.1"},
+			AuthMode:     AuthModeBearer,
+			AuthToken:    token,
+			Cache:        cache,
+		})
+		t.Cleanup(s.Close)
+		return s
+	}
+	params := types.GetHatenaBookmarksParams{Username: "alice"}
+
+	mustGetBookmarks(t, newService("token-1"), params)
+	mustGetBookmarks(t, newService("token-1"), params)
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hits = %d, want services with the same credentials to share entries", got)
+	}
+	mustGetBookmarks(t, newService("token-2"), params)
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server hits = %d, want other credentials to fetch their own", got)
+	}
+}
+
+func TestInvalidateCacheKeepsOtherNamespaces(t *testing.T) {
+	cache := utils.NewCache(time.Minute, nil)
+	params := types.GetHatenaBookmarksParams{Username: "bob"}
+
+	var hitsA, hitsB atomic.Int32
+	a := newTestService(t, serveFeed(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}), &hitsA), Options{Cache: cache})
+	b := newTestService(t, serveFeed(rdfFeed(testItem{Title: "B", Link: "https://example.com/b"}), &hitsB), Options{Cache: cache})
+	mustGetBookmarks(t, a, params)
+	mustGetBookmarks(t, b, params)
+
+	response, err := a.InvalidateCache(context.Background(), "bob")
+	if err != nil {
+		t.Fatalf("InvalidateCache() error = %v", err)
+	}
+	if response.Invalidated != 1 {
+		t.Errorf("Invalidated = %d, want only service A's entry", response.Invalidated)
+	}
+
+	mustGetBookmarks(t, a, params)
+	mustGetBookmarks(t, b, params)
+	if hitsA.Load() != 2 || hitsB.Load() != 1 {
+		t.Errorf("server hits = %d, %d, want only service A to refetch", hitsA.Load(), hitsB.Load())
+	}
+}
+
+func TestGetDomainCount(t *testing.T) {
+	s := newTestService(t, pagedFeeds(
+		rdfFeed(
+			testItem{Title: "1", Link: "https://go.dev/1"},
+			testItem{Title: "2", Link: "https://example.com/2"},
+		),
+		rdfFeed(
+			testItem{Title: "3", Link: "https://go.dev/3"},
+			testItem{Title: "4", Link: "https://go.dev/4"},
+			testItem{Title: "5", Link: "https://example.org/5"},
+		),
+	), Options{})
+
+	response, err := s.GetDomainCount(context.Background(), "alice", false)
+	if err != nil {
+		t.Fatalf("GetDomainCount() error = %v", err)
+	}
+
+	if response.TotalBookmarks != 5 || response.DistinctDomains != 3 || response.GroupBy != GroupByHost {
+		t.Errorf("response = %+v", response)
+	}
+	want := []types.DomainCount{{Domain: "go.dev", Count: 3}, {Domain: "example.com", Count: 1}, {Domain: "example.org", Count: 1}}
+	if !reflect.DeepEqual(response.TopDomains, want) {
+		t.Errorf("TopDomains = %v, want %v", response.TopDomains, want)
+	}
+}
+
+func TestGetDomainCountEmptyFeed(t *testing.T) {
+	s := newTestService(t, serveFeed(rdfFeed(), nil), Options{})
+
+	response, err := s.GetDomainCount(context.Background(), "alice", false)
+	if err != nil {
+		t.Fatalf("GetDomainCount() error = %v", err)
+	}
+	if response.TotalBookmarks != 0 || response.DistinctDomains != 0 || response.TopDomains == nil {
+		t.Errorf("response = %+v, want zero counts and an empty list", response)
+	}
+}
+
+// numberedPages returns count feed pages of two bookmarks each, all distinct
+func numberedPages(count int) []string {
+	pages := make([]string, count)
+	for i := range pages {
+		pages[i] = rdfFeed(
+			testItem{Title: "a", Link: fmt.Sprintf("https://example.com/%d/a", i+1)},
+			testItem{Title: "b", Link: fmt.Sprintf("https://example.com/%d/b", i+1)},
+		)
+	}
+	return pages
+}
+
+func TestGetBookmarksFetchAllLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       http.Handler
+		options       Options
+		wantCount     int
+		wantTruncated string
+	}{
+		{"all pages", pagedFeeds(numberedPages(3)...), Options{}, 6, ""},
+		{"max pages", pagedFeeds(numberedPages(3)...), Options{MaxPages: 2}, 4, TruncatedByMaxPages},
+		{"max pages reached exactly", pagedFeeds(numberedPages(2)...), Options{MaxPages: 2}, 4, ""},
+		{"max items", pagedFeeds(numberedPages(3)...), Options{MaxItems: 3}, 3, TruncatedByMaxItems},
+		{"max items reached exactly", pagedFeeds(numberedPages(2)...), Options{MaxItems: 4}, 4, ""},
+		{"overall timeout", stallingFeeds(3), Options{FetchAllTimeout: 100 * time.Millisecond}, 2, TruncatedByOverallTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, tt.handler, tt.options)
+
+			response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", FetchAll: true})
+
+			if len(response.Bookmarks) != tt.wantCount || response.TotalCount != tt.wantCount {
+				t.Errorf("got %d bookmarks (TotalCount %d), want %d", len(response.Bookmarks), response.TotalCount, tt.wantCount)
+			}
+			if response.Truncated != (tt.wantTruncated != "") || response.TruncatedBy != tt.wantTruncated {
+				t.Errorf("Truncated = %v, TruncatedBy = %q, want %q", response.Truncated, response.TruncatedBy, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestDebugParse(t *testing.T) {
+	feed := rdfFeed(
+		testItem{Title: "older", Link: "https://example.com/older", Date: "2024-02-01T09:00:00+09:00"},
+		testItem{Title: "undated", Link: "https://example.com/undated", Date: "someday"},
+		testItem{Title: "newer", Link: "https://example.com/newer", Date: "2024-02-10T09:00:00+09:00"},
+	)
+	s := newTestService(t, serveFeed(feed, nil), Options{})
+
+	data, err := s.DebugParse(context.Background(), types.GetHatenaBookmarksParams{Username: " alice "})
+	if err != nil {
+		t.Fatalf("DebugParse() error = %v", err)
+	}
+
+	if data.Title != "alice's bookmarks" {
+		t.Errorf("Title = %q", data.Title)
+	}
+	if data.ItemCount != 3 || data.TotalItems != 3 {
+		t.Errorf("ItemCount = %d, TotalItems = %d, want 3 and 3", data.ItemCount, data.TotalItems)
+	}
+	want := []string{"https://example.com/older", "https://example.com/undated", "https://example.com/newer"}
+	if got := bookmarkURLs(data.Items); !reflect.DeepEqual(got, want) {
+		t.Errorf("items = %v, want feed order %v", got, want)
+	}
+	if len(data.Warnings) != 1 || !strings.Contains(data.Warnings[0], "someday") {
+		t.Errorf("Warnings = %q, want one for the unparseable date", data.Warnings)
+	}
+
+	if _, err := s.DebugParse(context.Background(), types.GetHatenaBookmarksParams{Username: "a/b"}); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("invalid username error = %v, want a validation error", err)
+	}
+}
+
+func TestGetBookmarksRawXML(t *testing.T) {
+	feeds := map[string]string{
+		"rdf": rdfFeed(
+			testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"go"}},
+			testItem{Title: "B", Link: "https://example.com/b"},
+		),
+		"rss": `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel><title>t</title><link>https://example.com/</link>
+<item><title>A</title><link>https://example.com/a</link><dc:subject>go</dc:subject><pubDate>Sat, 10 Feb 2024 09:00:00 +0900</pubDate></item>
+<item><title>B</title><link>https://example.com/b</link><pubDate>Sat, 10 Feb 2024 08:00:00 +0900</pubDate></item>
+</channel></rss>`,
+	}
+
+	for name, feed := range feeds {
+		t.Run(name, func(t *testing.T) {
+			var hits atomic.Int32
+			s := newTestService(t, serveFeed(rdfFeed(), &hits), Options{})
+
+			// No username is needed when nothing is fetched
+			response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{RawXML: feed})
+
+			want := []string{"https://example.com/a", "https://example.com/b"}
+			if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, want) {
+				t.Errorf("bookmarks = %v, want %v", got, want)
+			}
+			if got := response.Bookmarks[0].Tags; !reflect.DeepEqual(got, []string{"go"}) {
+				t.Errorf("tags = %v, want [go]", got)
+			}
+			if hits.Load() != 0 {
+				t.Errorf("server was requested %d times, want 0", hits.Load())
+			}
+		})
+	}
+}
+
+func TestGetBookmarksRawXMLValidation(t *testing.T) {
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a"})
+
+	tests := []struct {
+		name   string
+		params types.GetHatenaBookmarksParams
+	}{
+		{"blank", types.GetHatenaBookmarksParams{RawXML: " \n "}},
+		{"too large", types.GetHatenaBookmarksParams{RawXML: feed + strings.Repeat(" ", MaxRawXMLBytes)}},
+		{"with tag", types.GetHatenaBookmarksParams{RawXML: feed, Tag: "go"}},
+		{"with page", types.GetHatenaBookmarksParams{RawXML: feed, Page: 2}},
+		{"with fetch_all", types.GetHatenaBookmarksParams{RawXML: feed, FetchAll: true}},
+	}
+
+	s := newTestService(t, serveFeed(feed, nil), Options{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.GetBookmarks(context.Background(), tt.params)
+			if !errors.Is(err, types.ErrValidation) {
+				t.Errorf("GetBookmarks() error = %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+func TestGetDomainCountByPath(t *testing.T) {
+	s := newTestService(t, pagedFeeds(rdfFeed(
+		testItem{Title: "1", Link: "https://example.com/a?x=1"},
+		testItem{Title: "2", Link: "https://example.com/a?x=2"},
+		testItem{Title: "3", Link: "https://example.com/b"},
+	)), Options{})
+
+	response, err := s.GetDomainCount(context.Background(), "alice", true)
+	if err != nil {
+		t.Fatalf("GetDomainCount() error = %v", err)
+	}
+
+	// The two query variants stay separate bookmarks but count as one path
+	if response.TotalBookmarks != 3 || response.DistinctDomains != 2 || response.GroupBy != GroupByPath {
+		t.Errorf("response = %+v", response)
+	}
+	want := []types.DomainCount{{Domain: "example.com/a", Count: 2}, {Domain: "example.com/b", Count: 1}}
+	if !reflect.DeepEqual(response.TopDomains, want) {
+		t.Errorf("TopDomains = %v, want %v", response.TopDomains, want)
+	}
+}
+
+func TestGetBookmarksSortTags(t *testing.T) {
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"rss", "Go", "api"}})
+	s := newTestService(t, serveFeed(feed, nil), Options{CacheTTL: -1})
+
+	tests := []struct {
+		sortTags bool
+		want     []string
+	}{
+		{false, []string{"rss", "Go", "api"}},
+		{true, []string{"api", "Go", "rss"}},
+	}
+
+	for _, tt := range tests {
+		response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", SortTags: tt.sortTags})
+		if got := response.Bookmarks[0].Tags; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sort_tags=%v: tags = %q, want %q", tt.sortTags, got, tt.want)
+		}
+	}
+}
+
+func TestGetBookmarksRequestIDInLogs(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(utils.RequestIDHeader)
+		io.WriteString(w, rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	s := NewBookmarkServiceWithOptions(logger, Options{BaseURL: server.URL, AllowedHosts: []string{"127.0.0.1"}})
+	defer s.Close()
+
+	ctx := utils.WithRequestID(context.Background(), "req-1")
+	if _, err := s.GetBookmarks(ctx, types.GetHatenaBookmarksParams{Username: "alice"}); err != nil {
+		t.Fatalf("GetBookmarks() error = %v", err)
+	}
+
+	// Both the service and the parser it calls tag their lines with the ID
+	tagged := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record struct {
+			Msg       string `json:"msg"`
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		if record.RequestID == "req-1" {
+			tagged[record.Msg] = true
+		}
+	}
+	for _, msg := range []string{"Getting bookmarks", "Built request URL", "Starting RSS feed parsing", "Successfully parsed RDF/RSS 1.0 feed"} {
+		if !tagged[msg] {
+			t.Errorf("%q was not logged with the request ID", msg)
+		}
+	}
+
+	if header != "req-1" {
+		t.Errorf("%s header = %q, want req-1", utils.RequestIDHeader, header)
+	}
+}
+
+// failingPageFeeds serves pages like pagedFeeds but fails ?page=failPage with
+// a server error, counting the requests for it in hits
+func failingPageFeeds(failPage int, hits *atomic.Int32, pages ...string) http.HandlerFunc {
+	serve := pagedFeeds(pages...)
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			fmt.Sscanf(p, "%d", &page)
+		}
+		if page == failPage {
+			hits.Add(1)
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		serve(w, r)
+	}
+}
+
+func TestGetBookmarksBestEffort(t *testing.T) {
+	var hits atomic.Int32
+	s := newTestService(t, failingPageFeeds(3, &hits, numberedPages(4)...), Options{})
+	params := types.GetHatenaBookmarksParams{Username: "alice", FetchAll: true}
+
+	if _, err := s.GetBookmarks(context.Background(), params); !errors.Is(err, types.ErrAPI) {
+		t.Fatalf("GetBookmarks() error = %v, want the page 3 API error", err)
+	}
+
+	params.BestEffort = true
+	response := mustGetBookmarks(t, s, params)
+
+	if response.TotalCount != 4 || !response.Truncated || response.TruncatedBy != TruncatedByPageError {
+		t.Errorf("response = %d bookmarks, truncated %v by %q, want pages 1 and 2 truncated by %s",
+			response.TotalCount, response.Truncated, response.TruncatedBy, TruncatedByPageError)
+	}
+	if len(response.Errors) != 1 {
+		t.Fatalf("Errors = %v, want the page 3 failure", response.Errors)
+	}
+	pageErr := response.Errors[0]
+	details, _ := pageErr.Details.(map[string]interface{})
+	if pageErr.Code != types.ErrorCodeAPI || details["page"] != 3 || !strings.HasPrefix(pageErr.Message, "page 3: ") {
+		t.Errorf("page error = %+v", pageErr)
+	}
+
+	// Partial results are not cached, so the failed page is retried
+	mustGetBookmarks(t, s, params)
+	if hits.Load() != 3 {
+		t.Errorf("page 3 was requested %d times, want 3", hits.Load())
+	}
+}
+
+func TestGetBookmarksBestEffortFirstPageFails(t *testing.T) {
+	var hits atomic.Int32
+	s := newTestService(t, failingPageFeeds(1, &hits, numberedPages(2)...), Options{})
+
+	_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice", FetchAll: true, BestEffort: true})
+	if !errors.Is(err, types.ErrAPI) {
+		t.Errorf("GetBookmarks() error = %v, want an error when no page succeeded", err)
+	}
+}
+
+func TestGetBookmarksStreamThresholdFormatDetection(t *testing.T) {
+	// The RSS 1.0 namespace makes this RSS 2.0 feed look like RDF
+	misdetected := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:rss1="http://purl.org/rss/1.0/">
+<channel><title>alice's bookmarks</title><link>https://b.hatena.ne.jp/alice/bookmark</link>
+<item><title>A</title><link>https://example.com/a</link><pubDate>Sat, 10 Feb 2024 09:00:00 +0900</pubDate></item>
+</channel>
+</rss>`
+	atom := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>alice</title></feed>`
+
+	for _, threshold := range []int{-1, 1} {
+		t.Run(fmt.Sprintf("threshold %d", threshold), func(t *testing.T) {
+			s := newTestService(t, serveFeed(misdetected, nil), Options{StreamThreshold: threshold})
+			response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Page: 1})
+			if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, []string{"https://example.com/a"}) {
+				t.Errorf("bookmarks = %q, want the RSS 2.0 item", got)
+			}
+
+			s = newTestService(t, serveFeed(atom, nil), Options{StreamThreshold: threshold})
+			_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice", Page: 1})
+			if !errors.Is(err, types.ErrParsing) || !strings.Contains(err.Error(), "feed format not recognized") {
+				t.Errorf("Atom feed error = %v, want feed format not recognized", err)
+			}
+		})
+	}
+}
+
+func TestGetBookmarksStreamThreshold(t *testing.T) {
+	feed := rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"go"}, Count: 3},
+		testItem{Title: "B", Link: "/relative", Comment: "nice"},
+		testItem{Title: "C", Link: "https://example.com/c", Date: "someday"},
+	)
+
+	var responses []*types.GetHatenaBookmarksResponse
+	var logs []string
+	for _, threshold := range []int{-1, 1} {
+		var buf bytes.Buffer
+		s := newTestService(t, serveFeed(feed, nil), Options{StreamThreshold: threshold})
+		s.logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		responses = append(responses, mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"}))
+		logs = append(logs, buf.String())
+	}
+
+	if strings.Contains(logs[0], "Streaming large feed") || !strings.Contains(logs[1], "Streaming large feed") {
+		t.Errorf("only the feed over the threshold should be streamed")
+	}
+
+	unmarshaled, streamed := responses[0], responses[1]
+	if !reflect.DeepEqual(bookmarkURLs(streamed.Bookmarks), bookmarkURLs(unmarshaled.Bookmarks)) ||
+		streamed.TotalCount != unmarshaled.TotalCount || len(streamed.Warnings) != len(unmarshaled.Warnings) {
+		t.Errorf("streamed response = %+v, want %+v", streamed, unmarshaled)
+	}
+	for i := range streamed.Bookmarks {
+		got, want := streamed.Bookmarks[i], unmarshaled.Bookmarks[i]
+		if want.URL == "https://example.com/c" {
+			// Both fall back to the parse time, which may differ
+			got.BookmarkedAt, want.BookmarkedAt = "", ""
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("streamed bookmark %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestTagAliases(t *testing.T) {
+	s := newTestService(t, pagedFeeds(rdfFeed(
+		testItem{Title: "1", Link: "https://example.com/1", Tags: []string{"golang"}},
+		testItem{Title: "2", Link: "https://example.com/2", Tags: []string{"Golang", "go-lang", "rust"}},
+		testItem{Title: "3", Link: "https://example.com/3", Tags: []string{"go"}},
+		testItem{Title: "4", Link: "https://example.com/4", Tags: []string{"rust"}},
+	)), Options{TagAliases: map[string]string{"golang": "go", " Go-Lang ": "go"}})
+
+	overview, err := s.GetUserOverview(context.Background(), "alice", 0)
+	if err != nil {
+		t.Fatalf("GetUserOverview() error = %v", err)
+	}
+	want := []types.TagCount{{Tag: "go", Count: 3}, {Tag: "rust", Count: 2}}
+	if !reflect.DeepEqual(overview.TopTags, want) {
+		t.Errorf("TopTags = %v, want %v", overview.TopTags, want)
+	}
+
+	// The variants on one bookmark merge into a single canonical tag
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+	if got := response.Bookmarks[1].Tags; !reflect.DeepEqual(got, []string{"go", "rust"}) {
+		t.Errorf("tags = %q, want [go rust]", got)
+	}
+}
+
+func TestGetRecentDomains(t *testing.T) {
+	var hits atomic.Int32
+	pages := pagedFeeds(
+		rdfFeed(
+			testItem{Title: "older go", Link: "https://go.dev/doc", Date: "2024-02-08T09:00:00+09:00"},
+			testItem{Title: "newest go", Link: "https://go.dev/blog", Date: "2024-02-10T09:00:00+09:00"},
+			testItem{Title: "example", Link: "https://example.com/a", Date: "2024-02-09T09:00:00+09:00"},
+		),
+		rdfFeed(
+			testItem{Title: "org", Link: "https://example.org/", Date: "2024-02-07T09:00:00+09:00"},
+		),
+	)
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		pages(w, r)
+	}), Options{})
+
+	tests := []struct {
+		limit     int
+		want      []string
+		wantPages int32
+	}{
+		{2, []string{"go.dev", "example.com"}, 1},
+		{3, []string{"go.dev", "example.com", "example.org"}, 2},
+	}
+
+	for _, tt := range tests {
+		hits.Store(0)
+		response, err := s.GetRecentDomains(context.Background(), "alice", tt.limit)
+		if err != nil {
+			t.Fatalf("GetRecentDomains() error = %v", err)
+		}
+
+		var got []string
+		for _, domain := range response.Domains {
+			got = append(got, domain.Domain)
+		}
+		if !reflect.DeepEqual(got, tt.want) || response.Count != len(tt.want) {
+			t.Errorf("limit %d: domains = %v, want %v in recency order", tt.limit, got, tt.want)
+		}
+		if response.Domains[0].Bookmark.Title != "newest go" {
+			t.Errorf("limit %d: go.dev bookmark = %q, want the newest", tt.limit, response.Domains[0].Bookmark.Title)
+		}
+		if hits.Load() != tt.wantPages {
+			t.Errorf("limit %d: fetched %d pages, want %d", tt.limit, hits.Load(), tt.wantPages)
+		}
+	}
+
+	if _, err := s.GetRecentDomains(context.Background(), "alice", MaxRecentDomains+1); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("limit over the maximum: error = %v, want a validation error", err)
+	}
+}
+
+func TestGetUserOverview(t *testing.T) {
+	pages := []string{
+		rdfFeed(
+			testItem{Title: "1", Link: "https://go.dev/1", Date: "2024-02-10T09:00:00+09:00", Tags: []string{"go", "release"}},
+			testItem{Title: "2", Link: "https://example.com/2", Date: "2024-02-03T09:00:00+09:00", Tags: []string{"go"}},
+		),
+		rdfFeed(
+			testItem{Title: "3", Link: "https://go.dev/3", Date: "2024-01-20T09:00:00+09:00", Tags: []string{"rss"}},
+			testItem{Title: "4", Link: "https://go.dev/4", Date: "2024-02-12T09:00:00+09:00"},
+		),
+	}
+
+	t.Run("all pages", func(t *testing.T) {
+		s := newTestService(t, pagedFeeds(pages...), Options{})
+
+		overview, err := s.GetUserOverview(context.Background(), "alice", 2)
+		if err != nil {
+			t.Fatalf("GetUserOverview() error = %v", err)
+		}
+
+		if overview.User != "alice" || overview.TotalBookmarks != 4 || overview.Truncated {
+			t.Errorf("overview = %+v", overview)
+		}
+		wantTags := []types.TagCount{{Tag: "go", Count: 2}, {Tag: "release", Count: 1}, {Tag: "rss", Count: 1}}
+		if !reflect.DeepEqual(overview.TopTags, wantTags) {
+			t.Errorf("TopTags = %v, want %v", overview.TopTags, wantTags)
+		}
+		wantDomains := []types.DomainCount{{Domain: "go.dev", Count: 3}, {Domain: "example.com", Count: 1}}
+		if !reflect.DeepEqual(overview.TopDomains, wantDomains) {
+			t.Errorf("TopDomains = %v, want %v", overview.TopDomains, wantDomains)
+		}
+		wantActivity := []types.MonthCount{{Month: "2024-01", Count: 1}, {Month: "2024-02", Count: 3}}
+		if !reflect.DeepEqual(overview.Activity, wantActivity) {
+			t.Errorf("Activity = %v, want %v", overview.Activity, wantActivity)
+		}
+		// The most recent bookmarks come from across pages, newest first
+		if got := bookmarkURLs(overview.RecentBookmarks); !reflect.DeepEqual(got, []string{"https://go.dev/4", "https://go.dev/1"}) {
+			t.Errorf("RecentBookmarks = %v", got)
+		}
+	})
+
+	t.Run("bounded by MaxItems", func(t *testing.T) {
+		s := newTestService(t, pagedFeeds(pages...), Options{MaxItems: 3})
+
+		overview, err := s.GetUserOverview(context.Background(), "alice", 0)
+		if err != nil {
+			t.Fatalf("GetUserOverview() error = %v", err)
+		}
+		if overview.TotalBookmarks != 3 || !overview.Truncated || len(overview.RecentBookmarks) != 3 {
+			t.Errorf("overview = %d bookmarks, truncated %v, %d recent, want 3, true and 3",
+				overview.TotalBookmarks, overview.Truncated, len(overview.RecentBookmarks))
+		}
+	})
+
+	t.Run("invalid recent_count", func(t *testing.T) {
+		s := newTestService(t, pagedFeeds(pages...), Options{})
+
+		for _, recentCount := range []int{-1, MaxOverviewRecentCount + 1} {
+			if _, err := s.GetUserOverview(context.Background(), "alice", recentCount); !errors.Is(err, types.ErrValidation) {
+				t.Errorf("recent_count %d: error = %v, want a validation error", recentCount, err)
+			}
+		}
+	})
+}
+
+func TestGetBookmarksEncodedUsername(t *testing.T) {
+	var hits atomic.Int32
+	s := newTestService(t, serveFeed(rdfFeed(), &hits), Options{})
+
+	tests := []struct {
+		username string
+		wantErr  string
+	}{
+		{"%61lice", "username must not be URL-encoded; send the raw username"},
+		{"ali ce", "Username must contain only alphanumeric characters and hyphens"},
+	}
+
+	for _, tt := range tests {
+		_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: tt.username})
+		var mcpErr *types.MCPError
+		if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeValidation || mcpErr.Message != tt.wantErr {
+			t.Errorf("username %q: error = %v, want %q", tt.username, err, tt.wantErr)
+		}
+	}
+
+	// An encoded username is rejected rather than escaped a second time
+	if _, err := s.buildRequestURL(types.GetHatenaBookmarksParams{Username: "%61lice"}); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("buildRequestURL() error = %v, want a validation error", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("server was requested %d times, want 0", hits.Load())
+	}
+}
+
+func TestGetBookmarksAnnotatePage(t *testing.T) {
+	s := newTestService(t, pagedFeeds(numberedPages(2)...), Options{})
+
+	// Without the flag no page is recorded, even for a cached result later
+	// requested with it
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", FetchAll: true})
+	for _, bookmark := range response.Bookmarks {
+		if bookmark.SourcePage != 0 {
+			t.Fatalf("SourcePage = %d without annotate_page", bookmark.SourcePage)
+		}
+	}
+
+	response = mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", FetchAll: true, AnnotatePage: true})
+	want := map[string]int{
+		"https://example.com/1/a": 1,
+		"https://example.com/1/b": 1,
+		"https://example.com/2/a": 2,
+		"https://example.com/2/b": 2,
+	}
+	if len(response.Bookmarks) != len(want) {
+		t.Fatalf("got %d bookmarks, want %d", len(response.Bookmarks), len(want))
+	}
+	for _, bookmark := range response.Bookmarks {
+		if bookmark.SourcePage != want[bookmark.URL] {
+			t.Errorf("%s: SourcePage = %d, want %d", bookmark.URL, bookmark.SourcePage, want[bookmark.URL])
+		}
+	}
+
+	// A single page is annotated with the requested page
+	for _, page := range []int{0, 2} {
+		response = mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Page: page, AnnotatePage: true})
+		wantPage := page
+		if wantPage == 0 {
+			wantPage = 1
+		}
+		for _, bookmark := range response.Bookmarks {
+			if bookmark.SourcePage != wantPage {
+				t.Errorf("page %d: SourcePage = %d, want %d", page, bookmark.SourcePage, wantPage)
+			}
+		}
+	}
+}
+
+func TestGetBookmarksFiltered(t *testing.T) {
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"go"}})
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hatena applies the filters; this tag matches nothing
+		if r.URL.Query().Get("tag") == "none" {
+			io.WriteString(w, rdfFeed())
+			return
+		}
+		io.WriteString(w, feed)
+	}), Options{})
+
+	first := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Tag: "go"})
+
+	tests := []struct {
+		name      string
+		params    types.GetHatenaBookmarksParams
+		want      bool
+		wantCount int
+	}{
+		{"no filter", types.GetHatenaBookmarksParams{Username: "alice"}, false, 1},
+		{"tag", types.GetHatenaBookmarksParams{Username: "alice", Tag: "go"}, true, 1},
+		{"date", types.GetHatenaBookmarksParams{Username: "alice", Date: "20240210"}, true, 1},
+		{"url", types.GetHatenaBookmarksParams{Username: "alice", URL: "https://example.com/a"}, true, 1},
+		{"filter matching nothing", types.GetHatenaBookmarksParams{Username: "alice", Tag: "none"}, true, 0},
+		{"filter resumed from a cursor", types.GetHatenaBookmarksParams{Username: "alice", Cursor: first.NextCursor}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := mustGetBookmarks(t, s, tt.params)
+			if response.Filtered != tt.want || response.TotalCount != tt.wantCount {
+				t.Errorf("Filtered = %v with %d bookmarks, want %v with %d", response.Filtered, response.TotalCount, tt.want, tt.wantCount)
+			}
+			if (response.Filters != nil) != tt.want {
+				t.Errorf("Filters = %+v, want them set only when filtered", response.Filters)
+			}
+
+			// The flag is serialized even when false
+			data, err := json.Marshal(response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := fmt.Sprintf(`"filtered":%v`, tt.want); !strings.Contains(string(data), want) {
+				t.Errorf("JSON has no %s", want)
+			}
+		})
+	}
+}
+
+func TestCompareUsers(t *testing.T) {
+	feeds := map[string][]string{
+		"alice": {
+			rdfFeed(
+				testItem{Title: "shared 1", Link: "https://example.com/shared1"},
+				testItem{Title: "alice only", Link: "https://example.com/alice"},
+			),
+			rdfFeed(testItem{Title: "shared 2", Link: "https://example.com/shared2"}),
+			rdfFeed(testItem{Title: "outside the window", Link: "https://example.com/bob"}),
+		},
+		"bob": {
+			rdfFeed(
+				testItem{Title: "shared 2", Link: "https://example.com/shared2"},
+				testItem{Title: "bob only", Link: "https://example.com/bob"},
+				testItem{Title: "shared 1", Link: "https://example.com/shared1"},
+			),
+		},
+		"carol": nil,
+	}
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username := strings.Split(strings.Trim(r.URL.Path, "/"), "/")[0]
+		pagedFeeds(feeds[username]...)(w, r)
+	}), Options{})
+
+	response, err := s.CompareUsers(context.Background(), "alice", " bob ", 2)
+	if err != nil {
+		t.Fatalf("CompareUsers() error = %v", err)
+	}
+	want := []string{"https://example.com/shared1", "https://example.com/shared2"}
+	if !reflect.DeepEqual(response.SharedURLs, want) || response.SharedCount != 2 {
+		t.Errorf("shared = %v, want %v", response.SharedURLs, want)
+	}
+	if response.UserB != "bob" || response.Pages != 2 || response.UniqueA != 1 || response.UniqueB != 1 || response.Similarity != 0.5 {
+		t.Errorf("response = %+v", response)
+	}
+
+	response, err = s.CompareUsers(context.Background(), "alice", "carol", 0)
+	if err != nil {
+		t.Fatalf("CompareUsers() error = %v", err)
+	}
+	if response.SharedCount != 0 || response.UniqueA != 4 || response.UniqueB != 0 || response.Similarity != 0 || response.SharedURLs == nil {
+		t.Errorf("comparison with an empty user = %+v", response)
+	}
+
+	if _, err := s.CompareUsers(context.Background(), "alice", "bob", MaxComparePages+1); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("within_pages over the maximum: error = %v, want a validation error", err)
+	}
+	if _, err := s.CompareUsers(context.Background(), "alice", "b/c", 1); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("invalid username: error = %v, want a validation error", err)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	feeds := pagedFeeds(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}))
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		feeds(w, r)
+	}), Options{})
+
+	// carol is cached before warming and should not be fetched again
+	mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "carol"})
+
+	results := s.Warm(context.Background(), []types.GetHatenaBookmarksParams{
+		{Username: "alice"},
+		{Username: "bob", NoCache: true},
+		{Username: "carol"},
+		{Username: "not a user"},
+		{RawXML: rdfFeed()},
+	})
+
+	if len(results) != 5 {
+		t.Fatalf("len(results) = %d, want 5", len(results))
+	}
+	for i, username := range []string{"alice", "bob", "carol"} {
+		if results[i].Err != nil || results[i].Params.Username != username {
+			t.Errorf("results[%d] = %+v, want %s warmed", i, results[i], username)
+		}
+	}
+	if results[0].Cached || results[1].Cached || !results[2].Cached {
+		t.Errorf("Cached = %v, %v, %v, want only carol cached", results[0].Cached, results[1].Cached, results[2].Cached)
+	}
+	if !errors.Is(results[3].Err, types.ErrValidation) {
+		t.Errorf("invalid username error = %v, want a validation error", results[3].Err)
+	}
+	if results[4].Err == nil {
+		t.Error("raw_xml entry succeeded, want an error")
+	}
+
+	mu.Lock()
+	warmed := map[string]int{"/alice/rss": hits["/alice/rss"], "/bob/rss": hits["/bob/rss"], "/carol/rss": hits["/carol/rss"]}
+	mu.Unlock()
+
+	// Subsequent calls are served from the warmed cache
+	for _, username := range []string{"alice", "bob", "carol"} {
+		response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: username})
+		if len(response.Bookmarks) != 1 {
+			t.Errorf("%s bookmarks = %d, want 1", username, len(response.Bookmarks))
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for path, count := range warmed {
+		if count == 0 {
+			t.Errorf("%s was never fetched", path)
+		}
+		if hits[path] != count {
+			t.Errorf("%s hits = %d after warming, want %d", path, hits[path], count)
+		}
+	}
+}
+
+func TestWarmCacheDisabled(t *testing.T) {
+	var hits atomic.Int32
+	s := newTestService(t, serveFeed(rdfFeed(), &hits), Options{CacheTTL: -1})
+
+	results := s.Warm(context.Background(), []types.GetHatenaBookmarksParams{{Username: "alice"}, {Username: "bob"}})
+	for i, result := range results {
+		if result.Err == nil {
+			t.Errorf("results[%d] succeeded, want an error with caching disabled", i)
+		}
+	}
+	if got := hits.Load(); got != 0 {
+		t.Errorf("server hits = %d, want nothing fetched", got)
+	}
+}
+
+func TestWarmCanceled(t *testing.T) {
+	var hits atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}), Options{WarmConcurrency: 1, WarmInterval: -1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan []WarmResult)
+	go func() {
+		done <- s.Warm(ctx, []types.GetHatenaBookmarksParams{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}})
+	}()
+
+	// bob waits for alice's slot; cancelling must not let it through
+	<-started
+	cancel()
+
+	var results []WarmResult
+	select {
+	case results = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Warm did not return after cancellation")
+	}
+
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("results[%d].Err = %v, want context.Canceled", i, result.Err)
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hits = %d, want only the first fetch started", got)
+	}
+}
+
+func TestWarmInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	var mu sync.Mutex
+	var arrivals []time.Time
+	feeds := pagedFeeds(rdfFeed())
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+		feeds(w, r)
+	}), Options{WarmConcurrency: 3, WarmInterval: interval})
+
+	results := s.Warm(context.Background(), []types.GetHatenaBookmarksParams{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}})
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("results[%d].Err = %v", i, result.Err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(arrivals) != 3 {
+		t.Fatalf("server hits = %d, want 3", len(arrivals))
+	}
+	// Allow for timer slack while still catching fetches sent in a burst
+	if spread := arrivals[2].Sub(arrivals[0]); spread < interval {
+		t.Errorf("fetches spread over %v, want at least %v with a %v interval", spread, interval, interval)
+	}
+}
+
+func TestGetBookmarksTagTree(t *testing.T) {
+	s := newTestService(t, pagedFeeds(rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"tech/go"}},
+		testItem{Title: "B", Link: "https://example.com/b"},
+	)), Options{})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", TagTree: true})
+
+	node := response.TagTree["tech"]
+	if node == nil || node.Children["go"] == nil {
+		t.Fatalf("TagTree = %v, want tech/go", response.TagTree)
+	}
+	if got := bookmarkURLs(node.Children["go"].Bookmarks); !reflect.DeepEqual(got, []string{"https://example.com/a"}) {
+		t.Errorf("tech/go bookmarks = %q", got)
+	}
+	if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, []string{"https://example.com/b"}) {
+		t.Errorf("Bookmarks = %q, want only the untagged bookmark", got)
+	}
+
+	_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice", TagTree: true, GroupByDate: true})
+	if !errors.Is(err, types.ErrValidation) {
+		t.Errorf("tag_tree with group_by_date error = %v, want a validation error", err)
+	}
+}
+
+func TestGetBookmarksRefreshAhead(t *testing.T) {
+	clock := newTestClock()
+	var hits atomic.Int32
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	defer unblock()
+
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			io.WriteString(w, rdfFeed(testItem{Title: "Old", Link: "https://example.com/old"}))
+			return
+		}
+		// The refresh is held until the cached hit has been served
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		io.WriteString(w, rdfFeed(testItem{Title: "New", Link: "https://example.com/new"}))
+	}), Options{Clock: clock.Now, CacheTTL: 5 * time.Minute, CacheRefreshAhead: time.Minute})
+	params := types.GetHatenaBookmarksParams{Username: "alice", Page: 1}
+
+	// Make the entry hot, then move within RefreshAhead of its expiry
+	for i := 0; i < 3; i++ {
+		mustGetBookmarks(t, s, params)
+	}
+	clock.Advance(4*time.Minute + 30*time.Second)
+
+	served := make(chan *types.GetHatenaBookmarksResponse, 1)
+	go func() {
+		response, err := s.GetBookmarks(context.Background(), params)
+		if err != nil {
+			t.Errorf("GetBookmarks() error = %v", err)
+		}
+		served <- response
+	}()
+
+	select {
+	case response := <-served:
+		if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, []string{"https://example.com/old"}) {
+			t.Errorf("served %q, want the cached bookmarks", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cache hit waited for the refresh")
+	}
+
+	unblock()
+	s.refreshes.Wait()
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("server hits = %d, want a single refresh", got)
+	}
+
+	// The refreshed entry replaces the old one and lives for a full TTL
+	clock.Advance(4 * time.Minute)
+	response := mustGetBookmarks(t, s, params)
+	if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, []string{"https://example.com/new"}) {
+		t.Errorf("bookmarks after refresh = %q, want the refreshed bookmarks", got)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server hits = %d, want the refreshed entry served from the cache", got)
+	}
+}
+
+func TestGetBookmarksRefreshAheadDisabled(t *testing.T) {
+	clock := newTestClock()
+	var hits atomic.Int32
+	s := newTestService(t, serveFeed(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}), &hits),
+		Options{Clock: clock.Now, CacheTTL: 5 * time.Minute})
+	params := types.GetHatenaBookmarksParams{Username: "alice", Page: 1}
+
+	for i := 0; i < 3; i++ {
+		mustGetBookmarks(t, s, params)
+	}
+	clock.Advance(4*time.Minute + 30*time.Second)
+	mustGetBookmarks(t, s, params)
+	s.refreshes.Wait()
+
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hits = %d, want no refresh without CacheRefreshAhead", got)
+	}
+}
+
+func TestGetBookmarksMinResults(t *testing.T) {
+	// Each page has a single bookmark matching the tag filter
+	pages := make([]string, 5)
+	for i := range pages {
+		pages[i] = rdfFeed(testItem{Title: "match", Link: fmt.Sprintf("https://example.com/%d", i+1), Tags: []string{"go"}})
+	}
+
+	tests := []struct {
+		name       string
+		pages      int
+		minResults int
+		options    Options
+		wantCount  int
+		wantMet    bool
+		wantCursor bool
+		// wantRequests counts the feed pages requested
+		wantRequests int
+	}{
+		{"met after several pages", 5, 3, Options{}, 3, true, true, 3},
+		{"met on the first page", 5, 1, Options{}, 1, true, true, 1},
+		{"pages run out", 2, 3, Options{}, 2, false, false, 3},
+		{"bounded by max_pages", 5, 4, Options{MaxPages: 2}, 2, false, true, 2},
+		{"bounded by max_items", 5, 4, Options{MaxItems: 2}, 2, false, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tags []string
+			var mu sync.Mutex
+			feeds := pagedFeeds(pages[:tt.pages]...)
+			s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				tags = append(tags, r.URL.Query().Get("tag"))
+				mu.Unlock()
+				feeds(w, r)
+			}), tt.options)
+
+			response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Tag: "go", MinResults: tt.minResults})
+
+			if len(response.Bookmarks) != tt.wantCount {
+				t.Errorf("bookmarks = %d, want %d", len(response.Bookmarks), tt.wantCount)
+			}
+			if response.MinResultsMet == nil || *response.MinResultsMet != tt.wantMet {
+				t.Errorf("MinResultsMet = %v, want %v", response.MinResultsMet, tt.wantMet)
+			}
+			if (response.NextCursor != "") != tt.wantCursor {
+				t.Errorf("NextCursor = %q, want one: %v", response.NextCursor, tt.wantCursor)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(tags) != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", len(tags), tt.wantRequests)
+			}
+			for _, tag := range tags {
+				if tag != "go" {
+					t.Errorf("requested tag = %q, want every page filtered", tag)
+				}
+			}
+		})
+	}
+}
+
+func TestGetBookmarksMinResultsValidation(t *testing.T) {
+	s := NewBookmarkServiceWithOptions(testLogger(), Options{})
+	defer s.Close()
+
+	for _, params := range []types.GetHatenaBookmarksParams{
+		{Username: "alice", MinResults: -1},
+		{Username: "alice", MinResults: 5, FetchAll: true},
+	} {
+		if _, err := s.GetBookmarks(context.Background(), params); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("GetBookmarks(%+v) error = %v, want a validation error", params, err)
+		}
+	}
+}
+
+func TestFilterBookmarks(t *testing.T) {
+	s := newTestService(t, pagedFeeds(rdfFeed(
+		testItem{Title: "Both", Link: "https://example.com/both", Comment: "nice", Tags: []string{"go"}},
+		testItem{Title: "Comment", Link: "https://example.com/comment", Comment: "nice"},
+		testItem{Title: "Tags", Link: "https://example.com/tags", Tags: []string{"go"}},
+	)), Options{})
+	hasComment, hasTags := true, false
+
+	response, err := s.FilterBookmarks(context.Background(), "alice", &hasComment, &hasTags, 1)
+	if err != nil {
+		t.Fatalf("FilterBookmarks() error = %v", err)
+	}
+
+	if got := bookmarkURLs(response.Bookmarks); !reflect.DeepEqual(got, []string{"https://example.com/comment"}) {
+		t.Errorf("bookmarks = %q, want the one with a comment but no tags", got)
+	}
+	if response.User != "alice" || response.Page != 1 || response.TotalCount != 3 || response.MatchedCount != 1 {
+		t.Errorf("response = %+v, want 1 of 3 matched on page 1", response)
+	}
+	if response.HasComment == nil || !*response.HasComment || response.HasTags == nil || *response.HasTags {
+		t.Errorf("criteria = %v, %v, want the applied criteria echoed", response.HasComment, response.HasTags)
+	}
+
+	if _, err := s.FilterBookmarks(context.Background(), "not a user", nil, nil, 1); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("invalid username error = %v, want a validation error", err)
+	}
+}
+
+func TestGetBookmarksLowercaseTags(t *testing.T) {
+	s := newTestService(t, pagedFeeds(rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"Go", "go", "日本語", "RSS"}},
+	)), Options{})
+	params := types.GetHatenaBookmarksParams{Username: "alice"}
+
+	params.LowercaseTags = true
+	response := mustGetBookmarks(t, s, params)
+	if got, want := response.Bookmarks[0].Tags, []string{"go", "日本語", "rss"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lowercased tags = %q, want %q", got, want)
+	}
+
+	// The default keeps the original casing, including for cached results
+	params.LowercaseTags = false
+	response = mustGetBookmarks(t, s, params)
+	if got, want := response.Bookmarks[0].Tags, []string{"Go", "go", "日本語", "RSS"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tags = %q, want %q", got, want)
+	}
+}
+
+func TestGetBookmarksGeneratesRequestID(t *testing.T) {
+	var mu sync.Mutex
+	var headers []string
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Get(utils.RequestIDHeader))
+		mu.Unlock()
+		io.WriteString(w, rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}))
+	}), Options{CacheTTL: -1})
+
+	for i := 0; i < 2; i++ {
+		mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Page: 1})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(headers) != 2 {
+		t.Fatalf("got %d requests, want 2", len(headers))
+	}
+	for _, header := range headers {
+		if !utils.IsValidRequestID(header) {
+			t.Errorf("%s header = %q, want a generated ID", utils.RequestIDHeader, header)
+		}
+	}
+	if headers[0] == headers[1] {
+		t.Errorf("calls without a request ID shared %q", headers[0])
+	}
+}
+
+func TestGetBookmarksResponseOptionsShareCacheEntry(t *testing.T) {
+	var hits atomic.Int32
+	cache := utils.NewCache(5*time.Minute, nil)
+	s := newTestService(t, serveFeed(rdfFeed(testItem{Title: "A", Link: "https://example.com/a", Tags: []string{"Go"}}), &hits), Options{Cache: cache})
+
+	plain := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Page: 1})
+	styled := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{
+		Username:      "alice",
+		Page:          1,
+		SortBy:        "score",
+		LowercaseTags: true,
+		DateFormat:    "jp",
+		IncludeEpoch:  true,
+	})
+
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hits = %d, want calls differing in response options to share a fetch", got)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("cache holds %d entries, want 1", cache.Len())
+	}
+	// Each call still gets its own presentation of the shared fetch
+	if plain.Bookmarks[0].Tags[0] != "Go" || styled.Bookmarks[0].Tags[0] != "go" || styled.Bookmarks[0].BookmarkedAtUnix == 0 {
+		t.Errorf("bookmarks = %+v and %+v, want the options applied to the second only", plain.Bookmarks[0], styled.Bookmarks[0])
+	}
+}
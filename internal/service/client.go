@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// newHTTPClient builds the HTTP client used for all requests to Hatena
+func newHTTPClient(options Options) *http.Client {
+	return &http.Client{
+		Timeout:       options.RequestTimeout,
+		Transport:     newTransport(options, newTLSConfig(options)),
+		CheckRedirect: loginRedirectCheck(options),
+	}
+}
+
+// newTransport builds an HTTP transport applying the connection timeouts and
+// HTTP/2 setting from options, with the given TLS configuration
+func newTransport(options Options, tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	// Bound each connection phase separately from the overall timeout, so
+	// that slow body transfers can proceed while stalled connects fail fast
+	transport.DialContext = newDialer(options).DialContext
+	transport.TLSHandshakeTimeout = options.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = options.ResponseHeaderTimeout
+
+	// Responses are decompressed by responseBody, which tolerates proxies
+	// that declare gzip but send plain text
+	transport.DisableCompression = true
+
+	if options.DisableHTTP2 {
+		// A non-nil, empty TLSNextProto map stops the transport from
+		// negotiating HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return transport
+}
+
+// newDialer builds the dialer for new connections, bounded by DialTimeout
+func newDialer(options Options) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   options.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+// gzipMagic is the two-byte header that starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// responseBody returns a reader for the decoded response body. A body
+// declared as gzip that does not start with the gzip header is read as plain
+// text, since some misconfigured proxies set Content-Encoding wrongly.
+func (s *BookmarkService) responseBody(resp *http.Response) (io.Reader, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+
+	body := bufio.NewReader(resp.Body)
+	header, err := body.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if !bytes.Equal(header, gzipMagic) {
+		s.log(resp.Request.Context()).Warn("Response declared gzip encoding but is not gzip; reading as plain text",
+			"url", redactURL(resp.Request.URL))
+		return body, nil
+	}
+
+	return gzip.NewReader(body)
+}
+
+// debugHeaderNames lists the response headers captured for include_headers,
+// besides X-RateLimit-* headers
+var debugHeaderNames = []string{"Content-Type", "ETag", "Last-Modified", "Retry-After"}
+
+// debugHeaders captures the status and the response headers useful for
+// troubleshooting rate limits and caching
+func debugHeaders(resp *http.Response) map[string]string {
+	headers := map[string]string{"Status": resp.Status}
+
+	for _, name := range debugHeaderNames {
+		if value := resp.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	for name, values := range resp.Header {
+		if strings.HasPrefix(name, "X-Ratelimit-") && len(values) > 0 {
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+
+	return headers
+}
+
+// newTLSConfig builds the TLS configuration enforcing the minimum TLS
+// version and, when configured, certificate pinning
+func newTLSConfig(options Options) *tls.Config {
+	config := &tls.Config{
+		MinVersion: options.TLSMinVersion,
+	}
+
+	if len(options.PinnedCertSHA256) > 0 {
+		pins := make(map[string]bool, len(options.PinnedCertSHA256))
+		for _, pin := range options.PinnedCertSHA256 {
+			pins[normalizeFingerprint(pin)] = true
+		}
+
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("no peer certificate presented by %s", state.ServerName)
+			}
+
+			sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+			fingerprint := hex.EncodeToString(sum[:])
+			if !pins[fingerprint] {
+				return fmt.Errorf("certificate fingerprint %s for %s does not match any pinned certificate", fingerprint, state.ServerName)
+			}
+			return nil
+		}
+	}
+
+	return config
+}
+
+// normalizeFingerprint lowercases a hex fingerprint and strips colon separators
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+}
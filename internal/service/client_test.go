@@ -0,0 +1,457 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	release := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		io.WriteString(w, rdfFeed())
+	}
+	s := newTestService(t, http.HandlerFunc(handler), Options{MaxConcurrentRequests: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Distinct users, so that no fetch is shared or cached
+			params := types.GetHatenaBookmarksParams{Username: fmt.Sprintf("user%d", i)}
+			if _, err := s.GetBookmarks(context.Background(), params); err != nil {
+				t.Errorf("GetBookmarks() error = %v", err)
+			}
+		}(i)
+	}
+
+	// Let the first requests arrive, then let them all through
+	deadline := time.Now().Add(5 * time.Second)
+	for inFlight.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak in-flight requests = %d, want 2", got)
+	}
+}
+
+func TestRequestSlotHonorsCancellation(t *testing.T) {
+	s := newTestService(t, serveFeed(rdfFeed(), nil), Options{MaxConcurrentRequests: 1})
+
+	// Hold the only slot
+	hold, err := s.acquireRequestSlot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hold()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.acquireRequestSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquireRequestSlot() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	req, err := s.newRequest(ctx, http.MethodGet, s.baseURL+"/alice/rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.send(req); !errors.Is(err, types.ErrNetwork) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("send() error = %v, want a network error caused by the deadline", err)
+	}
+}
+
+// newTLSTestService returns a service fetching from an httptest TLS server
+// that trusts the server's certificate
+func newTLSTestService(t *testing.T, server *httptest.Server, options Options) *BookmarkService {
+	t.Helper()
+
+	options.BaseURL = server.URL
+	options.AllowedHosts = append(options.AllowedHosts, "127.0.0.1")
+
+	s := NewBookmarkServiceWithOptions(testLogger(), options)
+	t.Cleanup(s.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	s.client.Transport.(*http.Transport).TLSClientConfig.RootCAs = roots
+	return s
+}
+
+// certFingerprint returns the SHA-256 fingerprint of the server's
+// certificate in the colon-separated uppercase form tools like openssl print
+func certFingerprint(server *httptest.Server) string {
+	sum := sha256.Sum256(server.Certificate().Raw)
+	pairs := make([]string, len(sum))
+	for i, b := range sum {
+		pairs[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(pairs, ":")
+}
+
+func TestTLSMinVersionOnTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    uint16
+	}{
+		{"default", Options{}, tls.VersionTLS12},
+		{"configured", Options{TLSMinVersion: tls.VersionTLS13}, tls.VersionTLS13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewBookmarkServiceWithOptions(testLogger(), tt.options)
+			defer s.Close()
+
+			transport, ok := s.client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Transport is %T, want *http.Transport", s.client.Transport)
+			}
+			if got := transport.TLSClientConfig.MinVersion; got != tt.want {
+				t.Errorf("MinVersion = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSMinVersionRejectsOlderServer(t *testing.T) {
+	server := httptest.NewUnstartedServer(serveFeed(rdfFeed(), nil))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // The failed handshake is expected
+	server.StartTLS()
+	defer server.Close()
+	s := newTLSTestService(t, server, Options{TLSMinVersion: tls.VersionTLS13})
+
+	_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+	if !errors.Is(err, types.ErrNetwork) {
+		t.Errorf("error = %v, want a network error", err)
+	}
+}
+
+func TestPinnedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(serveFeed(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}), nil))
+	defer server.Close()
+
+	t.Run("matching pin", func(t *testing.T) {
+		s := newTLSTestService(t, server, Options{PinnedCertSHA256: []string{"00:11", certFingerprint(server)}})
+
+		response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+		if len(response.Bookmarks) != 1 {
+			t.Errorf("got %d bookmarks, want 1", len(response.Bookmarks))
+		}
+	})
+
+	t.Run("mismatched pin", func(t *testing.T) {
+		s := newTLSTestService(t, server, Options{PinnedCertSHA256: []string{strings.Repeat("ab", sha256.Size)}})
+
+		_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+		if !errors.Is(err, types.ErrNetwork) {
+			t.Fatalf("error = %v, want a network error", err)
+		}
+		if !strings.Contains(err.Error(), "does not match any pinned certificate") {
+			t.Errorf("error = %v, want the pin mismatch reported", err)
+		}
+	})
+}
+
+func TestDisableHTTP2(t *testing.T) {
+	tests := []struct {
+		name      string
+		disable   bool
+		wantProto string
+	}{
+		{"default", false, "HTTP/2.0"},
+		{"disabled", true, "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proto atomic.Value
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proto.Store(r.Proto)
+				io.WriteString(w, rdfFeed())
+			}))
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			defer server.Close()
+			s := newTLSTestService(t, server, Options{DisableHTTP2: tt.disable})
+
+			transport := s.client.Transport.(*http.Transport)
+			if transport.ForceAttemptHTTP2 == tt.disable {
+				t.Errorf("ForceAttemptHTTP2 = %v, want %v", transport.ForceAttemptHTTP2, !tt.disable)
+			}
+			if tt.disable && (transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0) {
+				t.Errorf("TLSNextProto = %v, want an empty, non-nil map", transport.TLSNextProto)
+			}
+
+			mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+			if got := proto.Load(); got != tt.wantProto {
+				t.Errorf("request protocol = %v, want %s", got, tt.wantProto)
+			}
+		})
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a"})
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	io.WriteString(writer, feed)
+	writer.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		wantWarn bool
+	}{
+		{"plain", "", []byte(feed), false},
+		{"gzip", "gzip", gzipped.Bytes(), false},
+		{"declared gzip but plain", "gzip", []byte(feed), true},
+		{"declared GZIP but plain", "GZIP", []byte(feed), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(tt.body)
+			}), Options{})
+			var logs bytes.Buffer
+			s.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+			response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+
+			if len(response.Bookmarks) != 1 || response.Bookmarks[0].URL != "https://example.com/a" {
+				t.Errorf("Bookmarks = %+v", response.Bookmarks)
+			}
+			if warned := strings.Contains(logs.String(), "declared gzip encoding but is not gzip"); warned != tt.wantWarn {
+				t.Errorf("warning logged = %v, want %v", warned, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestIncludeHeaders(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("Set-Cookie", "session=secret")
+		io.WriteString(w, rdfFeed())
+	}
+
+	tests := []struct {
+		name           string
+		includeHeaders bool
+		want           map[string]string
+	}{
+		{"off", false, nil},
+		{"on", true, map[string]string{
+			"Status":                "200 OK",
+			"Content-Type":          "application/rss+xml",
+			"ETag":                  `"v1"`,
+			"X-Ratelimit-Remaining": "42",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, http.HandlerFunc(handler), Options{})
+
+			response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", IncludeHeaders: tt.includeHeaders})
+
+			got := response.DebugHeaders
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("DebugHeaders = %v, want none", got)
+				}
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("DebugHeaders = %v, want %v", got, tt.want)
+			}
+			for name, value := range tt.want {
+				if got[name] != value {
+					t.Errorf("DebugHeaders[%s] = %q, want %q (all: %v)", name, got[name], value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestConnectionTimeoutsOnTransport(t *testing.T) {
+	tests := []struct {
+		name                  string
+		options               Options
+		tlsHandshakeTimeout   time.Duration
+		responseHeaderTimeout time.Duration
+		requestTimeout        time.Duration
+	}{
+		{"defaults", Options{}, DefaultTLSHandshakeTimeout, DefaultResponseHeaderTimeout, DefaultRequestTimeout},
+		{"configured", Options{
+			DialTimeout:           time.Second,
+			TLSHandshakeTimeout:   2 * time.Second,
+			ResponseHeaderTimeout: 3 * time.Second,
+			RequestTimeout:        4 * time.Minute,
+		}, 2 * time.Second, 3 * time.Second, 4 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewBookmarkServiceWithOptions(testLogger(), tt.options)
+			defer s.Close()
+
+			transport := s.client.Transport.(*http.Transport)
+			if transport.TLSHandshakeTimeout != tt.tlsHandshakeTimeout {
+				t.Errorf("TLSHandshakeTimeout = %s, want %s", transport.TLSHandshakeTimeout, tt.tlsHandshakeTimeout)
+			}
+			if transport.ResponseHeaderTimeout != tt.responseHeaderTimeout {
+				t.Errorf("ResponseHeaderTimeout = %s, want %s", transport.ResponseHeaderTimeout, tt.responseHeaderTimeout)
+			}
+			if s.client.Timeout != tt.requestTimeout {
+				t.Errorf("client Timeout = %s, want %s", s.client.Timeout, tt.requestTimeout)
+			}
+			if transport.DialContext == nil {
+				t.Error("DialContext is not set")
+			}
+		})
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    time.Duration
+	}{
+		{"default", Options{}, DefaultDialTimeout},
+		{"configured", Options{DialTimeout: 750 * time.Millisecond}, 750 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newDialer(tt.options.withDefaults()).Timeout; got != tt.want {
+				t.Errorf("dialer Timeout = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseHeaderTimeoutSeparateFromBody(t *testing.T) {
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a"})
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr bool
+	}{
+		{"stalled headers", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(500 * time.Millisecond):
+			}
+			io.WriteString(w, feed)
+		}, true},
+		{"slow body", func(w http.ResponseWriter, r *http.Request) {
+			// Headers arrive at once, the body takes longer than the
+			// header timeout but less than the request timeout
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			half := len(feed) / 2
+			io.WriteString(w, feed[:half])
+			w.(http.Flusher).Flush()
+			time.Sleep(150 * time.Millisecond)
+			io.WriteString(w, feed[half:])
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, tt.handler, Options{
+				ResponseHeaderTimeout: 50 * time.Millisecond,
+				RequestTimeout:        5 * time.Second,
+				CacheTTL:              -1,
+			})
+
+			_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+			if tt.wantErr && !errors.Is(err, types.ErrNetwork) {
+				t.Errorf("GetBookmarks() error = %v, want a network error", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("GetBookmarks() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestAcceptHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{"default", Options{}, "application/rss+xml, application/xml"},
+		{"configured", Options{Accept: "application/rdf+xml"}, "application/rdf+xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			accepts := make(map[string]string)
+			s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				accepts[r.URL.Path] = r.Header.Get("Accept")
+				mu.Unlock()
+				io.WriteString(w, rdfFeed(testItem{Title: "A", Link: "https://example.com/a"}))
+			}), tt.options)
+
+			if _, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"}); err != nil {
+				t.Fatalf("GetBookmarks() error = %v", err)
+			}
+			if _, err := s.GetTagFeed(context.Background(), "go", "", 0); err != nil {
+				t.Fatalf("GetTagFeed() error = %v", err)
+			}
+
+			for _, path := range []string{"/alice/rss", "/t/go"} {
+				if got := accepts[path]; got != tt.want {
+					t.Errorf("%s: Accept = %q, want %q", path, got, tt.want)
+				}
+			}
+		})
+	}
+}
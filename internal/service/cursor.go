@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// cursorState is the paging and filter state encoded in a cursor
+type cursorState struct {
+	Username string `json:"u"`
+	Tag      string `json:"t,omitempty"`
+	Date     string `json:"d,omitempty"`
+	URL      string `json:"l,omitempty"`
+	Page     int    `json:"p"`
+}
+
+// encodeCursor returns an opaque cursor for the page after params
+func encodeCursor(params types.GetHatenaBookmarksParams, page int) string {
+	data, err := json.Marshal(cursorState{
+		Username: params.Username,
+		Tag:      params.Tag,
+		Date:     params.Date,
+		URL:      params.URL,
+		Page:     page + 1,
+	})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// applyCursor decodes params.Cursor into the filters and page it encodes.
+// The cursor cannot be combined with explicit filters or a page, and must
+// belong to the requested username.
+func applyCursor(params types.GetHatenaBookmarksParams) (types.GetHatenaBookmarksParams, error) {
+	invalid := func(reason string) error {
+		return &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("Invalid cursor: %s", reason),
+			Details: map[string]interface{}{"field": "cursor"},
+		}
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(params.Cursor)
+	if err != nil {
+		return params, invalid("not a cursor returned by this server")
+	}
+
+	var state cursorState
+	if err := json.Unmarshal(data, &state); err != nil || state.Page < 1 {
+		return params, invalid("not a cursor returned by this server")
+	}
+
+	if params.Tag != "" || params.Date != "" || params.URL != "" || params.Page > 0 {
+		return params, invalid("cannot be combined with tag, date, url or page")
+	}
+
+	if state.Username != params.Username {
+		return params, invalid("issued for a different username")
+	}
+
+	params.Tag = state.Tag
+	params.Date = state.Date
+	params.URL = state.URL
+	params.Page = state.Page
+	return params, nil
+}
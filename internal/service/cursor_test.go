@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestGetBookmarksCursorRoundTrip(t *testing.T) {
+	pages := pagedFeeds(numberedPages(2)...)
+
+	var mu sync.Mutex
+	var tags []string
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tags = append(tags, r.URL.Query().Get("tag"))
+		mu.Unlock()
+		pages(w, r)
+	}), Options{CacheTTL: -1})
+
+	first := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Tag: "go"})
+	if first.NextCursor == "" {
+		t.Fatal("first page has no NextCursor")
+	}
+
+	second := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Cursor: first.NextCursor})
+	want := []string{"https://example.com/2/a", "https://example.com/2/b"}
+	if got := bookmarkURLs(second.Bookmarks); !reflect.DeepEqual(got, want) {
+		t.Errorf("second page = %v, want %v", got, want)
+	}
+	if second.Page != 2 {
+		t.Errorf("Page = %d, want 2", second.Page)
+	}
+
+	// The cursor past the last page leads to an empty page without a cursor
+	third := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", Cursor: second.NextCursor})
+	if len(third.Bookmarks) != 0 || third.NextCursor != "" {
+		t.Errorf("third page = %d bookmarks, cursor %q, want none", len(third.Bookmarks), third.NextCursor)
+	}
+
+	// Every request kept the tag filter the first call was made with
+	if !reflect.DeepEqual(tags, []string{"go", "go", "go"}) {
+		t.Errorf("requested tags = %q, want the tag kept across pages", tags)
+	}
+}
+
+func TestGetBookmarksInvalidCursor(t *testing.T) {
+	cursor := encodeCursor(types.GetHatenaBookmarksParams{Username: "alice"}, 1)
+
+	tests := []struct {
+		name   string
+		params types.GetHatenaBookmarksParams
+	}{
+		{"not base64", types.GetHatenaBookmarksParams{Username: "alice", Cursor: "!!!"}},
+		{"not JSON", types.GetHatenaBookmarksParams{Username: "alice", Cursor: base64.RawURLEncoding.EncodeToString([]byte("page 2"))}},
+		{"no page", types.GetHatenaBookmarksParams{Username: "alice", Cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"u":"alice"}`))}},
+		{"with tag", types.GetHatenaBookmarksParams{Username: "alice", Cursor: cursor, Tag: "go"}},
+		{"with page", types.GetHatenaBookmarksParams{Username: "alice", Cursor: cursor, Page: 3}},
+		{"other username", types.GetHatenaBookmarksParams{Username: "bob", Cursor: cursor}},
+	}
+
+	s := newTestService(t, serveFeed(rdfFeed(), nil), Options{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.GetBookmarks(context.Background(), tt.params)
+			if !errors.Is(err, types.ErrValidation) {
+				t.Errorf("GetBookmarks() error = %v, want a validation error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// Named date format presets accepted by the date_format parameter
+var dateFormatPresets = map[string]string{
+	"rfc3339":   time.RFC3339,
+	"date_only": time.DateOnly,
+	"jp":        "2006年01月02日 15:04:05",
+}
+
+// dateLayout resolves a date_format value, either a preset name or a Go
+// layout, to a layout. An empty value selects RFC 3339.
+func dateLayout(dateFormat string) (string, error) {
+	if dateFormat == "" {
+		return time.RFC3339, nil
+	}
+	if layout, ok := dateFormatPresets[dateFormat]; ok {
+		return layout, nil
+	}
+
+	// A layout without any reference-time element formats to itself
+	if time.Unix(0, 0).UTC().Format(dateFormat) == dateFormat {
+		return "", &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("Invalid date_format %q: expected rfc3339, date_only, jp or a Go time layout", dateFormat),
+			Details: map[string]interface{}{"date_format": dateFormat},
+		}
+	}
+	return dateFormat, nil
+}
+
+// dateLocation resolves a timezone value to a location. An empty value
+// selects UTC.
+func dateLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, (&types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("Invalid timezone %q: expected an IANA name such as Asia/Tokyo", timezone),
+			Details: map[string]interface{}{"timezone": timezone},
+		}).WithCause(err)
+	}
+	return location, nil
+}
+
+// countDateFallbacks returns the number of bookmarks whose feed date was
+// missing or unparseable
+func countDateFallbacks(items []types.BookmarkItem) int {
+	count := 0
+	for _, item := range items {
+		if item.DateFallback {
+			count++
+		}
+	}
+	return count
+}
+
+// addEpochs sets BookmarkedAtUnix from BookmarkedAt. Bookmarks whose date
+// is a parse-time fallback or not RFC 3339 are left without one.
+func addEpochs(items []types.BookmarkItem) {
+	for i := range items {
+		if items[i].DateFallback {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, items[i].BookmarkedAt); err == nil {
+			items[i].BookmarkedAtUnix = t.Unix()
+		}
+	}
+}
+
+// formatDates rewrites BookmarkedAt in the given layout and location.
+// Timestamps that are not RFC 3339 are left unchanged.
+func formatDates(items []types.BookmarkItem, layout string, location *time.Location) {
+	for i := range items {
+		if t, err := time.Parse(time.RFC3339, items[i].BookmarkedAt); err == nil {
+			items[i].BookmarkedAt = t.In(location).Format(layout)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestDateLayout(t *testing.T) {
+	tests := []struct {
+		dateFormat string
+		want       string
+		wantErr    bool
+	}{
+		{"", "2006-01-02T15:04:05Z07:00", false},
+		{"rfc3339", "2006-01-02T15:04:05Z07:00", false},
+		{"date_only", "2006-01-02", false},
+		{"jp", "2006年01月02日 15:04:05", false},
+		{"02/01/2006", "02/01/2006", false},
+		{"yyyy-mm-dd", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dateFormat, func(t *testing.T) {
+			got, err := dateLayout(tt.dateFormat)
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrValidation) {
+					t.Errorf("error = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("dateLayout() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBookmarksDateFormat(t *testing.T) {
+	// 2024-02-10T20:00:00Z, already the next day in Japan
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a", Date: "2024-02-11T05:00:00+09:00"})
+
+	tests := []struct {
+		name       string
+		dateFormat string
+		timezone   string
+		want       string
+	}{
+		{"default", "", "", "2024-02-11T05:00:00+09:00"},
+		{"rfc3339 in UTC", "rfc3339", "UTC", "2024-02-10T20:00:00Z"},
+		{"date_only in Asia/Tokyo", "date_only", "Asia/Tokyo", "2024-02-11"},
+		{"date_only defaults to UTC", "date_only", "", "2024-02-10"},
+		{"jp", "jp", "Asia/Tokyo", "2024年02月11日 05:00:00"},
+		{"timezone alone", "", "America/New_York", "2024-02-10T15:00:00-05:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, serveFeed(feed, nil), Options{})
+
+			response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{
+				Username:   "alice",
+				DateFormat: tt.dateFormat,
+				Timezone:   tt.timezone,
+			})
+			if got := response.Bookmarks[0].BookmarkedAt; got != tt.want {
+				t.Errorf("BookmarkedAt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBookmarksInvalidDateFormat(t *testing.T) {
+	s := newTestService(t, serveFeed(rdfFeed(), nil), Options{})
+
+	for _, params := range []types.GetHatenaBookmarksParams{
+		{Username: "alice", DateFormat: "yyyy-mm-dd"},
+		{Username: "alice", Timezone: "Mars/Olympus_Mons"},
+	} {
+		if _, err := s.GetBookmarks(context.Background(), params); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("GetBookmarks(%+v) error = %v, want a validation error", params, err)
+		}
+	}
+}
+
+func TestAddEpochs(t *testing.T) {
+	items := []types.BookmarkItem{
+		{BookmarkedAt: "2024-02-11T05:00:00+09:00"},
+		{BookmarkedAt: "2024-02-10T20:00:00Z"},
+		{BookmarkedAt: "2024-02-10T20:00:00Z", DateFallback: true},
+		{BookmarkedAt: "2024年02月11日 05:00:00"},
+	}
+
+	addEpochs(items)
+
+	want := []int64{1707595200, 1707595200, 0, 0}
+	for i, item := range items {
+		if item.BookmarkedAtUnix != want[i] {
+			t.Errorf("item %d: BookmarkedAtUnix = %d, want %d", i, item.BookmarkedAtUnix, want[i])
+		}
+	}
+}
+
+func TestGetBookmarksIncludeEpoch(t *testing.T) {
+	feed := rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a", Date: "2024-02-11T05:00:00+09:00"},
+		testItem{Title: "B", Link: "https://example.com/b", Date: "someday"},
+	)
+	s := newTestService(t, serveFeed(feed, nil), Options{CacheTTL: -1})
+
+	// The epoch is taken before the date is reformatted
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", IncludeEpoch: true, DateFormat: "jp", Timezone: "Asia/Tokyo"})
+	dated, undated := response.Bookmarks[0], response.Bookmarks[1]
+	if dated.BookmarkedAtUnix != time.Date(2024, 2, 10, 20, 0, 0, 0, time.UTC).Unix() || dated.BookmarkedAt != "2024年02月11日 05:00:00" {
+		t.Errorf("dated bookmark = %q at %d", dated.BookmarkedAt, dated.BookmarkedAtUnix)
+	}
+	if undated.BookmarkedAtUnix != 0 {
+		t.Errorf("fallback-dated bookmark has BookmarkedAtUnix %d, want it omitted", undated.BookmarkedAtUnix)
+	}
+	if data, _ := json.Marshal(undated); strings.Contains(string(data), "bookmarked_at_unix") {
+		t.Errorf("fallback-dated bookmark JSON = %s, want no bookmarked_at_unix", data)
+	}
+
+	response = mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+	if response.Bookmarks[0].BookmarkedAtUnix != 0 {
+		t.Error("BookmarkedAtUnix set without include_epoch")
+	}
+}
+
+func TestCountDateFallbacks(t *testing.T) {
+	items := []types.BookmarkItem{{DateFallback: true}, {}, {DateFallback: true}}
+
+	if got := countDateFallbacks(items); got != 2 {
+		t.Errorf("countDateFallbacks() = %d, want 2", got)
+	}
+	if got := countDateFallbacks(nil); got != 0 {
+		t.Errorf("countDateFallbacks(nil) = %d, want 0", got)
+	}
+}
+
+func TestGetBookmarksDateFallbackCount(t *testing.T) {
+	feed := rdfFeed(
+		testItem{Title: "A", Link: "https://example.com/a"},
+		testItem{Title: "B", Link: "https://example.com/b", Date: "someday"},
+		testItem{Title: "C", Link: "https://example.com/c", Date: "2024/02/10"},
+		testItem{Title: "D", Link: "https://example.com/d", Date: " "},
+	)
+	s := newTestService(t, pagedFeeds(feed), Options{})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+	if response.DateFallbackCount != 3 {
+		t.Errorf("DateFallbackCount = %d, want 3", response.DateFallbackCount)
+	}
+
+	// A reliable feed omits the count
+	dated := newTestService(t, pagedFeeds(rdfFeed(testItem{Title: "A", Link: "https://example.com/a"})), Options{})
+	encoded, err := json.Marshal(mustGetBookmarks(t, dated, types.GetHatenaBookmarksParams{Username: "alice"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(encoded), "date_fallback_count") {
+		t.Errorf("response %s has date_fallback_count, want it omitted", encoded)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// DefaultStarBaseURL is the default Hatena Star base URL
+const DefaultStarBaseURL = "https://s.hatena.ne.jp"
+
+// MaxEntryBookmarkers caps the number of bookmarkers get_entry_detail returns
+const MaxEntryBookmarkers = 20
+
+// entryResponse is the JSON returned by the entry API (/entry/jsonlite/)
+type entryResponse struct {
+	Count     int `json:"count"`
+	Bookmarks []struct {
+		User      string   `json:"user"`
+		Comment   string   `json:"comment"`
+		Tags      []string `json:"tags"`
+		Timestamp string   `json:"timestamp"`
+	} `json:"bookmarks"`
+}
+
+// starResponse is the JSON returned by the Hatena Star API (/entry.json)
+type starResponse struct {
+	Entries []struct {
+		// Stars holds one object per star, or a number standing in for
+		// several stars when there are many
+		Stars        []json.RawMessage `json:"stars"`
+		ColoredStars []struct {
+			Stars []json.RawMessage `json:"stars"`
+		} `json:"colored_stars"`
+	} `json:"entries"`
+}
+
+// GetEntryDetail returns the bookmark count, recent bookmarkers and star
+// count of an entry. The entry and star lookups fail independently; a failed
+// lookup leaves its fields null and is reported in Errors. An error is
+// returned only if both fail.
+func (s *BookmarkService) GetEntryDetail(ctx context.Context, entryURL string) (*types.EntryDetail, error) {
+	entryURL = strings.TrimSpace(entryURL)
+	if entryURL == "" {
+		return nil, &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "URL is required",
+			Details: map[string]interface{}{"field": "url"},
+		}
+	}
+	if err := s.validator.ValidateURL(entryURL); err != nil {
+		return nil, err
+	}
+
+	detail := &types.EntryDetail{URL: entryURL}
+
+	entryErr := s.fillEntryBookmarks(ctx, detail)
+	if entryErr != nil {
+		s.log(ctx).Warn("Failed to fetch entry bookmarks", "url", entryURL, "error", entryErr)
+		addEntryError(detail, "entry", entryErr)
+	}
+
+	starErr := s.fillEntryStars(ctx, detail)
+	if starErr != nil {
+		s.log(ctx).Warn("Failed to fetch entry stars", "url", entryURL, "error", starErr)
+		addEntryError(detail, "stars", starErr)
+	}
+
+	if entryErr != nil && starErr != nil {
+		return nil, entryErr
+	}
+
+	return detail, nil
+}
+
+// addEntryError records why a part of the entry detail is missing
+func addEntryError(detail *types.EntryDetail, part string, err error) {
+	if detail.Errors == nil {
+		detail.Errors = make(map[string]string)
+	}
+	detail.Errors[part] = err.Error()
+}
+
+// fillEntryBookmarks fills the bookmark count and recent bookmarkers
+func (s *BookmarkService) fillEntryBookmarks(ctx context.Context, detail *types.EntryDetail) error {
+	requestURL := fmt.Sprintf("%s/entry/jsonlite/?url=%s", s.baseURL, url.QueryEscape(detail.URL))
+
+	body, err := s.fetchJSON(ctx, requestURL)
+	if err != nil {
+		return err
+	}
+
+	var entry entryResponse
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return (&types.MCPError{
+			Code:    types.ErrorCodeParsing,
+			Message: fmt.Sprintf("Failed to parse entry response: %v", err),
+			Details: map[string]interface{}{"url": requestURL},
+		}).WithCause(err)
+	}
+
+	count := entry.Count
+	detail.BookmarkCount = &count
+
+	// The entry API lists bookmarks newest first
+	detail.Bookmarkers = make([]types.EntryBookmarker, 0, min(len(entry.Bookmarks), MaxEntryBookmarkers))
+	for _, bookmark := range entry.Bookmarks {
+		if len(detail.Bookmarkers) == MaxEntryBookmarkers {
+			break
+		}
+		detail.Bookmarkers = append(detail.Bookmarkers, types.EntryBookmarker{
+			User:         bookmark.User,
+			Comment:      strings.TrimSpace(bookmark.Comment),
+			Tags:         bookmark.Tags,
+			BookmarkedAt: bookmark.Timestamp,
+		})
+	}
+
+	return nil
+}
+
+// fillEntryStars fills the total star count, colored stars included
+func (s *BookmarkService) fillEntryStars(ctx context.Context, detail *types.EntryDetail) error {
+	requestURL := fmt.Sprintf("%s/entry.json?uri=%s", s.options.StarBaseURL, url.QueryEscape(detail.URL))
+
+	body, err := s.fetchJSON(ctx, requestURL)
+	if err != nil {
+		return err
+	}
+
+	var stars starResponse
+	if err := json.Unmarshal(body, &stars); err != nil {
+		return (&types.MCPError{
+			Code:    types.ErrorCodeParsing,
+			Message: fmt.Sprintf("Failed to parse star response: %v", err),
+			Details: map[string]interface{}{"url": requestURL},
+		}).WithCause(err)
+	}
+
+	count := 0
+	for _, entry := range stars.Entries {
+		count += countStars(entry.Stars)
+		for _, colored := range entry.ColoredStars {
+			count += countStars(colored.Stars)
+		}
+	}
+	detail.StarCount = &count
+
+	return nil
+}
+
+// countStars counts star objects, adding numeric placeholders as their value
+func countStars(stars []json.RawMessage) int {
+	count := 0
+	for _, star := range stars {
+		var n int
+		if err := json.Unmarshal(star, &n); err == nil {
+			count += n
+			continue
+		}
+		count++
+	}
+	return count
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+const testEntryURL = "https://go.dev/blog/go1.22"
+
+// fakeEntryAPI returns a handler serving the entry and star APIs for
+// testEntryURL, failing the APIs listed in failing with a 500
+func fakeEntryAPI(t *testing.T, failing ...string) http.HandlerFunc {
+	bookmarks := make([]map[string]interface{}, 0, MaxEntryBookmarkers+5)
+	for i := 0; i < MaxEntryBookmarkers+5; i++ {
+		bookmarks = append(bookmarks, map[string]interface{}{
+			"user":      fmt.Sprintf("user%d", i),
+			"comment":   " nice ",
+			"tags":      []string{"go"},
+			"timestamp": "2024/02/10 09:00",
+		})
+	}
+	entry, _ := json.Marshal(map[string]interface{}{"count": 512, "bookmarks": bookmarks})
+	// Two star objects, a placeholder standing for 10 stars, and 2 colored stars
+	stars := `{"entries":[{"stars":[{"name":"a"},{"name":"b"},10],"colored_stars":[{"color":"green","stars":[{"name":"c"},{"name":"d"}]}]}]}`
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept"); accept != "application/json" {
+			t.Errorf("Accept = %q, want application/json", accept)
+		}
+
+		var api, body, target string
+		switch r.URL.Path {
+		case "/entry/jsonlite/":
+			api, body, target = "entry", string(entry), r.URL.Query().Get("url")
+		case "/entry.json":
+			api, body, target = "stars", stars, r.URL.Query().Get("uri")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if target != testEntryURL {
+			t.Errorf("%s API asked for %q", api, target)
+		}
+		for _, f := range failing {
+			if f == api {
+				http.Error(w, "boom", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}
+}
+
+// newEntryTestService returns a service whose entry and star APIs are
+// served by handler
+func newEntryTestService(t *testing.T, handler http.Handler) *BookmarkService {
+	t.Helper()
+
+	s := newTestService(t, handler, Options{})
+	s.options.StarBaseURL = s.baseURL
+	return s
+}
+
+func TestGetEntryDetail(t *testing.T) {
+	s := newEntryTestService(t, fakeEntryAPI(t))
+
+	detail, err := s.GetEntryDetail(context.Background(), " "+testEntryURL+" ")
+	if err != nil {
+		t.Fatalf("GetEntryDetail() error = %v", err)
+	}
+
+	if detail.URL != testEntryURL || detail.Errors != nil {
+		t.Errorf("detail = %+v", detail)
+	}
+	if detail.BookmarkCount == nil || *detail.BookmarkCount != 512 {
+		t.Errorf("BookmarkCount = %v, want 512", detail.BookmarkCount)
+	}
+	if len(detail.Bookmarkers) != MaxEntryBookmarkers {
+		t.Fatalf("got %d bookmarkers, want %d", len(detail.Bookmarkers), MaxEntryBookmarkers)
+	}
+	if first := detail.Bookmarkers[0]; first.User != "user0" || first.Comment != "nice" {
+		t.Errorf("first bookmarker = %+v", first)
+	}
+	if detail.StarCount == nil || *detail.StarCount != 14 {
+		t.Errorf("StarCount = %v, want 14", detail.StarCount)
+	}
+}
+
+func TestGetEntryDetailPartialFailure(t *testing.T) {
+	tests := []struct {
+		failing   string
+		wantCount bool
+		wantStars bool
+	}{
+		{"entry", false, true},
+		{"stars", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.failing, func(t *testing.T) {
+			s := newEntryTestService(t, fakeEntryAPI(t, tt.failing))
+
+			detail, err := s.GetEntryDetail(context.Background(), testEntryURL)
+			if err != nil {
+				t.Fatalf("GetEntryDetail() error = %v, want a partial result", err)
+			}
+			if (detail.BookmarkCount != nil) != tt.wantCount || (detail.StarCount != nil) != tt.wantStars {
+				t.Errorf("BookmarkCount = %v, StarCount = %v", detail.BookmarkCount, detail.StarCount)
+			}
+			if len(detail.Errors) != 1 || detail.Errors[tt.failing] == "" {
+				t.Errorf("Errors = %v, want only %s reported", detail.Errors, tt.failing)
+			}
+		})
+	}
+}
+
+func TestGetEntryDetailBothFail(t *testing.T) {
+	s := newEntryTestService(t, fakeEntryAPI(t, "entry", "stars"))
+
+	if _, err := s.GetEntryDetail(context.Background(), testEntryURL); !errors.Is(err, types.ErrAPI) {
+		t.Errorf("error = %v, want the entry API error", err)
+	}
+}
+
+func TestGetEntryDetailValidation(t *testing.T) {
+	s := newEntryTestService(t, fakeEntryAPI(t))
+
+	for _, entryURL := range []string{"", "  ", "ftp://example.com/file", "not a url"} {
+		if _, err := s.GetEntryDetail(context.Background(), entryURL); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("GetEntryDetail(%q) error = %v, want a validation error", entryURL, err)
+		}
+	}
+}
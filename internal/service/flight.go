@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// flightGroup coalesces concurrent identical fetches so that they share a
+// single underlying request and result
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall represents an in-flight or completed fetch
+type flightCall struct {
+	done chan struct{}
+	data *types.ParsedRSSData
+	err  error
+}
+
+// do executes fn once per key among concurrent callers. Callers arriving while
+// a call for the same key is in flight wait for it and receive its result.
+// The shared result reports whether the result came from another caller.
+//
+// fn runs in its own goroutine, so it is not tied to any caller: each caller
+// waits only until its own ctx is done, returning ctx.Err(), while fn carries
+// on for the others. fn must therefore bound itself with its own deadline.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (*types.ParsedRSSData, error)) (*types.ParsedRSSData, bool, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call, shared := g.calls[key]
+	if !shared {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go func() {
+			call.data, call.err = fn()
+
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.data, shared, call.err
+	case <-ctx.Done():
+		return nil, shared, ctx.Err()
+	}
+}
+
+// cloneParsedData copies parsed data so that callers sharing a fetch result
+// can modify their bookmarks independently
+func cloneParsedData(data *types.ParsedRSSData) *types.ParsedRSSData {
+	if data == nil {
+		return nil
+	}
+
+	clone := *data
+	clone.ResponseHeaders = maps.Clone(data.ResponseHeaders)
+	clone.Warnings = append([]string(nil), data.Warnings...)
+	clone.Items = make([]types.BookmarkItem, len(data.Items))
+	for i, item := range data.Items {
+		item.Tags = slices.Clone(item.Tags)
+		clone.Items[i] = item
+	}
+
+	return &clone
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestFlightGroupCoalesces(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (*types.ParsedRSSData, error) {
+		calls.Add(1)
+		<-release
+		return &types.ParsedRSSData{Title: "shared"}, nil
+	}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	started := make(chan struct{}, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started <- struct{}{}
+			data, shared, err := g.do(context.Background(), "key", fn)
+			if err != nil || data.Title != "shared" {
+				t.Errorf("do() = %v, %v", data, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+	for i := 0; i < callers; i++ {
+		<-started
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("fn ran %d times, want 1", calls.Load())
+	}
+	if sharedCount.Load() != callers-1 {
+		t.Errorf("%d callers got a shared result, want %d", sharedCount.Load(), callers-1)
+	}
+
+	// Once done, the key runs again
+	if _, shared, _ := g.do(context.Background(), "key", fn); shared || calls.Load() != 2 {
+		t.Errorf("shared = %v, calls = %d after completion, want a new call", shared, calls.Load())
+	}
+}
+
+func TestFlightGroupCallerCancellation(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	fn := func() (*types.ParsedRSSData, error) {
+		<-release
+		return &types.ParsedRSSData{Title: "done"}, nil
+	}
+
+	// The first caller gives up; the fetch must carry on for the second
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(ctx, "key", fn)
+		firstErr <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	secondData := make(chan *types.ParsedRSSData, 1)
+	go func() {
+		data, _, _ := g.do(context.Background(), "key", fn)
+		secondData <- data
+	}()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if data := <-secondData; data == nil || data.Title != "done" {
+		t.Errorf("second caller got %v, want the fetch result", data)
+	}
+}
+
+func TestGetBookmarksSharesInFlightFetch(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		io.WriteString(w, rdfFeed(testItem{Title: "A", Link: "https://example.com/a", Tags: []string{}}))
+	}
+	s := newTestService(t, http.HandlerFunc(handler), Options{CacheTTL: -1})
+
+	// The first caller cancels while the fetch is under way
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := s.GetBookmarks(ctx, types.GetHatenaBookmarksParams{Username: "alice"})
+		firstErr <- err
+	}()
+	for hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan *types.GetHatenaBookmarksResponse, 1)
+	go func() {
+		response, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+		if err != nil {
+			t.Errorf("second GetBookmarks() error = %v", err)
+		}
+		second <- response
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, types.ErrNetwork) || !errors.Is(err, context.Canceled) {
+		t.Errorf("first GetBookmarks() error = %v, want a network error caused by the cancellation", err)
+	}
+
+	close(release)
+	response := <-second
+	if response == nil || len(response.Bookmarks) != 1 {
+		t.Fatalf("second response = %+v, want the shared result", response)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("server got %d requests, want 1", hits.Load())
+	}
+
+	// An empty tag list still encodes as [] after cloning
+	encoded, _ := json.Marshal(response.Bookmarks[0])
+	if !strings.Contains(string(encoded), `"tags":[]`) {
+		t.Errorf("bookmark = %s, want \"tags\":[]", encoded)
+	}
+}
+
+func TestCloneParsedData(t *testing.T) {
+	original := &types.ParsedRSSData{
+		Items:           []types.BookmarkItem{{URL: "https://example.com/a", Tags: []string{"go"}}, {URL: "https://example.com/b", Tags: []string{}}},
+		Warnings:        []string{"w"},
+		ResponseHeaders: map[string]string{"Etag": "x"},
+	}
+
+	clone := cloneParsedData(original)
+	clone.Items[0].Tags[0] = "changed"
+	clone.Items[0].URL = "changed"
+	clone.Warnings[0] = "changed"
+	clone.ResponseHeaders["Etag"] = "changed"
+
+	if original.Items[0].Tags[0] != "go" || original.Items[0].URL != "https://example.com/a" {
+		t.Error("modifying the clone's items changed the original")
+	}
+	if original.Warnings[0] != "w" || original.ResponseHeaders["Etag"] != "x" {
+		t.Error("modifying the clone's warnings or headers changed the original")
+	}
+	if clone.Items[1].Tags == nil {
+		t.Error("empty tag list became nil")
+	}
+	if cloneParsedData(nil) != nil {
+		t.Error("cloneParsedData(nil) != nil")
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// DefaultLoginPathPattern matches the path of Hatena's login page, which
+// private feeds redirect to when requested without authentication
+const DefaultLoginPathPattern = `(^|/)login(/|$)`
+
+// maxRedirects is the number of redirects followed, as by net/http
+const maxRedirects = 10
+
+// loginRedirectError reports a redirect to a login page
+type loginRedirectError struct {
+	URL string
+}
+
+// Error implements the error interface
+func (e *loginRedirectError) Error() string {
+	return fmt.Sprintf("redirected to login page %s", e.URL)
+}
+
+// redirectHostError reports a redirect to a host outside the allowlist
+type redirectHostError struct {
+	Host string
+}
+
+// Error implements the error interface
+func (e *redirectHostError) Error() string {
+	return fmt.Sprintf("redirected to disallowed host %q", e.Host)
+}
+
+// loginRedirectCheck returns a CheckRedirect function that stops at the
+// first redirect whose path matches the login path pattern, instead of
+// following the login redirects or returning the login page to the parser.
+// Redirects to hosts outside the allowlist are refused like direct requests.
+func loginRedirectCheck(options Options) func(*http.Request, []*http.Request) error {
+	loginPath := loginPathPattern(options)
+	return func(req *http.Request, via []*http.Request) error {
+		if loginPath.MatchString(req.URL.Path) {
+			return &loginRedirectError{URL: redactURL(req.URL)}
+		}
+		if !options.isAllowedHost(req.URL.Hostname()) {
+			return &redirectHostError{Host: req.URL.Hostname()}
+		}
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+}
+
+// loginPathPattern compiles Options.LoginPathPattern, falling back to
+// DefaultLoginPathPattern if it does not compile; Validate reports that case
+func loginPathPattern(options Options) *regexp.Regexp {
+	if pattern, err := regexp.Compile(options.LoginPathPattern); err == nil {
+		return pattern
+	}
+	return regexp.MustCompile(DefaultLoginPathPattern)
+}
+
+// redirectHostValidationError converts a redirect to a disallowed host into
+// the VALIDATION_ERROR returned by checkHost, or returns nil for other errors
+func redirectHostValidationError(err error, requestURL string) *types.MCPError {
+	var hostErr *redirectHostError
+	if !errors.As(err, &hostErr) {
+		return nil
+	}
+
+	return (&types.MCPError{
+		Code:    types.ErrorCodeValidation,
+		Message: fmt.Sprintf("Redirect to host %q is not allowed", hostErr.Host),
+		Details: map[string]interface{}{"url": requestURL, "host": hostErr.Host},
+	}).WithCause(err)
+}
+
+// authRequiredError converts a login redirect into the API_ERROR returned for
+// feeds that require authentication, or returns nil for other errors
+func authRequiredError(err error, requestURL string) *types.MCPError {
+	var loginErr *loginRedirectError
+	if !errors.As(err, &loginErr) {
+		return nil
+	}
+
+	return (&types.MCPError{
+		Code:    types.ErrorCodeAPI,
+		Message: "feed requires authentication",
+		Details: map[string]interface{}{
+			"url":        requestURL,
+			"login_url":  loginErr.URL,
+			"suggestion": "the feed is private; configure HATENA_AUTH_MODE or use a public feed",
+		},
+	}).WithCause(err)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestDisallowedBaseURL(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(serveFeed(rdfFeed(), &hits))
+	defer server.Close()
+
+	// The server is not on the default allowlist
+	s := NewBookmarkServiceWithOptions(testLogger(), Options{BaseURL: server.URL})
+	defer s.Close()
+
+	_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+	if !errors.Is(err, types.ErrValidation) || !strings.Contains(err.Error(), `host "127.0.0.1" are not allowed`) {
+		t.Errorf("GetBookmarks() error = %v, want the host rejected", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("server got %d requests, want none", hits.Load())
+	}
+}
+
+func TestRedirectToDisallowedHost(t *testing.T) {
+	var targetHits atomic.Int32
+	target := httptest.NewServer(serveFeed(rdfFeed(), &targetHits))
+	defer target.Close()
+
+	// Redirect to the same server by another name, which is not allowed
+	redirect := strings.Replace(target.URL, "127.0.0.1", "localhost", 1) + "/internal"
+	s := newTestService(t, http.RedirectHandler(redirect, http.StatusFound), Options{})
+
+	_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+
+	var mcpErr *types.MCPError
+	if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeValidation {
+		t.Fatalf("GetBookmarks() error = %v, want a validation error", err)
+	}
+	if !strings.Contains(mcpErr.Message, `Redirect to host "localhost" is not allowed`) {
+		t.Errorf("message = %q", mcpErr.Message)
+	}
+	if targetHits.Load() != 0 {
+		t.Errorf("redirect target got %d requests, want none", targetHits.Load())
+	}
+}
+
+func TestRedirectWithinAllowlist(t *testing.T) {
+	feed := rdfFeed(testItem{Title: "A", Link: "https://example.com/a"})
+	mux := http.NewServeMux()
+	mux.Handle("/alice/rss", http.RedirectHandler("/alice/bookmark/rss", http.StatusMovedPermanently))
+	mux.Handle("/alice/bookmark/rss", serveFeed(feed, nil))
+	s := newTestService(t, mux, Options{})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice"})
+	if len(response.Bookmarks) != 1 {
+		t.Errorf("got %d bookmarks, want 1 after following the redirect", len(response.Bookmarks))
+	}
+}
+
+// loginRedirects returns a handler that redirects feeds to loginPath, which
+// serves the login page fixture and redirects back to the feed, so that
+// following the redirects would loop. It counts requests for the login page.
+func loginRedirects(t *testing.T, loginPath string, loginHits *atomic.Int32) http.Handler {
+	t.Helper()
+
+	page, err := os.ReadFile(filepath.Join("testdata", "login.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/alice/rss", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, loginPath+"?location="+url.QueryEscape(r.URL.String()), http.StatusFound)
+	})
+	mux.HandleFunc(loginPath, func(w http.ResponseWriter, r *http.Request) {
+		loginHits.Add(1)
+		if location := r.URL.Query().Get("location"); location != "" {
+			http.Redirect(w, r, location, http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	return mux
+}
+
+func TestLoginRedirect(t *testing.T) {
+	tests := []struct {
+		name      string
+		loginPath string
+		pattern   string
+	}{
+		{"default pattern", "/login", ""},
+		{"nested login path", "/accounts/login/", ""},
+		{"configured pattern", "/accounts/signin", `^/accounts/signin$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var loginHits atomic.Int32
+			s := newTestService(t, loginRedirects(t, tt.loginPath, &loginHits), Options{LoginPathPattern: tt.pattern})
+
+			_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+
+			var mcpErr *types.MCPError
+			if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeAPI || mcpErr.Message != "feed requires authentication" {
+				t.Fatalf("GetBookmarks() error = %v, want feed requires authentication", err)
+			}
+			details, _ := mcpErr.Details.(map[string]interface{})
+			if loginURL, _ := details["login_url"].(string); !strings.Contains(loginURL, tt.loginPath) {
+				t.Errorf("login_url = %v, want the login page", details["login_url"])
+			}
+			if got := loginHits.Load(); got != 0 {
+				t.Errorf("login page got %d requests, want none", got)
+			}
+		})
+	}
+}
+
+func TestLoginRedirectOtherPattern(t *testing.T) {
+	// A configured pattern replaces the default, so /login is followed and
+	// the loop ends at the redirect limit
+	var loginHits atomic.Int32
+	s := newTestService(t, loginRedirects(t, "/login", &loginHits), Options{LoginPathPattern: `^/accounts/signin$`})
+
+	_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+	if err == nil || strings.Contains(err.Error(), "feed requires authentication") {
+		t.Errorf("GetBookmarks() error = %v, want a redirect failure", err)
+	}
+	if got := loginHits.Load(); got == 0 {
+		t.Error("login page was never requested")
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// maintenanceScanBytes bounds how much of an HTML response is searched for
+// maintenance markers
+const maintenanceScanBytes = 64 << 10
+
+// maintenanceMarkers are phrases found on Hatena's maintenance notice
+// pages, compared case-insensitively
+var maintenanceMarkers = [][]byte{
+	[]byte("メンテナンス"),
+	[]byte("maintenance"),
+}
+
+// isMaintenancePage reports whether a response is an HTML maintenance
+// notice served in place of a feed. Pages served with status 200 qualify
+// only if their title names maintenance, since ordinary pages may mention it
+// anywhere in their text; pages signalling unavailability with status 503
+// or Retry-After may carry the marker anywhere.
+func isMaintenancePage(resp *http.Response, body []byte) bool {
+	if !isHTML(resp.Header.Get("Content-Type"), body) {
+		return false
+	}
+
+	if len(body) > maintenanceScanBytes {
+		body = body[:maintenanceScanBytes]
+	}
+	body = bytes.ToLower(body)
+
+	unavailable := resp.StatusCode == http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != ""
+	if !unavailable {
+		body = htmlTitle(body)
+	}
+
+	for _, marker := range maintenanceMarkers {
+		if bytes.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlTitle returns the text of the title element of a lowercased HTML
+// document, or nil if it has none
+func htmlTitle(body []byte) []byte {
+	start := bytes.Index(body, []byte("<title"))
+	if start < 0 {
+		return nil
+	}
+	body = body[start:]
+
+	open := bytes.IndexByte(body, '>')
+	end := bytes.Index(body, []byte("</title"))
+	if open < 0 || end < open {
+		return nil
+	}
+	return body[open+1 : end]
+}
+
+// isHTML reports whether a response is HTML, by its Content-Type or, when
+// that is missing, by sniffing the body
+func isHTML(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// maintenanceError builds the API_ERROR returned while Hatena is under
+// maintenance, passing on Retry-After when the page sets it
+func maintenanceError(resp *http.Response, requestURL string) *types.MCPError {
+	details := map[string]interface{}{
+		"url":         requestURL,
+		"maintenance": true,
+		"suggestion":  "retry later",
+	}
+	if retryAfter := strings.TrimSpace(resp.Header.Get("Retry-After")); retryAfter != "" {
+		details["retry_after"] = retryAfter
+	}
+
+	return &types.MCPError{
+		Code:    types.ErrorCodeAPI,
+		Message: "Hatena is under maintenance; please retry later",
+		Details: details,
+	}
+}
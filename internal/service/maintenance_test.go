@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// maintenancePage returns the maintenance notice fixture
+func maintenancePage(t *testing.T) []byte {
+	t.Helper()
+
+	page, err := os.ReadFile(filepath.Join("testdata", "maintenance.html"))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	return page
+}
+
+func TestIsMaintenancePage(t *testing.T) {
+	page := maintenancePage(t)
+	mentioning := []byte(`<html><head><title>Release notes</title></head><body>Scheduled maintenance is over.</body></html>`)
+
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		retryAfter  string
+		body        []byte
+		want        bool
+	}{
+		{"notice", http.StatusOK, "text/html; charset=utf-8", "", page, true},
+		{"sniffed notice", http.StatusOK, "", "", page, true},
+		{"English title", http.StatusOK, "text/html", "", []byte(`<html><head><TITLE>Under Maintenance</TITLE></head></html>`), true},
+		{"marker outside the title", http.StatusOK, "text/html", "", mentioning, false},
+		{"marker outside the title with 503", http.StatusServiceUnavailable, "text/html", "", mentioning, true},
+		{"marker outside the title with Retry-After", http.StatusOK, "text/html", "120", mentioning, true},
+		{"feed", http.StatusOK, "application/rss+xml", "", []byte(rdfFeed()), false},
+		{"HTML without marker", http.StatusServiceUnavailable, "text/html", "", []byte(`<html><title>Error</title></html>`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			if got := isMaintenancePage(resp, tt.body); got != tt.want {
+				t.Errorf("isMaintenancePage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBookmarksMaintenance(t *testing.T) {
+	page := maintenancePage(t)
+
+	tests := []struct {
+		name           string
+		status         int
+		retryAfter     string
+		wantRetryAfter interface{}
+	}{
+		{"served with 200", http.StatusOK, "", nil},
+		{"served with 503", http.StatusServiceUnavailable, "3600", "3600"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				w.Write(page)
+			}), Options{})
+
+			_, err := s.GetBookmarks(context.Background(), types.GetHatenaBookmarksParams{Username: "alice"})
+
+			var mcpErr *types.MCPError
+			if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeAPI {
+				t.Fatalf("GetBookmarks() error = %v, want an API error", err)
+			}
+			if mcpErr.Message != "Hatena is under maintenance; please retry later" {
+				t.Errorf("Message = %q", mcpErr.Message)
+			}
+			details, _ := mcpErr.Details.(map[string]interface{})
+			if details["maintenance"] != true || details["suggestion"] != "retry later" || details["retry_after"] != tt.wantRetryAfter {
+				t.Errorf("Details = %v", details)
+			}
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"hatena-bookmark-mcp/internal/parser"
+	"hatena-bookmark-mcp/internal/utils"
+)
+
+// DefaultBaseURL is the default Hatena Bookmark base URL
+const DefaultBaseURL = "https://b.hatena.ne.jp"
+
+// DefaultMaxConcurrentRequests is the default cap on in-flight HTTP requests
+const DefaultMaxConcurrentRequests = 4
+
+// DefaultCacheTTL is how long fetched feeds are cached by default
+const DefaultCacheTTL = 5 * time.Minute
+
+// DefaultCacheNegativeTTL is how long empty results and missing feeds are
+// cached by default
+const DefaultCacheNegativeTTL = 30 * time.Second
+
+// DefaultStreamThreshold is the feed size in bytes above which feeds are
+// parsed one item at a time instead of being unmarshaled whole
+const DefaultStreamThreshold = 1 << 20
+
+// Default HTTP timeouts. The overall request timeout is a generous ceiling
+// so that large feeds on slow but steady links can finish downloading; the
+// connection-level timeouts catch stalled connects and unresponsive servers.
+const (
+	DefaultDialTimeout           = 5 * time.Second
+	DefaultTLSHandshakeTimeout   = 5 * time.Second
+	DefaultResponseHeaderTimeout = 10 * time.Second
+	DefaultRequestTimeout        = 60 * time.Second
+)
+
+// DefaultMaxPages is the default number of pages FetchAll retrieves
+const DefaultMaxPages = 10
+
+// DefaultMaxItems is the default number of bookmarks FetchAll collects
+const DefaultMaxItems = 1000
+
+// Reasons reported when fetching all pages stops early
+const (
+	TruncatedByMaxPages       = "max_pages"
+	TruncatedByMaxItems       = "max_items"
+	TruncatedByOverallTimeout = "overall_timeout"
+	TruncatedByPageError      = "page_error"
+)
+
+// DefaultUserAgent is the User-Agent sent with every request
+const DefaultUserAgent = "hatena-bookmark-mcp/1.0"
+
+// DefaultAccept is the Accept header sent with feed requests, so that
+// endpoints which negotiate content serve RSS rather than HTML
+const DefaultAccept = "application/rss+xml, application/xml"
+
+// DefaultReadLaterTag is the tag Hatena users commonly use for "read later"
+const DefaultReadLaterTag = "あとで読む"
+
+// Defaults for per-item checks such as short URL resolution
+const (
+	DefaultCheckConcurrency    = 8
+	DefaultMaxChecksPerRequest = 200
+)
+
+// DefaultWarmConcurrency is the default number of parameter sets Warm
+// fetches at once
+const DefaultWarmConcurrency = 2
+
+// DefaultWarmInterval is the default minimum time between the fetches Warm
+// starts, so that warming many users does not burst requests at startup
+const DefaultWarmInterval = 200 * time.Millisecond
+
+// DefaultAllowedHosts lists the hosts requests may be sent to by default
+var DefaultAllowedHosts = []string{
+	"b.hatena.ne.jp",
+	"bookmark.hatenaapis.com",
+	"s.hatena.ne.jp",
+}
+
+// Options configures optional service behavior
+type Options struct {
+	// BaseURL is the Hatena Bookmark base URL (defaults to DefaultBaseURL)
+	BaseURL string
+
+	// StarBaseURL is the Hatena Star base URL (defaults to DefaultStarBaseURL)
+	StarBaseURL string
+
+	// AllowedHosts lists the hosts requests may be sent to
+	// (defaults to DefaultAllowedHosts)
+	AllowedHosts []string
+
+	// AllowAnyHost disables the AllowedHosts check
+	AllowAnyHost bool
+
+	// LogHTTPBodies enables debug logging of outgoing requests and
+	// truncated response bodies. Never enabled by default.
+	LogHTTPBodies bool
+
+	// Clock returns the current time (defaults to time.Now)
+	Clock func() time.Time
+
+	// MaxConcurrentRequests caps the total number of in-flight HTTP requests
+	// across all operations (defaults to DefaultMaxConcurrentRequests)
+	MaxConcurrentRequests int
+
+	// MaxItems caps the number of bookmarks FetchAll collects
+	// (defaults to DefaultMaxItems)
+	MaxItems int
+
+	// PageTimeout bounds each page request made by FetchAll (0 = only the
+	// HTTP client timeout applies)
+	PageTimeout time.Duration
+
+	// FetchAllTimeout bounds a whole FetchAll call across all pages
+	// (0 = no overall deadline)
+	FetchAllTimeout time.Duration
+
+	// ProgressLogInterval logs FetchAll progress every N pages
+	// (defaults to 1, logging every page)
+	ProgressLogInterval int
+
+	// DialTimeout bounds establishing a TCP connection
+	// (defaults to DefaultDialTimeout)
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout bounds the TLS handshake
+	// (defaults to DefaultTLSHandshakeTimeout)
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout bounds waiting for response headers once the
+	// request is sent (defaults to DefaultResponseHeaderTimeout)
+	ResponseHeaderTimeout time.Duration
+
+	// RequestTimeout bounds a whole request, including reading the body
+	// (defaults to DefaultRequestTimeout)
+	RequestTimeout time.Duration
+
+	// TLSMinVersion is the minimum accepted TLS version
+	// (defaults to tls.VersionTLS12)
+	TLSMinVersion uint16
+
+	// PinnedCertSHA256 optionally pins the server's leaf certificate to one
+	// of these hex-encoded SHA-256 fingerprints
+	PinnedCertSHA256 []string
+
+	// AuthMode selects how requests are authenticated:
+	// AuthModeNone (default), AuthModeWSSE or AuthModeBearer
+	AuthMode string
+
+	// AuthUsername is the Hatena username used for WSSE authentication
+	AuthUsername string
+
+	// AuthToken is the API key (WSSE) or bearer token. It is never logged.
+	AuthToken string
+
+	// NonceSource provides random bytes for WSSE nonces
+	// (defaults to crypto/rand.Reader)
+	NonceSource io.Reader
+
+	// ShortenerHosts lists the URL shortener hosts whose links are resolved
+	// when requested (defaults to DefaultShortenerHosts)
+	ShortenerHosts []string
+
+	// ReadLaterTag is the tag get_read_later looks for
+	// (defaults to DefaultReadLaterTag)
+	ReadLaterTag string
+
+	// CheckConcurrency caps how many per-item checks, such as short URL
+	// resolution, run at once within a request
+	// (defaults to DefaultCheckConcurrency)
+	CheckConcurrency int
+
+	// MaxChecksPerRequest caps how many items are checked per request
+	// (defaults to DefaultMaxChecksPerRequest)
+	MaxChecksPerRequest int
+
+	// WarmConcurrency caps how many parameter sets Warm fetches at once
+	// (defaults to DefaultWarmConcurrency)
+	WarmConcurrency int
+
+	// WarmInterval is the minimum time between the fetches Warm starts
+	// (0 = DefaultWarmInterval, negative = no pacing)
+	WarmInterval time.Duration
+
+	// DisableHTTP2 forces HTTP/1.1, for proxies that break on HTTP/2
+	DisableHTTP2 bool
+
+	// LoginPathPattern is a regular expression matching the path of login
+	// pages; a redirect to one fails with "feed requires authentication"
+	// (defaults to DefaultLoginPathPattern)
+	LoginPathPattern string
+
+	// UserAgent is the User-Agent sent with every request
+	// (defaults to DefaultUserAgent)
+	UserAgent string
+
+	// Accept is the Accept header sent with feed requests
+	// (defaults to DefaultAccept)
+	Accept string
+
+	// TagAliases maps tag variants to a canonical tag, e.g. "Golang" and
+	// "go-lang" to "golang". Variants are matched case-insensitively and
+	// rewritten in every fetched feed, so they aggregate as one tag.
+	TagAliases map[string]string
+
+	// MaxPages caps the number of pages FetchAll retrieves
+	// (defaults to DefaultMaxPages)
+	MaxPages int
+
+	// CacheTTL is how long GetBookmarks caches fetched feeds
+	// (0 = DefaultCacheTTL, negative = no caching)
+	CacheTTL time.Duration
+
+	// CacheNegativeTTL is how long GetBookmarks caches empty results and
+	// missing feeds (0 = DefaultCacheNegativeTTL, negative = not cached)
+	CacheNegativeTTL time.Duration
+
+	// CacheRefreshAhead, if positive, refreshes hot cache entries in the
+	// background when they are read within this long of expiring, so that
+	// frequent callers are not kept waiting when they expire (0 = disabled)
+	CacheRefreshAhead time.Duration
+
+	// Cache optionally shares a cache between services, e.g. one per
+	// downstream client. Entries are namespaced by the configuration, so
+	// services with different base URLs or credentials never share results.
+	// CacheTTL and CacheNegativeTTL are then set by the cache, and its owner
+	// runs its cleanup. By default each service has a private cache.
+	Cache *utils.Cache
+
+	// MaxTagsPerItem caps the number of tags kept per bookmark
+	// (0 = unlimited)
+	MaxTagsPerItem int
+
+	// MaxTitleLength caps bookmark titles in runes (0 = unlimited)
+	MaxTitleLength int
+
+	// DatePreference selects whether dc:date or pubDate wins when an item
+	// carries both: parser.DatePreferenceDCFirst (default) or
+	// parser.DatePreferencePubDateFirst
+	DatePreference string
+
+	// MinCommentLength drops comments shorter than this many runes
+	// (0 = keep all)
+	MinCommentLength int
+
+	// MissingLinkPolicy selects what happens to feed items without a link:
+	// parser.MissingLinkSkip (default), parser.MissingLinkKeep or
+	// parser.MissingLinkError
+	MissingLinkPolicy string
+
+	// DeriveUntitled gives untitled bookmarks a title derived from their URL
+	DeriveUntitled bool
+
+	// StreamThreshold is the feed size in bytes above which feeds are
+	// parsed one item at a time to lower peak memory
+	// (0 = DefaultStreamThreshold, negative = never stream)
+	StreamThreshold int
+}
+
+// cacheNamespace identifies the configuration that shapes fetch results:
+// where feeds come from, whose credentials fetch them and how they are parsed
+func (o Options) cacheNamespace() string {
+	return utils.CacheNamespace(
+		o.BaseURL,
+		o.AuthMode,
+		o.AuthUsername,
+		o.AuthToken,
+		fmt.Sprintf("%+v", o.parserOptions()),
+		fmt.Sprintf("%v", o.TagAliases),
+	)
+}
+
+// parserOptions returns the options of the feed parser
+func (o Options) parserOptions() parser.Options {
+	return parser.Options{
+		MaxTagsPerItem:    o.MaxTagsPerItem,
+		MaxTitleLength:    o.MaxTitleLength,
+		DatePreference:    o.DatePreference,
+		MinCommentLength:  o.MinCommentLength,
+		MissingLinkPolicy: o.MissingLinkPolicy,
+		DeriveUntitled:    o.DeriveUntitled,
+	}
+}
+
+// withDefaults returns a copy of the options with unset fields defaulted
+func (o Options) withDefaults() Options {
+	if o.BaseURL == "" {
+		o.BaseURL = DefaultBaseURL
+	}
+	o.BaseURL = strings.TrimRight(o.BaseURL, "/")
+
+	if o.StarBaseURL == "" {
+		o.StarBaseURL = DefaultStarBaseURL
+	}
+	o.StarBaseURL = strings.TrimRight(o.StarBaseURL, "/")
+
+	if len(o.AllowedHosts) == 0 {
+		o.AllowedHosts = DefaultAllowedHosts
+	}
+
+	if o.Clock == nil {
+		o.Clock = time.Now
+	}
+
+	if o.MaxConcurrentRequests <= 0 {
+		o.MaxConcurrentRequests = DefaultMaxConcurrentRequests
+	}
+
+	if o.MaxPages <= 0 {
+		o.MaxPages = DefaultMaxPages
+	}
+
+	if o.CacheTTL == 0 {
+		o.CacheTTL = DefaultCacheTTL
+	}
+
+	if o.CacheNegativeTTL == 0 {
+		o.CacheNegativeTTL = DefaultCacheNegativeTTL
+	}
+
+	if o.StreamThreshold == 0 {
+		o.StreamThreshold = DefaultStreamThreshold
+	}
+
+	if o.MaxItems <= 0 {
+		o.MaxItems = DefaultMaxItems
+	}
+
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = DefaultDialTimeout
+	}
+
+	if o.TLSHandshakeTimeout <= 0 {
+		o.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+
+	if o.ResponseHeaderTimeout <= 0 {
+		o.ResponseHeaderTimeout = DefaultResponseHeaderTimeout
+	}
+
+	if o.RequestTimeout <= 0 {
+		o.RequestTimeout = DefaultRequestTimeout
+	}
+
+	if o.TLSMinVersion == 0 {
+		o.TLSMinVersion = tls.VersionTLS12
+	}
+
+	if o.AuthMode == "" {
+		o.AuthMode = AuthModeNone
+	}
+
+	if o.NonceSource == nil {
+		o.NonceSource = rand.Reader
+	}
+
+	if len(o.ShortenerHosts) == 0 {
+		o.ShortenerHosts = DefaultShortenerHosts
+	}
+
+	if o.LoginPathPattern == "" {
+		o.LoginPathPattern = DefaultLoginPathPattern
+	}
+
+	if o.UserAgent == "" {
+		o.UserAgent = DefaultUserAgent
+	}
+
+	if o.Accept == "" {
+		o.Accept = DefaultAccept
+	}
+
+	if o.ReadLaterTag == "" {
+		o.ReadLaterTag = DefaultReadLaterTag
+	}
+
+	if o.CheckConcurrency <= 0 {
+		o.CheckConcurrency = DefaultCheckConcurrency
+	}
+
+	if o.MaxChecksPerRequest <= 0 {
+		o.MaxChecksPerRequest = DefaultMaxChecksPerRequest
+	}
+
+	if o.WarmConcurrency <= 0 {
+		o.WarmConcurrency = DefaultWarmConcurrency
+	}
+
+	if o.WarmInterval == 0 {
+		o.WarmInterval = DefaultWarmInterval
+	}
+
+	if o.ProgressLogInterval <= 0 {
+		o.ProgressLogInterval = 1
+	}
+
+	if len(o.TagAliases) > 0 {
+		aliases := make(map[string]string, len(o.TagAliases))
+		for variant, canonical := range o.TagAliases {
+			aliases[strings.ToLower(strings.TrimSpace(variant))] = strings.TrimSpace(canonical)
+		}
+		o.TagAliases = aliases
+	}
+
+	return o
+}
+
+// Validate checks the options for invalid combinations
+func (o Options) Validate() error {
+	switch o.AuthMode {
+	case "", AuthModeNone:
+	case AuthModeWSSE:
+		if o.AuthUsername == "" || o.AuthToken == "" {
+			return fmt.Errorf("auth mode %q requires a username and API key", o.AuthMode)
+		}
+	case AuthModeBearer:
+		if o.AuthToken == "" {
+			return fmt.Errorf("auth mode %q requires a token", o.AuthMode)
+		}
+	default:
+		return fmt.Errorf("unsupported auth mode %q (supported: %s, %s, %s)", o.AuthMode, AuthModeNone, AuthModeWSSE, AuthModeBearer)
+	}
+
+	if o.CacheRefreshAhead < 0 {
+		return fmt.Errorf("cache refresh ahead must not be negative, got %s", o.CacheRefreshAhead)
+	}
+
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"max tags per item", o.MaxTagsPerItem},
+		{"max title length", o.MaxTitleLength},
+		{"min comment length", o.MinCommentLength},
+	}
+	for _, limit := range limits {
+		if limit.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", limit.name, limit.value)
+		}
+	}
+
+	switch o.DatePreference {
+	case "", parser.DatePreferenceDCFirst, parser.DatePreferencePubDateFirst:
+	default:
+		return fmt.Errorf("unsupported date preference %q (supported: %s, %s)", o.DatePreference, parser.DatePreferenceDCFirst, parser.DatePreferencePubDateFirst)
+	}
+
+	switch o.MissingLinkPolicy {
+	case "", parser.MissingLinkSkip, parser.MissingLinkKeep, parser.MissingLinkError:
+	default:
+		return fmt.Errorf("unsupported missing link policy %q (supported: %s, %s, %s)", o.MissingLinkPolicy, parser.MissingLinkSkip, parser.MissingLinkKeep, parser.MissingLinkError)
+	}
+
+	if o.LoginPathPattern != "" {
+		if _, err := regexp.Compile(o.LoginPathPattern); err != nil {
+			return fmt.Errorf("invalid login path pattern %q: %w", o.LoginPathPattern, err)
+		}
+	}
+
+	for variant, canonical := range o.TagAliases {
+		if strings.TrimSpace(variant) == "" || strings.TrimSpace(canonical) == "" {
+			return fmt.Errorf("tag alias %q=%q must name both a variant and a canonical tag", variant, canonical)
+		}
+	}
+
+	return nil
+}
+
+// canonicalTag returns the canonical tag tag is an alias of, or tag itself
+func (o Options) canonicalTag(tag string) string {
+	if canonical, ok := o.TagAliases[strings.ToLower(tag)]; ok {
+		return canonical
+	}
+	return tag
+}
+
+// isAllowedHost reports whether requests may be sent to host
+func (o Options) isAllowedHost(host string) bool {
+	if o.AllowAnyHost {
+		return true
+	}
+
+	for _, allowed := range o.AllowedHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
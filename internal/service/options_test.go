@@ -0,0 +1,74 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/parser"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		wantErr string
+	}{
+		{"zero options", Options{}, ""},
+		{"tag cap", Options{MaxTagsPerItem: 5}, ""},
+		{"negative tag cap", Options{MaxTagsPerItem: -1}, "max tags per item must not be negative"},
+		{"WSSE", Options{AuthMode: AuthModeWSSE, AuthUsername: "alice", AuthToken: "key"}, ""},
+		{"WSSE without key", Options{AuthMode: AuthModeWSSE, AuthUsername: "alice"}, `auth mode "wsse" requires a username and API key`},
+		{"bearer without token", Options{AuthMode: AuthModeBearer}, `auth mode "bearer" requires a token`},
+		{"unknown auth mode", Options{AuthMode: "basic"}, `unsupported auth mode "basic"`},
+		{"pubDate first", Options{DatePreference: parser.DatePreferencePubDateFirst}, ""},
+		{"unknown date preference", Options{DatePreference: "newest"}, `unsupported date preference "newest"`},
+		{"keep items without links", Options{MissingLinkPolicy: parser.MissingLinkKeep}, ""},
+		{"unknown missing link policy", Options{MissingLinkPolicy: "drop"}, `unsupported missing link policy "drop"`},
+		{"tag aliases", Options{TagAliases: map[string]string{"golang": "go"}}, ""},
+		{"tag alias without canonical tag", Options{TagAliases: map[string]string{"golang": " "}}, `tag alias "golang"=" " must name both`},
+		{"tag alias without variant", Options{TagAliases: map[string]string{"": "go"}}, `must name both a variant and a canonical tag`},
+		{"login path pattern", Options{LoginPathPattern: `^/accounts/signin$`}, ""},
+		{"invalid login path pattern", Options{LoginPathPattern: `(login`}, `invalid login path pattern "(login"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsAllowedHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		host    string
+		want    bool
+	}{
+		{"default host", Options{}.withDefaults(), "b.hatena.ne.jp", true},
+		{"case-insensitive", Options{}.withDefaults(), "B.Hatena.NE.JP", true},
+		{"star API", Options{}.withDefaults(), "s.hatena.ne.jp", true},
+		{"lookalike subdomain", Options{}.withDefaults(), "b.hatena.ne.jp.evil.example", false},
+		{"metadata address", Options{}.withDefaults(), "169.254.169.254", false},
+		{"custom allowlist", Options{AllowedHosts: []string{"mirror.example"}}.withDefaults(), "mirror.example", true},
+		{"custom allowlist replaces defaults", Options{AllowedHosts: []string{"mirror.example"}}.withDefaults(), "b.hatena.ne.jp", false},
+		{"any host", Options{AllowAnyHost: true}.withDefaults(), "169.254.169.254", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.isAllowedHost(tt.host); got != tt.want {
+				t.Errorf("isAllowedHost(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// redactedValue replaces sensitive values in logs
+const redactedValue = "[REDACTED]"
+
+// sensitiveHeaders lists headers whose values must never be logged
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-WSSE",
+}
+
+// redactHeaders returns a copy of the headers with credentials redacted
+func redactHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if len(values) > 0 {
+			redacted[name] = values[0]
+		}
+	}
+
+	for _, name := range sensitiveHeaders {
+		canonical := http.CanonicalHeaderKey(name)
+		if _, ok := redacted[canonical]; ok {
+			redacted[canonical] = redactedValue
+		}
+	}
+
+	return redacted
+}
+
+// redactURL returns the URL as a string with any user info redacted
+func redactURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+
+	clone := *u
+	clone.User = url.User(redactedValue)
+	return clone.String()
+}
+
+// truncateBody returns at most limit bytes of body as a string
+func truncateBody(body []byte, limit int) string {
+	if len(body) <= limit {
+		return string(body)
+	}
+	return string(body[:limit]) + "...(truncated)"
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// DefaultShortenerHosts lists the URL shortener hosts resolved by default
+var DefaultShortenerHosts = []string{
+	"bit.ly",
+	"buff.ly",
+	"goo.gl",
+	"is.gd",
+	"ow.ly",
+	"t.co",
+	"tinyurl.com",
+}
+
+// newResolveClient builds the client used to resolve shortened URLs.
+// Redirects are followed only between shortener hosts, so the final URL is
+// captured from the Location header without requesting the target site.
+// Shorteners are third-party hosts, so the certificate pins for Hatena do
+// not apply; only the minimum TLS version is enforced.
+func newResolveClient(options Options) *http.Client {
+	tlsConfig := &tls.Config{MinVersion: options.TLSMinVersion}
+	return &http.Client{
+		Timeout:   options.RequestTimeout,
+		Transport: newTransport(options, tlsConfig),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if !options.isShortenerHost(req.URL.Hostname()) {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
+
+// resolveShortURLs annotates bookmarks on shortener hosts with the URL they
+// redirect to. Failures are logged and leave ResolvedURL empty. At most
+// CheckConcurrency URLs are resolved at once and MaxChecksPerRequest in
+// total; it reports whether the latter cap left URLs unresolved.
+func (s *BookmarkService) resolveShortURLs(ctx context.Context, items []types.BookmarkItem) bool {
+	var wg sync.WaitGroup
+	checks := make(chan struct{}, s.options.CheckConcurrency)
+	checked := 0
+	limited := false
+
+	for i := range items {
+		parsed, err := url.Parse(items[i].URL)
+		if err != nil || !s.options.isShortenerHost(parsed.Hostname()) {
+			continue
+		}
+
+		if checked == s.options.MaxChecksPerRequest {
+			limited = true
+			break
+		}
+		checked++
+
+		checks <- struct{}{}
+		wg.Add(1)
+		go func(item *types.BookmarkItem) {
+			defer wg.Done()
+			defer func() { <-checks }()
+
+			resolved, err := s.resolveShortURL(ctx, item.URL)
+			if err != nil {
+				s.log(ctx).Warn("Failed to resolve short URL", "url", item.URL, "error", err)
+				return
+			}
+			item.ResolvedURL = resolved
+		}(&items[i])
+	}
+
+	wg.Wait()
+
+	if limited {
+		s.log(ctx).Warn("Limited short URL resolution",
+			"max_checks", s.options.MaxChecksPerRequest)
+	}
+
+	return limited
+}
+
+// resolveShortURL issues a HEAD request for a shortened URL and returns the
+// URL it finally redirects to
+func (s *BookmarkService) resolveShortURL(ctx context.Context, shortURL string) (string, error) {
+	// Requests go to third-party hosts, so no authentication headers are set
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, shortURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", s.options.UserAgent)
+
+	release, err := s.acquireRequestSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	resp, err := s.resolveClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		location, err := resp.Location()
+		if err != nil {
+			return "", err
+		}
+		return location.String(), nil
+	}
+
+	if resp.StatusCode >= 400 {
+		return "", errors.New(resp.Status)
+	}
+
+	// The chain ended on a shortener host without a further redirect
+	return resp.Request.URL.String(), nil
+}
+
+// isShortenerHost reports whether host is a configured URL shortener
+func (o Options) isShortenerHost(host string) bool {
+	for _, shortener := range o.ShortenerHosts {
+		if strings.EqualFold(host, shortener) {
+			return true
+		}
+	}
+	return false
+}
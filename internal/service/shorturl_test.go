@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// newShortener starts a URL shortener redirecting /abc to an outside URL,
+// /chain to /abc, and answering 404 for anything else. Requests reaching
+// the outside URL would fail the test, since the final hop is not followed.
+func newShortener(t *testing.T, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/abc", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Redirect(w, r, "https://example.com/final?from=abc", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/chain", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Redirect(w, r, "/abc", http.StatusFound)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.NotFound(w, r)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolveShortURLs(t *testing.T) {
+	var hits atomic.Int32
+	shortener := newShortener(t, &hits)
+	feed := rdfFeed(
+		testItem{Title: "Short", Link: shortener.URL + "/abc"},
+		testItem{Title: "Chained", Link: shortener.URL + "/chain"},
+		testItem{Title: "Gone", Link: shortener.URL + "/gone"},
+		testItem{Title: "Plain", Link: "https://example.com/plain"},
+	)
+	s := newTestService(t, serveFeed(feed, nil), Options{ShortenerHosts: []string{"127.0.0.1"}})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", ResolveShortURLs: true})
+
+	var resolved []string
+	for _, bookmark := range response.Bookmarks {
+		resolved = append(resolved, bookmark.ResolvedURL)
+	}
+	want := []string{"https://example.com/final?from=abc", "https://example.com/final?from=abc", "", ""}
+	if !reflect.DeepEqual(resolved, want) {
+		t.Errorf("ResolvedURL = %q, want %q", resolved, want)
+	}
+	if response.ChecksLimited {
+		t.Error("ChecksLimited = true, want false")
+	}
+	// abc, chain and its hop to abc, and gone
+	if got := hits.Load(); got != 4 {
+		t.Errorf("shortener hits = %d, want 4", got)
+	}
+}
+
+func TestResolveShortURLsLimit(t *testing.T) {
+	var hits atomic.Int32
+	shortener := newShortener(t, &hits)
+	feed := rdfFeed(
+		testItem{Title: "A", Link: shortener.URL + "/abc"},
+		testItem{Title: "B", Link: shortener.URL + "/abc?b"},
+		testItem{Title: "C", Link: shortener.URL + "/abc?c"},
+	)
+	s := newTestService(t, serveFeed(feed, nil), Options{ShortenerHosts: []string{"127.0.0.1"}, MaxChecksPerRequest: 2})
+
+	response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", ResolveShortURLs: true})
+
+	if !response.ChecksLimited {
+		t.Error("ChecksLimited = false, want true")
+	}
+	if response.Bookmarks[2].ResolvedURL != "" || hits.Load() != 2 {
+		t.Errorf("third URL resolved to %q after %d requests, want it left alone", response.Bookmarks[2].ResolvedURL, hits.Load())
+	}
+}
+
+func TestResolveClientIgnoresPins(t *testing.T) {
+	client := newResolveClient(Options{PinnedCertSHA256: []string{"ab"}, TLSMinVersion: tls.VersionTLS13}.withDefaults())
+
+	config := client.Transport.(*http.Transport).TLSClientConfig
+	if config.VerifyConnection != nil {
+		t.Error("shortener requests are pinned to Hatena's certificate")
+	}
+	if config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("MinVersion = %x, want the configured minimum", config.MinVersion)
+	}
+}
+
+func TestResolveShortURLsConcurrency(t *testing.T) {
+	var inFlight, peak, hits atomic.Int32
+	release := make(chan struct{})
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		http.Redirect(w, r, "https://example.com/final", http.StatusMovedPermanently)
+	}))
+	defer shortener.Close()
+
+	s := newTestService(t, serveFeed(rdfFeed(), nil), Options{
+		ShortenerHosts:        []string{"127.0.0.1"},
+		MaxConcurrentRequests: 20,
+		CheckConcurrency:      3,
+		MaxChecksPerRequest:   10,
+	})
+	items := make([]types.BookmarkItem, 12)
+	for i := range items {
+		items[i].URL = fmt.Sprintf("%s/%d", shortener.URL, i)
+	}
+
+	limited := make(chan bool)
+	go func() { limited <- s.resolveShortURLs(context.Background(), items) }()
+
+	// Let the first checks arrive, then let them all through
+	deadline := time.Now().Add(5 * time.Second)
+	for inFlight.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if !<-limited {
+		t.Error("resolveShortURLs() = false, want the check limit reported")
+	}
+	if got := peak.Load(); got != 3 {
+		t.Errorf("peak concurrent checks = %d, want 3", got)
+	}
+	if got := hits.Load(); got != 10 {
+		t.Errorf("checks made = %d, want 10", got)
+	}
+	if items[9].ResolvedURL != "https://example.com/final" || items[10].ResolvedURL != "" {
+		t.Errorf("ResolvedURL = %q, %q, want only the first 10 resolved", items[9].ResolvedURL, items[10].ResolvedURL)
+	}
+}
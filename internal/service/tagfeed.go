@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// Sort orders of the site-wide tag feed
+const (
+	TagFeedSortHot    = "hot"
+	TagFeedSortRecent = "recent"
+)
+
+// GetTagFeed returns the bookmarks tagged with tag across all users, from
+// Hatena's site-wide tag feed, either the most popular (hot) or the most
+// recent ones. Sort defaults to recent.
+func (s *BookmarkService) GetTagFeed(ctx context.Context, tag, sort string, page int) (*types.TagFeedResponse, error) {
+	tag = strings.TrimSpace(tag)
+	if sort == "" {
+		sort = TagFeedSortRecent
+	}
+
+	requestURL, err := s.buildTagFeedURL(tag, sort, page)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedData, err := s.fetchAndParse(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TagFeedResponse{
+		Tag:        tag,
+		Sort:       sort,
+		Page:       s.getPageOrDefault(page),
+		TotalCount: len(parsedData.Items),
+		Bookmarks:  parsedData.Items,
+	}, nil
+}
+
+// buildTagFeedURL constructs the site-wide tag feed URL:
+// https://b.hatena.ne.jp/t/{tag}?mode=rss&sort={sort}
+func (s *BookmarkService) buildTagFeedURL(tag, sort string, page int) (string, error) {
+	if tag == "" {
+		return "", &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "Tag is required",
+			Details: map[string]interface{}{"tag": tag},
+		}
+	}
+	if err := s.validator.ValidateTag(tag); err != nil {
+		return "", err
+	}
+	if err := s.validator.ValidatePage(page); err != nil {
+		return "", err
+	}
+	if sort != TagFeedSortHot && sort != TagFeedSortRecent {
+		return "", &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: fmt.Sprintf("Unsupported sort value %q (supported: %q, %q)", sort, TagFeedSortHot, TagFeedSortRecent),
+			Details: map[string]interface{}{"sort": sort},
+		}
+	}
+
+	tagSegment, err := sanitizePathSegment("tag", tag)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("mode", "rss")
+	query.Set("sort", sort)
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+
+	return fmt.Sprintf("%s/t/%s?%s", s.baseURL, tagSegment, query.Encode()), nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestBuildTagFeedURL(t *testing.T) {
+	s := NewBookmarkService(testLogger())
+	defer s.Close()
+
+	tests := []struct {
+		name string
+		tag  string
+		sort string
+		page int
+		want string
+	}{
+		{"recent", "go", TagFeedSortRecent, 0, "https://b.hatena.ne.jp/t/go?mode=rss&sort=recent"},
+		{"hot second page", "go", TagFeedSortHot, 2, "https://b.hatena.ne.jp/t/go?mode=rss&page=2&sort=hot"},
+		{"escaped tag", "c++/cli", TagFeedSortRecent, 1, "https://b.hatena.ne.jp/t/c++%2Fcli?mode=rss&sort=recent"},
+		{"multibyte tag", "日本語 タグ", TagFeedSortRecent, 1, "https://b.hatena.ne.jp/t/%E6%97%A5%E6%9C%AC%E8%AA%9E%20%E3%82%BF%E3%82%B0?mode=rss&sort=recent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.buildTagFeedURL(tt.tag, tt.sort, tt.page)
+			if err != nil {
+				t.Fatalf("buildTagFeedURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("buildTagFeedURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTagFeedURLValidation(t *testing.T) {
+	s := NewBookmarkService(testLogger())
+	defer s.Close()
+
+	tests := []struct {
+		name string
+		tag  string
+		sort string
+		page int
+	}{
+		{"empty tag", "", TagFeedSortRecent, 1},
+		{"invalid character", "a<b", TagFeedSortRecent, 1},
+		{"control character", "a\nb", TagFeedSortRecent, 1},
+		{"unknown sort", "go", "popular", 1},
+		{"negative page", "go", TagFeedSortRecent, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.buildTagFeedURL(tt.tag, tt.sort, tt.page); !errors.Is(err, types.ErrValidation) {
+				t.Errorf("buildTagFeedURL() error = %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+// tagFeed is a site-wide tag feed with bookmarks by different users
+const tagFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns="http://purl.org/rss/1.0/"
+ xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:hatena="http://www.hatena.ne.jp/info/xmlns#">
+<channel rdf:about="https://b.hatena.ne.jp/t/go"><title>go tag</title><link>https://b.hatena.ne.jp/t/go</link></channel>
+<item rdf:about="https://go.dev/"><title>Go</title><link>https://go.dev/</link><dc:creator>alice</dc:creator><dc:date>2024-02-10T09:00:00+09:00</dc:date><hatena:bookmarkcount>120</hatena:bookmarkcount></item>
+<item rdf:about="https://pkg.go.dev/"><title>Packages</title><link>https://pkg.go.dev/</link><dc:creator>bob</dc:creator><dc:date>2024-02-10T08:00:00+09:00</dc:date><hatena:bookmarkcount>45</hatena:bookmarkcount></item>
+</rdf:RDF>`
+
+func TestGetTagFeed(t *testing.T) {
+	var requested string
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.RequestURI()
+		io.WriteString(w, tagFeed)
+	}), Options{})
+
+	response, err := s.GetTagFeed(context.Background(), " go ", "", 0)
+	if err != nil {
+		t.Fatalf("GetTagFeed() error = %v", err)
+	}
+
+	if requested != "/t/go?mode=rss&sort=recent" {
+		t.Errorf("requested %q", requested)
+	}
+	if response.Tag != "go" || response.Sort != TagFeedSortRecent || response.Page != 1 || response.TotalCount != 2 {
+		t.Errorf("response = %+v", response)
+	}
+
+	want := []struct {
+		creator string
+		count   int
+	}{{"alice", 120}, {"bob", 45}}
+	for i, bookmark := range response.Bookmarks {
+		if bookmark.Creator != want[i].creator || bookmark.BookmarkCount != want[i].count {
+			t.Errorf("bookmark %d: creator %q, count %d, want %q and %d", i, bookmark.Creator, bookmark.BookmarkCount, want[i].creator, want[i].count)
+		}
+	}
+}
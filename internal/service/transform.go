@@ -0,0 +1,360 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// Supported sort orders
+const (
+	// SortByScore sorts bookmarks by importance score, highest first
+	SortByScore = "score"
+	// SortByDate sorts bookmarks by bookmark date, newest first
+	SortByDate = "date"
+)
+
+// scoreHalfLife is the age at which the recency factor of a score halves
+const scoreHalfLife = 30 * 24 * time.Hour
+
+// scoreBookmarks annotates each bookmark with an importance score:
+//
+//	score = (1 + ln(1 + bookmark_count)) * 0.5^(age / 30 days)
+//
+// The count term is log-scaled so popular entries don't dominate, and the
+// recency term halves every 30 days. Future timestamps count as age zero.
+func scoreBookmarks(items []types.BookmarkItem, now time.Time) {
+	for i := range items {
+		age := time.Duration(0)
+		if t, err := time.Parse(time.RFC3339, items[i].BookmarkedAt); err == nil && now.After(t) {
+			age = now.Sub(t)
+		}
+
+		popularity := 1 + math.Log1p(float64(items[i].BookmarkCount))
+		recency := math.Pow(0.5, float64(age)/float64(scoreHalfLife))
+		items[i].Score = popularity * recency
+	}
+}
+
+// sortByScore sorts bookmarks by score in descending order
+func sortByScore(items []types.BookmarkItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score {
+			return items[i].Score > items[j].Score
+		}
+		return tiebreakLess(items[i], items[j])
+	})
+}
+
+// sortByDate sorts bookmarks by BookmarkedAt in descending order.
+// Timestamps only have second resolution, so bulk imports often share one;
+// ties are broken by URL and title to keep the output deterministic.
+func sortByDate(items []types.BookmarkItem) {
+	sortByDateOrder(items, true)
+}
+
+// sortByDateOldestFirst sorts bookmarks by BookmarkedAt in ascending order,
+// with the same tiebreak as sortByDate
+func sortByDateOldestFirst(items []types.BookmarkItem) {
+	sortByDateOrder(items, false)
+}
+
+// sortByDateOrder sorts bookmarks by BookmarkedAt, newest or oldest first.
+// Unparseable timestamps always sort last.
+func sortByDateOrder(items []types.BookmarkItem, newestFirst bool) {
+	times := make(map[string]time.Time, len(items))
+	for _, item := range items {
+		if t, err := time.Parse(time.RFC3339, item.BookmarkedAt); err == nil {
+			times[item.BookmarkedAt] = t
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].BookmarkedAt, items[j].BookmarkedAt
+		ta, okA := times[a]
+		tb, okB := times[b]
+		switch {
+		case okA && okB && !ta.Equal(tb):
+			return ta.After(tb) == newestFirst
+		case okA != okB:
+			// Unparseable timestamps sort last
+			return okA
+		case !okA && a != b:
+			return (a > b) == newestFirst
+		}
+		return tiebreakLess(items[i], items[j])
+	})
+}
+
+// olderThan returns the bookmarks made before cutoff. Bookmarks with
+// unparseable timestamps are dropped.
+func olderThan(items []types.BookmarkItem, cutoff time.Time) []types.BookmarkItem {
+	result := make([]types.BookmarkItem, 0, len(items))
+	for _, item := range items {
+		if t, err := time.Parse(time.RFC3339, item.BookmarkedAt); err == nil && t.Before(cutoff) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// tiebreakLess orders bookmarks that compare equal on the sort key by URL,
+// then title
+func tiebreakLess(a, b types.BookmarkItem) bool {
+	if a.URL != b.URL {
+		return a.URL < b.URL
+	}
+	return a.Title < b.Title
+}
+
+// groupByDate buckets bookmarks by the local date of BookmarkedAt.
+// Dates are sorted in descending order and items within a date keep feed order.
+func groupByDate(items []types.BookmarkItem) []types.DateGroup {
+	groups := make([]types.DateGroup, 0)
+	index := make(map[string]int)
+
+	for _, item := range items {
+		date := bookmarkDate(item.BookmarkedAt)
+
+		i, ok := index[date]
+		if !ok {
+			i = len(groups)
+			index[date] = i
+			groups = append(groups, types.DateGroup{Date: date})
+		}
+		groups[i].Bookmarks = append(groups[i].Bookmarks, item)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Date > groups[j].Date
+	})
+
+	return groups
+}
+
+// DefaultTagSeparator separates the levels of a hierarchical tag
+const DefaultTagSeparator = "/"
+
+// buildTagTree nests bookmarks by their tags split on separator, so that a
+// bookmark tagged "tech/go" lands in the "go" child of the "tech" node. Flat
+// tags become top-level nodes, and a bookmark with several tags appears under
+// each of their paths. Empty segments are ignored. Bookmarks without any
+// usable tag are returned separately, in feed order.
+func buildTagTree(items []types.BookmarkItem, separator string) (map[string]*types.TagNode, []types.BookmarkItem) {
+	tree := make(map[string]*types.TagNode)
+	untagged := make([]types.BookmarkItem, 0)
+
+	for _, item := range items {
+		placed := make(map[*types.TagNode]bool)
+		for _, tag := range item.Tags {
+			children := tree
+			var node *types.TagNode
+			for _, segment := range strings.Split(tag, separator) {
+				if segment = strings.TrimSpace(segment); segment == "" {
+					continue
+				}
+				if children[segment] == nil {
+					children[segment] = &types.TagNode{Children: make(map[string]*types.TagNode)}
+				}
+				node = children[segment]
+				children = node.Children
+			}
+
+			// Skip tags made only of separators and repeated paths
+			if node == nil || placed[node] {
+				continue
+			}
+			placed[node] = true
+			node.Bookmarks = append(node.Bookmarks, item)
+		}
+
+		if len(placed) == 0 {
+			untagged = append(untagged, item)
+		}
+	}
+
+	return tree, untagged
+}
+
+// bookmarkDate returns the YYYY-MM-DD date of an ISO 8601 timestamp,
+// keeping the timestamp's own offset
+func bookmarkDate(bookmarkedAt string) string {
+	t, err := time.Parse(time.RFC3339, bookmarkedAt)
+	if err != nil {
+		if len(bookmarkedAt) >= 10 {
+			return bookmarkedAt[:10]
+		}
+		return bookmarkedAt
+	}
+	return t.Format("2006-01-02")
+}
+
+// dedupBookmarks removes bookmarks already seen, keeping first-seen order.
+// Bookmarks are identified by GUID when present, otherwise by URL.
+func dedupBookmarks(items []types.BookmarkItem) []types.BookmarkItem {
+	seen := make(map[string]bool, len(items))
+	result := make([]types.BookmarkItem, 0, len(items))
+
+	for _, item := range items {
+		key := dedupKey(item)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, item)
+	}
+
+	return result
+}
+
+// mergeBookmarks merges bookmarks with the same URL, keeping the first
+// occurrence and first-seen order. The same bookmark may carry slightly
+// different tag sets in different feeds, so tags are unioned.
+func mergeBookmarks(items []types.BookmarkItem) []types.BookmarkItem {
+	index := make(map[string]int, len(items))
+	result := make([]types.BookmarkItem, 0, len(items))
+
+	for _, item := range items {
+		if i, ok := index[item.URL]; ok {
+			result[i].Tags = unionTags(result[i].Tags, item.Tags)
+			continue
+		}
+		index[item.URL] = len(result)
+		result = append(result, item)
+	}
+
+	return result
+}
+
+// filterByPresence returns the bookmarks whose comment and tags are present
+// or absent as requested; a nil criterion matches every bookmark
+func filterByPresence(items []types.BookmarkItem, hasComment, hasTags *bool) []types.BookmarkItem {
+	result := make([]types.BookmarkItem, 0, len(items))
+	for _, item := range items {
+		if hasComment != nil && (item.Comment != "") != *hasComment {
+			continue
+		}
+		if hasTags != nil && (len(item.Tags) > 0) != *hasTags {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// unionTags returns the tags of a followed by those of b not already present.
+// Tags are compared case-insensitively; the first-seen spelling wins.
+func unionTags(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	result := make([]string, 0, len(a)+len(b))
+
+	for _, tag := range append(append([]string(nil), a...), b...) {
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+
+	return result
+}
+
+// collapseDuplicateTitles merges consecutive bookmarks with the same URL and
+// normalized title, as left behind by re-bookmarking an article. Merged
+// bookmarks keep the union of their tags and the earliest BookmarkedAt.
+// Matching titles on different URLs are kept apart.
+func collapseDuplicateTitles(items []types.BookmarkItem) []types.BookmarkItem {
+	result := make([]types.BookmarkItem, 0, len(items))
+
+	for _, item := range items {
+		if n := len(result); n > 0 {
+			last := &result[n-1]
+			if last.URL == item.URL && normalizeTitle(last.Title) == normalizeTitle(item.Title) {
+				last.Tags = unionTags(last.Tags, item.Tags)
+				if earlier(item.BookmarkedAt, last.BookmarkedAt) {
+					last.BookmarkedAt = item.BookmarkedAt
+					last.DateFallback = item.DateFallback
+				}
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+
+	return result
+}
+
+// normalizeTitle lowercases a title and collapses its whitespace
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// earlier reports whether timestamp a is before b. Unparseable timestamps
+// never compare as earlier.
+func earlier(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	return errA == nil && errB == nil && ta.Before(tb)
+}
+
+// applyTagAliases rewrites each bookmark's tags to their canonical form,
+// dropping tags that become duplicates of an earlier one
+func (s *BookmarkService) applyTagAliases(items []types.BookmarkItem) {
+	if len(s.options.TagAliases) == 0 {
+		return
+	}
+
+	for i := range items {
+		tags := make([]string, len(items[i].Tags))
+		for j, tag := range items[i].Tags {
+			tags[j] = s.options.canonicalTag(tag)
+		}
+		items[i].Tags = unionTags(nil, tags)
+	}
+}
+
+// lowercaseTags lowercases each bookmark's tags, merging tags that differ
+// only in case into one. Tags without case, such as Japanese, are unchanged.
+func lowercaseTags(items []types.BookmarkItem) {
+	for i := range items {
+		tags := make([]string, len(items[i].Tags))
+		for j, tag := range items[i].Tags {
+			tags[j] = strings.ToLower(tag)
+		}
+		items[i].Tags = unionTags(nil, tags)
+	}
+}
+
+// sortTags sorts each bookmark's tags case-insensitively in rune order,
+// falling back to case-sensitive order for tags that differ only in case
+func sortTags(items []types.BookmarkItem) {
+	for _, item := range items {
+		tags := item.Tags
+		sort.SliceStable(tags, func(i, j int) bool {
+			a, b := strings.ToLower(tags[i]), strings.ToLower(tags[j])
+			if a != b {
+				return a < b
+			}
+			return tags[i] < tags[j]
+		})
+	}
+}
+
+// annotatePage records the feed page each bookmark was fetched from
+func annotatePage(items []types.BookmarkItem, page int) {
+	for i := range items {
+		items[i].SourcePage = page
+	}
+}
+
+// dedupKey returns the identity of a bookmark for deduplication
+func dedupKey(item types.BookmarkItem) string {
+	if item.GUID != "" {
+		return "guid:" + item.GUID
+	}
+	return "url:" + item.URL
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestGroupByDate(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "https://example.com/1", BookmarkedAt: "2024-02-09T08:00:00+09:00"},
+		{URL: "https://example.com/2", BookmarkedAt: "2024-02-10T23:30:00+09:00"},
+		{URL: "https://example.com/3", BookmarkedAt: "2024-02-09T22:00:00+09:00"},
+		// The timestamp's own offset decides the date: 2024-02-09 in UTC
+		{URL: "https://example.com/4", BookmarkedAt: "2024-02-09T20:00:00Z"},
+		{URL: "https://example.com/5", BookmarkedAt: "2024-02-11 garbage"},
+	}
+
+	groups := groupByDate(items)
+
+	want := []struct {
+		date string
+		urls []string
+	}{
+		{"2024-02-11", []string{"https://example.com/5"}},
+		{"2024-02-10", []string{"https://example.com/2"}},
+		{"2024-02-09", []string{"https://example.com/1", "https://example.com/3", "https://example.com/4"}},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i, w := range want {
+		if groups[i].Date != w.date {
+			t.Errorf("groups[%d].Date = %q, want %q", i, groups[i].Date, w.date)
+		}
+		if got := bookmarkURLs(groups[i].Bookmarks); !reflect.DeepEqual(got, w.urls) {
+			t.Errorf("groups[%d] URLs = %q, want %q in feed order", i, got, w.urls)
+		}
+	}
+}
+
+func TestGroupByDateEmpty(t *testing.T) {
+	groups := groupByDate(nil)
+	if groups == nil || len(groups) != 0 {
+		t.Errorf("groupByDate(nil) = %#v, want an empty, non-nil slice", groups)
+	}
+}
+
+func TestScoreBookmarks(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		item  types.BookmarkItem
+		score float64
+	}{
+		{"fresh, no bookmarks", types.BookmarkItem{BookmarkedAt: "2024-03-01T12:00:00Z"}, 1},
+		{"fresh, popular", types.BookmarkItem{BookmarkedAt: "2024-03-01T12:00:00Z", BookmarkCount: 99}, 1 + math.Log(100)},
+		{"one half-life old", types.BookmarkItem{BookmarkedAt: "2024-01-31T12:00:00Z"}, 0.5},
+		{"two half-lives old, popular", types.BookmarkItem{BookmarkedAt: "2024-01-01T12:00:00Z", BookmarkCount: 99}, (1 + math.Log(100)) / 4},
+		{"future counts as fresh", types.BookmarkItem{BookmarkedAt: "2024-04-01T00:00:00Z"}, 1},
+		{"unparseable counts as fresh", types.BookmarkItem{BookmarkedAt: "yesterday"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := []types.BookmarkItem{tt.item}
+			scoreBookmarks(items, now)
+			if math.Abs(items[0].Score-tt.score) > 1e-9 {
+				t.Errorf("Score = %v, want %v", items[0].Score, tt.score)
+			}
+		})
+	}
+}
+
+func TestSortByScore(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "https://example.com/low", Score: 0.5},
+		{URL: "https://example.com/tie-b", Score: 2},
+		{URL: "https://example.com/high", Score: 3},
+		{URL: "https://example.com/tie-a", Score: 2},
+	}
+
+	sortByScore(items)
+
+	want := []string{"https://example.com/high", "https://example.com/tie-a", "https://example.com/tie-b", "https://example.com/low"}
+	if got := bookmarkURLs(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+}
+
+func TestDedupBookmarks(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "https://example.com/a", GUID: "bookmark-1", Title: "first"},
+		{URL: "https://example.com/a", GUID: "bookmark-2", Title: "re-bookmarked"},
+		{URL: "https://example.com/b", GUID: "bookmark-1", Title: "same guid"},
+		{URL: "https://example.com/c", Title: "no guid"},
+		{URL: "https://example.com/c", Title: "no guid again"},
+	}
+
+	got := dedupBookmarks(items)
+
+	var titles []string
+	for _, item := range got {
+		titles = append(titles, item.Title)
+	}
+	if want := []string{"first", "re-bookmarked", "no guid"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("titles = %q, want %q", titles, want)
+	}
+}
+
+func TestSortByDateTiebreak(t *testing.T) {
+	const same = "2024-02-10T09:00:00+09:00"
+	items := []types.BookmarkItem{
+		{URL: "https://example.com/b", Title: "B", BookmarkedAt: same},
+		{URL: "https://example.com/old", BookmarkedAt: "2024-02-09T09:00:00+09:00"},
+		{URL: "https://example.com/a", Title: "Second", BookmarkedAt: same},
+		// The same instant written in another offset
+		{URL: "https://example.com/c", BookmarkedAt: "2024-02-10T00:00:00Z"},
+		{URL: "https://example.com/a", Title: "First", BookmarkedAt: same},
+		{URL: "https://example.com/bad", BookmarkedAt: "not a date"},
+		{URL: "https://example.com/new", BookmarkedAt: "2024-02-11T09:00:00+09:00"},
+	}
+	want := []string{
+		"https://example.com/new|",
+		"https://example.com/a|First",
+		"https://example.com/a|Second",
+		"https://example.com/b|B",
+		"https://example.com/c|",
+		"https://example.com/old|",
+		"https://example.com/bad|",
+	}
+
+	// Every starting order must give the same result
+	for rotation := 0; rotation < len(items); rotation++ {
+		rotated := append(append([]types.BookmarkItem{}, items[rotation:]...), items[:rotation]...)
+		sortByDate(rotated)
+
+		got := make([]string, len(rotated))
+		for i, item := range rotated {
+			got[i] = item.URL + "|" + item.Title
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("rotation %d: order = %q, want %q", rotation, got, want)
+		}
+	}
+}
+
+func TestSortByDateOldestFirst(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "https://example.com/b", BookmarkedAt: "2024-02-10T09:00:00+09:00"},
+		{URL: "https://example.com/bad", BookmarkedAt: "not a date"},
+		{URL: "https://example.com/a", BookmarkedAt: "2024-02-10T09:00:00+09:00"},
+		{URL: "https://example.com/old", BookmarkedAt: "2024-02-09T09:00:00+09:00"},
+	}
+
+	sortByDateOldestFirst(items)
+
+	want := []string{"https://example.com/old", "https://example.com/a", "https://example.com/b", "https://example.com/bad"}
+	if got := bookmarkURLs(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+}
+
+func TestUnionTags(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []string
+	}{
+		{"overlapping", []string{"a", "b"}, []string{"b", "c"}, []string{"a", "b", "c"}},
+		{"first spelling wins", []string{"Go", "rss"}, []string{"go", "RSS", "xml"}, []string{"Go", "rss", "xml"}},
+		{"duplicates within one set", []string{"a", "A"}, nil, []string{"a"}},
+		{"empty", nil, nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unionTags(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unionTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeBookmarks(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "https://example.com/x", Title: "query feed", Tags: []string{"a", "b"}},
+		{URL: "https://example.com/y", Title: "Y", Tags: []string{"a"}},
+		{URL: "https://example.com/x", Title: "path feed", Tags: []string{"b", "c"}},
+	}
+	original := []string{"a", "b"}
+
+	got := mergeBookmarks(items)
+
+	if len(got) != 2 || got[0].Title != "query feed" || got[1].URL != "https://example.com/y" {
+		t.Fatalf("merged = %+v, want x then y, keeping the first x", got)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got[0].Tags, want) {
+		t.Errorf("Tags = %q, want %q", got[0].Tags, want)
+	}
+	if !reflect.DeepEqual(items[0].Tags, original) {
+		t.Errorf("input tags changed to %q", items[0].Tags)
+	}
+}
+
+func TestCollapseDuplicateTitles(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "https://example.com/a", Title: "Go 1.22", Tags: []string{"go"}, BookmarkedAt: "2024-02-10T09:00:00+09:00"},
+		// Re-bookmarked: same URL and title up to case and spacing
+		{URL: "https://example.com/a", Title: " go  1.22 ", Tags: []string{"Go", "release"}, BookmarkedAt: "2024-02-08T09:00:00+09:00"},
+		// Same title on another URL
+		{URL: "https://example.com/b", Title: "Go 1.22", Tags: []string{"news"}, BookmarkedAt: "2024-02-07T09:00:00+09:00"},
+		// Same URL and title again, but not adjacent
+		{URL: "https://example.com/a", Title: "Go 1.22", BookmarkedAt: "2024-02-01T09:00:00+09:00"},
+		// Same URL, different title
+		{URL: "https://example.com/a", Title: "Go 1.22 is released", BookmarkedAt: "2024-02-01T09:00:00+09:00"},
+	}
+
+	got := collapseDuplicateTitles(items)
+
+	if len(got) != 4 {
+		t.Fatalf("got %d bookmarks, want 4: %+v", len(got), got)
+	}
+	merged := got[0]
+	if merged.Title != "Go 1.22" || merged.BookmarkedAt != "2024-02-08T09:00:00+09:00" {
+		t.Errorf("merged = %+v, want the first title and the earliest date", merged)
+	}
+	if want := []string{"go", "release"}; !reflect.DeepEqual(merged.Tags, want) {
+		t.Errorf("merged Tags = %q, want %q", merged.Tags, want)
+	}
+	if want := []string{"https://example.com/b", "https://example.com/a", "https://example.com/a"}; !reflect.DeepEqual(bookmarkURLs(got[1:]), want) {
+		t.Errorf("remaining URLs = %q, want %q", bookmarkURLs(got[1:]), want)
+	}
+}
+
+func TestSortTags(t *testing.T) {
+	items := []types.BookmarkItem{
+		{Tags: []string{"rss", "Go", "go", "API", "日本語", "あ"}},
+		{Tags: nil},
+	}
+
+	sortTags(items)
+
+	want := []string{"API", "Go", "go", "rss", "あ", "日本語"}
+	if !reflect.DeepEqual(items[0].Tags, want) {
+		t.Errorf("tags = %q, want %q", items[0].Tags, want)
+	}
+	if items[1].Tags != nil {
+		t.Errorf("untagged bookmark tags = %q, want nil", items[1].Tags)
+	}
+}
+
+// tagTreePaths flattens a tag tree into the URLs held at each tag path
+func tagTreePaths(tree map[string]*types.TagNode, prefix string, paths map[string][]string) map[string][]string {
+	for name, node := range tree {
+		path := prefix + name
+		if len(node.Bookmarks) > 0 {
+			paths[path] = bookmarkURLs(node.Bookmarks)
+		}
+		tagTreePaths(node.Children, path+"/", paths)
+	}
+	return paths
+}
+
+func TestBuildTagTree(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "a", Tags: []string{"tech/go", "tech/rust"}},
+		{URL: "b", Tags: []string{"tech/go", "news"}},
+		{URL: "c", Tags: []string{"tech", "tech/go/generics"}},
+		{URL: "d", Tags: nil},
+		{URL: "e", Tags: []string{"/", " tech / go ", "tech/go"}},
+	}
+
+	tree, untagged := buildTagTree(items, "/")
+
+	want := map[string][]string{
+		"tech":             {"c"},
+		"tech/go":          {"a", "b", "e"},
+		"tech/rust":        {"a"},
+		"tech/go/generics": {"c"},
+		"news":             {"b"},
+	}
+	if got := tagTreePaths(tree, "", map[string][]string{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree paths = %v, want %v", got, want)
+	}
+	if got := bookmarkURLs(untagged); !reflect.DeepEqual(got, []string{"d"}) {
+		t.Errorf("untagged = %q, want [d]", got)
+	}
+	// Intermediate nodes exist even without bookmarks of their own
+	if node := tree["tech"].Children["go"].Children["generics"]; node == nil || len(node.Children) != 0 {
+		t.Errorf("tech/go/generics node = %+v, want a leaf", node)
+	}
+}
+
+func TestBuildTagTreeSeparator(t *testing.T) {
+	items := []types.BookmarkItem{{URL: "a", Tags: []string{"lang:go", "ci/cd"}}}
+
+	tree, _ := buildTagTree(items, ":")
+
+	want := map[string][]string{"lang/go": {"a"}, "ci/cd": {"a"}}
+	if got := tagTreePaths(tree, "", map[string][]string{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree paths = %v, want %v", got, want)
+	}
+	if tree["ci/cd"] == nil {
+		t.Error(`"ci/cd" should be a flat tag when splitting on ":"`)
+	}
+}
+
+func TestFilterByPresence(t *testing.T) {
+	items := []types.BookmarkItem{
+		{URL: "both", Comment: "nice", Tags: []string{"go"}},
+		{URL: "comment", Comment: "nice"},
+		{URL: "tags", Tags: []string{"go"}},
+		{URL: "neither", Tags: []string{}},
+	}
+	yes, no := true, false
+
+	tests := []struct {
+		name       string
+		hasComment *bool
+		hasTags    *bool
+		want       []string
+	}{
+		{"no criteria", nil, nil, []string{"both", "comment", "tags", "neither"}},
+		{"has comment", &yes, nil, []string{"both", "comment"}},
+		{"no comment", &no, nil, []string{"tags", "neither"}},
+		{"has tags", nil, &yes, []string{"both", "tags"}},
+		{"no tags", nil, &no, []string{"comment", "neither"}},
+		{"has comment and tags", &yes, &yes, []string{"both"}},
+		{"has comment but no tags", &yes, &no, []string{"comment"}},
+		{"has tags but no comment", &no, &yes, []string{"tags"}},
+		{"neither", &no, &no, []string{"neither"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bookmarkURLs(filterByPresence(items, tt.hasComment, tt.hasTags)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterByPresence() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLowercaseTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{"casing variants merge", []string{"Go", "go", "GO"}, []string{"go"}},
+		{"first position kept", []string{"RSS", "Go", "rss"}, []string{"rss", "go"}},
+		{"Japanese unchanged", []string{"日本語", "ひらがな", "カタカナ"}, []string{"日本語", "ひらがな", "カタカナ"}},
+		{"non-ASCII letters", []string{"Ünicode", "ÜNICODE", "Σ"}, []string{"ünicode", "σ"}},
+		{"full-width letters", []string{"ＧＯ", "ｇｏ"}, []string{"ｇｏ"}},
+		{"no tags", nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := []types.BookmarkItem{{Tags: tt.tags}}
+
+			lowercaseTags(items)
+
+			if !reflect.DeepEqual(items[0].Tags, tt.want) {
+				t.Errorf("tags = %q, want %q", items[0].Tags, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// normalizeURLFilter lowercases the scheme and host of a URL filter and drops
+// a default port, since Hatena matches the filter against the bookmarked URL
+// exactly. Unparseable input is returned unchanged for validation to reject.
+func normalizeURLFilter(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+	}
+
+	return u.String()
+}
+
+// urlFilterHint explains an empty result of a URL filter. It fetches the
+// user's first unfiltered page and, if a bookmark there is on the same host,
+// suggests its URL, preferring one that differs from the filter only in
+// scheme, "www.", trailing slash or fragment. It returns an empty string if
+// there is nothing to suggest or the lookup fails.
+func (s *BookmarkService) urlFilterHint(ctx context.Context, params types.GetHatenaBookmarksParams) string {
+	filter, err := url.Parse(params.URL)
+	if err != nil {
+		return ""
+	}
+
+	unfiltered := types.GetHatenaBookmarksParams{Username: params.Username, Tag: params.Tag, Date: params.Date}
+	requestURL, err := s.buildRequestURL(unfiltered)
+	if err != nil {
+		return ""
+	}
+	data, err := s.fetchAndParse(ctx, requestURL)
+	if err != nil {
+		s.log(ctx).Debug("Failed to look up URL filter candidates", "error", err)
+		return ""
+	}
+
+	suggestion := ""
+	for _, item := range data.Items {
+		candidate, err := url.Parse(item.URL)
+		if err != nil || looseHost(candidate) != looseHost(filter) {
+			continue
+		}
+		if loosePath(candidate) == loosePath(filter) {
+			suggestion = item.URL
+			break
+		}
+		if suggestion == "" {
+			suggestion = item.URL
+		}
+	}
+	if suggestion == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("url filter %q matched no bookmarks, but the user bookmarked %q on the same host; the filter must match the bookmarked URL exactly", params.URL, suggestion)
+}
+
+// looseHost returns the lowercased host of u without a leading "www."
+func looseHost(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// loosePath returns the path and query of u without a trailing slash
+func loosePath(u *url.URL) string {
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
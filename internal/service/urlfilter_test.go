@@ -0,0 +1,135 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+func TestNormalizeURLFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"canonical", "https://example.com/a", "https://example.com/a"},
+		{"uppercase scheme and host", "HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"default https port", "https://example.com:443/a", "https://example.com/a"},
+		{"default http port", "http://example.com:80/a", "http://example.com/a"},
+		{"other port", "https://example.com:8443/a", "https://example.com:8443/a"},
+		{"query kept", "https://example.com/a?b=C", "https://example.com/a?b=C"},
+		{"no host", "example.com/a", "example.com/a"},
+		{"unparseable", "https://exa mple.com/%zz", "https://exa mple.com/%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeURLFilter(tt.in); got != tt.want {
+				t.Errorf("normalizeURLFilter(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLooseHostAndPath(t *testing.T) {
+	a, _ := url.Parse("http://WWW.Example.com/post/?id=1#top")
+	b, _ := url.Parse("https://example.com/post?id=1")
+
+	if looseHost(a) != looseHost(b) || loosePath(a) != loosePath(b) {
+		t.Errorf("loose forms differ: %q %q, %q %q", looseHost(a), loosePath(a), looseHost(b), loosePath(b))
+	}
+}
+
+// urlFilterFeeds returns a handler serving an empty feed to URL-filtered
+// requests and bookmarks on the unfiltered first page, recording the filters
+func urlFilterFeeds(filters *[]string, mu *sync.Mutex, items ...testItem) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if filter := r.URL.Query().Get("url"); filter != "" {
+			mu.Lock()
+			*filters = append(*filters, filter)
+			mu.Unlock()
+			io.WriteString(w, rdfFeed())
+			return
+		}
+		if r.URL.Query().Get("page") != "" {
+			io.WriteString(w, rdfFeed())
+			return
+		}
+		io.WriteString(w, rdfFeed(items...))
+	}
+}
+
+func TestGetBookmarksURLFilterHint(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		items    []testItem
+		wantHint string
+	}{
+		{
+			name:   "non-canonical URL",
+			filter: "http://example.com/post",
+			items: []testItem{
+				{Title: "Other", Link: "https://www.example.com/other"},
+				{Title: "Post", Link: "https://www.example.com/post/"},
+			},
+			wantHint: `the user bookmarked "https://www.example.com/post/"`,
+		},
+		{
+			name:     "same host only",
+			filter:   "https://example.com/missing",
+			items:    []testItem{{Title: "Other", Link: "https://example.com/other"}},
+			wantHint: `the user bookmarked "https://example.com/other"`,
+		},
+		{
+			name:   "other host",
+			filter: "https://example.com/post",
+			items:  []testItem{{Title: "Post", Link: "https://example.org/post"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var filters []string
+			s := newTestService(t, urlFilterFeeds(&filters, &mu, tt.items...), Options{})
+
+			response := mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", URL: tt.filter})
+
+			if len(response.Bookmarks) != 0 {
+				t.Errorf("bookmarks = %d, want none", len(response.Bookmarks))
+			}
+			hint := ""
+			for _, warning := range response.Warnings {
+				if strings.Contains(warning, "matched no bookmarks") {
+					hint = warning
+				}
+			}
+			if tt.wantHint == "" && hint != "" {
+				t.Errorf("hint = %q, want none", hint)
+			}
+			if tt.wantHint != "" && !strings.Contains(hint, tt.wantHint) {
+				t.Errorf("hint = %q, want one containing %q", hint, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestGetBookmarksNormalizesURLFilter(t *testing.T) {
+	var mu sync.Mutex
+	var filters []string
+	s := newTestService(t, urlFilterFeeds(&filters, &mu), Options{})
+
+	mustGetBookmarks(t, s, types.GetHatenaBookmarksParams{Username: "alice", URL: " HTTPS://Example.com:443/post "})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(filters) == 0 || filters[0] != "https://example.com/post" {
+		t.Errorf("url filters sent = %q, want the normalized URL", filters)
+	}
+}
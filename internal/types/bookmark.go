@@ -1,5 +1,13 @@
 package types
 
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // GetHatenaBookmarksParams represents the parameters for the get_hatena_bookmarks tool
 type GetHatenaBookmarksParams struct {
 	Username string `json:"username"`           // Required: Hatena Bookmark username
@@ -7,15 +15,277 @@ type GetHatenaBookmarksParams struct {
 	Date     string `json:"date,omitempty"`     // Optional: Date filter (YYYYMMDD)
 	URL      string `json:"url,omitempty"`      // Optional: URL filter
 	Page     int    `json:"page,omitempty"`     // Optional: Page number (default: 1)
+
+	GroupByDate  bool   `json:"group_by_date,omitempty"`  // Optional: Group bookmarks by date
+	IncludeScore bool   `json:"include_score,omitempty"`  // Optional: Annotate bookmarks with an importance score
+	SortBy       string `json:"sort_by,omitempty"`        // Optional: Sort order ("score" or "date")
+	ErrorOnEmpty bool   `json:"error_on_empty,omitempty"` // Optional: Return an error when no bookmarks are found
+
+	ResolveShortURLs bool   `json:"resolve_short_urls,omitempty"` // Optional: Resolve shortened URLs into ResolvedURL
+	DateFormat       string `json:"date_format,omitempty"`        // Optional: Output date format (preset name or Go layout)
+	Timezone         string `json:"timezone,omitempty"`           // Optional: Output timezone (IANA name)
+	IncludeHeaders   bool   `json:"include_headers,omitempty"`    // Optional: Attach selected response headers for debugging
+
+	CollapseDuplicateTitles bool `json:"collapse_duplicate_titles,omitempty"` // Optional: Merge consecutive same-URL, same-title bookmarks
+	FetchAll                bool `json:"fetch_all,omitempty"`                 // Optional: Fetch every page instead of a single one
+	SortTags                bool `json:"sort_tags,omitempty"`                 // Optional: Sort each bookmark's tags
+	BestEffort              bool `json:"best_effort,omitempty"`               // Optional: Return pages fetched before a failure
+	IncludeEpoch            bool `json:"include_epoch,omitempty"`             // Optional: Add bookmarked_at_unix to each bookmark
+	AnnotatePage            bool `json:"annotate_page,omitempty"`             // Optional: Add source_page to each bookmark
+	TagTree                 bool `json:"tag_tree,omitempty"`                  // Optional: Nest bookmarks by hierarchical tag in TagTree
+	LowercaseTags           bool `json:"lowercase_tags,omitempty"`            // Optional: Lowercase tags, merging casing variants
+
+	TagSeparator string `json:"tag_separator,omitempty"` // Optional: Separator of hierarchical tags (default: "/")
+	MinResults   int    `json:"min_results,omitempty"`   // Optional: Keep paging until this many bookmarks are found
+
+	NoCache bool `json:"no_cache,omitempty"` // Optional: Fetch fresh data instead of using the cache
+
+	RawXML string `json:"raw_xml,omitempty"` // Optional: Parse this feed XML instead of fetching
+	Cursor string `json:"cursor,omitempty"`  // Optional: Resume from a NextCursor
+}
+
+// FlexibleInt is an integer that also accepts a numeric JSON string ("2"),
+// since some MCP clients send numbers as strings
+type FlexibleInt int
+
+// UnmarshalJSON accepts both JSON numbers and numeric strings
+func (f *FlexibleInt) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*f = FlexibleInt(n)
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return &MCPError{
+			Code:    ErrorCodeValidation,
+			Message: "Value must be an integer",
+			Details: map[string]interface{}{"value": string(data)},
+		}
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil {
+		return (&MCPError{
+			Code:    ErrorCodeValidation,
+			Message: fmt.Sprintf("Value must be an integer, got %q", str),
+			Details: map[string]interface{}{"value": str},
+		}).WithCause(err)
+	}
+
+	*f = FlexibleInt(n)
+	return nil
 }
 
+// SchemaVersion is the version of the response schema.
+// Bump it whenever a breaking change is made to the response format.
+const SchemaVersion = "1"
+
 // GetHatenaBookmarksResponse represents the response from the get_hatena_bookmarks tool
 type GetHatenaBookmarksResponse struct {
-	User       string          `json:"user"`
-	Page       int             `json:"page"`
-	TotalCount int             `json:"total_count"`
-	Filters    *FilterParams   `json:"filters,omitempty"`
-	Bookmarks  []BookmarkItem  `json:"bookmarks"`
+	SchemaVersion string         `json:"schema_version"`
+	User          string         `json:"user"`
+	Page          int            `json:"page"`
+	TotalCount    int            `json:"total_count"`
+	Filters       *FilterParams  `json:"filters,omitempty"`
+	Filtered      bool           `json:"filtered"` // A filter was active, even if it matched nothing
+	Bookmarks     []BookmarkItem `json:"bookmarks"`
+	DateGroups    []DateGroup    `json:"date_groups,omitempty"`
+
+	TagTree map[string]*TagNode `json:"tag_tree,omitempty"` // Bookmarks nested by tag path; untagged ones stay in Bookmarks
+
+	DebugHeaders  map[string]string `json:"debug_headers,omitempty"`
+	ChecksLimited bool              `json:"checks_limited,omitempty"` // Per-item checks were capped
+	Warnings      []string          `json:"warnings,omitempty"`       // Non-fatal problems, e.g. unparseable dates
+
+	DateFallbackCount int `json:"date_fallback_count,omitempty"` // Bookmarks dated with the parse time for lack of a feed date
+
+	Truncated   bool   `json:"truncated,omitempty"`    // fetch_all stopped before the last page
+	TruncatedBy string `json:"truncated_by,omitempty"` // Limit that stopped it: max_pages, max_items, overall_timeout or page_error
+	NextCursor  string `json:"next_cursor,omitempty"`  // Opaque cursor for the next page
+
+	MinResultsMet *bool `json:"min_results_met,omitempty"` // Whether min_results bookmarks were found
+
+	Errors []*MCPError `json:"errors,omitempty"` // Page failures skipped by best_effort
+}
+
+// TagNode represents one segment of a hierarchical tag such as "tech/go",
+// holding the bookmarks tagged with exactly this path and the deeper nodes
+type TagNode struct {
+	Children  map[string]*TagNode `json:"children,omitempty"`
+	Bookmarks []BookmarkItem      `json:"bookmarks,omitempty"`
+}
+
+// DateGroup represents bookmarks bookmarked on the same date
+type DateGroup struct {
+	Date      string         `json:"date"` // YYYY-MM-DD
+	Bookmarks []BookmarkItem `json:"bookmarks"`
+}
+
+// MonthCount represents the number of bookmarks made in a month
+type MonthCount struct {
+	Month string `json:"month"` // YYYY-MM
+	Count int    `json:"count"`
+}
+
+// UserOverview represents the response from the get_user_overview tool
+type UserOverview struct {
+	User            string         `json:"user"`
+	TotalBookmarks  int            `json:"total_bookmarks"`
+	Truncated       bool           `json:"truncated,omitempty"` // Fetching stopped at a limit, so total_bookmarks is a lower bound
+	TopTags         []TagCount     `json:"top_tags"`
+	TopDomains      []DomainCount  `json:"top_domains"`
+	Activity        []MonthCount   `json:"activity"` // Bookmarks per month, oldest first
+	RecentBookmarks []BookmarkItem `json:"recent_bookmarks"`
+}
+
+// TagCount represents a tag and how often it occurs
+type TagCount struct {
+	Tag       string `json:"tag"`
+	Count     int    `json:"count"`
+	FirstSeen string `json:"first_seen,omitempty"` // Earliest bookmark date with the tag, when requested
+}
+
+// TagCountsResponse represents the response from the get_tag_counts tool
+type TagCountsResponse struct {
+	User           string     `json:"user"`
+	TotalBookmarks int        `json:"total_bookmarks"`
+	Tags           []TagCount `json:"tags"`
+}
+
+// SuggestTagsResponse represents the response from the suggest_tags tool
+type SuggestTagsResponse struct {
+	User        string     `json:"user"`
+	Tag         string     `json:"tag"`
+	Suggestions []TagCount `json:"suggestions"`
+}
+
+// BookmarkStats represents aggregate statistics over a user's bookmarks
+type BookmarkStats struct {
+	User                   string  `json:"user"`
+	TotalBookmarks         int     `json:"total_bookmarks"`
+	DistinctTags           int     `json:"distinct_tags"`
+	DistinctDomains        int     `json:"distinct_domains"`
+	AverageTagsPerBookmark float64 `json:"average_tags_per_bookmark"`
+	CommentPercentage      float64 `json:"comment_percentage"`
+	OldestBookmarkedAt     string  `json:"oldest_bookmarked_at,omitempty"`
+	NewestBookmarkedAt     string  `json:"newest_bookmarked_at,omitempty"`
+}
+
+// InvalidateCacheResponse represents the response from the invalidate_cache tool
+type InvalidateCacheResponse struct {
+	User        string `json:"user"`
+	Invalidated int    `json:"invalidated"` // Number of cached results dropped
+}
+
+// WordCount represents a word and how often it occurs
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// CommentKeywordsResponse represents the response from the get_comment_keywords tool
+type CommentKeywordsResponse struct {
+	User     string      `json:"user"`
+	Keywords []WordCount `json:"keywords"`
+}
+
+// EntryBookmarker represents one user's bookmark of an entry
+type EntryBookmarker struct {
+	User         string   `json:"user"`
+	Comment      string   `json:"comment,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	BookmarkedAt string   `json:"bookmarked_at,omitempty"`
+}
+
+// EntryDetail represents the response from the get_entry_detail tool.
+// Counts are null when their lookup failed; Errors says why.
+type EntryDetail struct {
+	URL           string            `json:"url"`
+	BookmarkCount *int              `json:"bookmark_count"`
+	Bookmarkers   []EntryBookmarker `json:"bookmarkers"`
+	StarCount     *int              `json:"star_count"`
+	Errors        map[string]string `json:"errors,omitempty"`
+}
+
+// ReadLaterResponse represents the response from the get_read_later tool
+type ReadLaterResponse struct {
+	User          string         `json:"user"`
+	Tag           string         `json:"tag"`
+	OlderThanDays int            `json:"older_than_days,omitempty"`
+	Count         int            `json:"count"`
+	Bookmarks     []BookmarkItem `json:"bookmarks"`
+}
+
+// TagFeedResponse represents the response from the get_tag_feed tool
+type TagFeedResponse struct {
+	Tag        string         `json:"tag"`
+	Sort       string         `json:"sort"`
+	Page       int            `json:"page"`
+	TotalCount int            `json:"total_count"`
+	Bookmarks  []BookmarkItem `json:"bookmarks"`
+}
+
+// DomainCount represents a host and how many bookmarks point to it
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// DomainCountResponse represents the response from the get_domain_count tool
+type DomainCountResponse struct {
+	User            string        `json:"user"`
+	GroupBy         string        `json:"group_by"` // "host", or "path" for host and path without query
+	TotalBookmarks  int           `json:"total_bookmarks"`
+	DistinctDomains int           `json:"distinct_domains"`
+	TopDomains      []DomainCount `json:"top_domains"`
+}
+
+// RecentDomain represents a recently bookmarked host with its most recent
+// bookmark
+type RecentDomain struct {
+	Domain     string       `json:"domain"`
+	FaviconURL string       `json:"favicon_url"`
+	Bookmark   BookmarkItem `json:"bookmark"`
+}
+
+// RecentDomainsResponse represents the response from the get_recent_domains tool
+type RecentDomainsResponse struct {
+	User    string         `json:"user"`
+	Count   int            `json:"count"`
+	Domains []RecentDomain `json:"domains"`
+}
+
+// FilterBookmarksResponse represents the response from the filter_bookmarks tool
+type FilterBookmarksResponse struct {
+	User         string         `json:"user"`
+	Page         int            `json:"page"`
+	HasComment   *bool          `json:"has_comment"` // Criterion applied, null if not filtered on
+	HasTags      *bool          `json:"has_tags"`    // Criterion applied, null if not filtered on
+	TotalCount   int            `json:"total_count"` // Bookmarks on the page before filtering
+	MatchedCount int            `json:"matched_count"`
+	Bookmarks    []BookmarkItem `json:"bookmarks"`
+}
+
+// CompareUsersResponse represents the response from the compare_users tool
+type CompareUsersResponse struct {
+	UserA       string   `json:"user_a"`
+	UserB       string   `json:"user_b"`
+	Pages       int      `json:"pages"` // Pages fetched per user at most
+	SharedCount int      `json:"shared_count"`
+	SharedURLs  []string `json:"shared_urls"`
+	UniqueA     int      `json:"unique_a"`   // Distinct URLs only user_a bookmarked
+	UniqueB     int      `json:"unique_b"`   // Distinct URLs only user_b bookmarked
+	Similarity  float64  `json:"similarity"` // Jaccard similarity of the URL sets
+}
+
+// CheckUserResponse represents the response from the check_user tool.
+// Exists is null when the status code does not tell (neither 200 nor 404).
+type CheckUserResponse struct {
+	User       string `json:"user"`
+	Exists     *bool  `json:"exists"`
+	StatusCode int    `json:"status"`
 }
 
 // FilterParams represents the applied filters
@@ -32,6 +302,19 @@ type BookmarkItem struct {
 	BookmarkedAt string   `json:"bookmarked_at"` // ISO 8601 format
 	Tags         []string `json:"tags"`
 	Comment      string   `json:"comment,omitempty"`
+	GUID         string   `json:"guid,omitempty"`         // Feed item identifier (RSS guid or RDF rdf:about)
+	FullTitle    string   `json:"full_title,omitempty"`   // Original title when Title was truncated
+	RawTitle     string   `json:"raw_title,omitempty"`    // Feed title when Title was derived from the URL
+	ResolvedURL  string   `json:"resolved_url,omitempty"` // Final URL of a shortened link
+	EntryURL     string   `json:"entry_url,omitempty"`    // Hatena Bookmark entry page of the URL
+	Creator      string   `json:"creator,omitempty"`      // User who made the bookmark, in multi-user feeds
+	DateFallback bool     `json:"-"`                      // BookmarkedAt is the parse time, not a feed date
+
+	BookmarkedAtUnix int64 `json:"bookmarked_at_unix,omitempty"` // BookmarkedAt as UNIX seconds
+	SourcePage       int   `json:"source_page,omitempty"`        // Feed page the bookmark was fetched from
+
+	BookmarkCount int     `json:"bookmark_count,omitempty"`
+	Score         float64 `json:"score,omitempty"`
 }
 
 // RSS XML structure for parsing Hatena Bookmark RSS feeds
@@ -55,14 +338,29 @@ type Item struct {
 	Link        string   `xml:"link"`
 	Description string   `xml:"description"`
 	PubDate     string   `xml:"pubDate"`
+	GUID        string   `xml:"guid"`
 	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	DCDate      string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+
+	BookmarkCount int `xml:"http://www.hatena.ne.jp/info/xmlns# bookmarkcount"`
 }
 
 // ParsedRSSData represents the intermediate parsed RSS data
 type ParsedRSSData struct {
-	Title     string
-	Items     []BookmarkItem
-	ItemCount int
+	Title      string         `json:"title"`
+	Items      []BookmarkItem `json:"items"`
+	ItemCount  int            `json:"item_count"`
+	TotalItems int            `json:"total_items"`        // Items in the feed, including skipped ones
+	Warnings   []string       `json:"warnings,omitempty"` // Non-fatal parsing problems
+
+	// ResponseHeaders holds selected headers of the response the data was
+	// parsed from
+	ResponseHeaders map[string]string `json:"-"`
+
+	// PageErrors holds the page failures a best-effort fetch of all pages
+	// stopped at
+	PageErrors []*MCPError `json:"-"`
 }
 
 // Error types for better error handling
@@ -75,17 +373,50 @@ const (
 	ErrorCodeAPI        ErrorCode = "API_ERROR"
 )
 
+// Sentinel errors for matching MCPError values by code with errors.Is
+var (
+	ErrValidation = &MCPError{Code: ErrorCodeValidation, Message: "validation error"}
+	ErrNetwork    = &MCPError{Code: ErrorCodeNetwork, Message: "network error"}
+	ErrParsing    = &MCPError{Code: ErrorCodeParsing, Message: "parsing error"}
+	ErrAPI        = &MCPError{Code: ErrorCodeAPI, Message: "API error"}
+)
+
+// FieldError describes a validation failure for a single parameter
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
 // MCPError represents an error response for MCP
 type MCPError struct {
 	Code    ErrorCode   `json:"code"`
 	Message string      `json:"message"`
 	Details interface{} `json:"details,omitempty"`
+
+	cause error
 }
 
 func (e *MCPError) Error() string {
 	return e.Message
 }
 
+// Is reports whether target is an MCPError with the same code
+func (e *MCPError) Is(target error) bool {
+	t, ok := target.(*MCPError)
+	return ok && t.Code == e.Code
+}
+
+// WithCause records err as the underlying cause and returns e
+func (e *MCPError) WithCause(err error) *MCPError {
+	e.cause = err
+	return e
+}
+
+// Unwrap returns the underlying cause, if any
+func (e *MCPError) Unwrap() error {
+	return e.cause
+}
+
 // RDF XML structure for parsing Hatena Bookmark RDF/RSS 1.0 feeds
 type RDF struct {
 	XMLName string     `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# RDF"`
@@ -110,13 +441,24 @@ type RDFChannel struct {
 
 // RDFItem represents a single RDF item (bookmark) with proper namespace handling
 type RDFItem struct {
-	About         string `xml:"about,attr"`
-	Title         string `xml:"title"`
-	Link          string `xml:"link"`
-	Description   string `xml:"description"`
-	Creator       string `xml:"http://purl.org/dc/elements/1.1/ creator"`
-	Date          string `xml:"http://purl.org/dc/elements/1.1/ date"`
-	Subject       string `xml:"http://purl.org/dc/elements/1.1/ subject"`
-	BookmarkCount int    `xml:"http://www.hatena.ne.jp/info/xmlns# bookmarkcount"`
-	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	About          string   `xml:"about,attr"`
+	Title          string   `xml:"title"`
+	Link           string   `xml:"link"`
+	Description    string   `xml:"description"`
+	Creator        string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Date           string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Subjects       []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	BookmarkCount  int      `xml:"http://www.hatena.ne.jp/info/xmlns# bookmarkcount"`
+	ContentEncoded string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate        string   `xml:"pubDate"`
+
+	// Others holds child elements not matched above, e.g. dc:date declared
+	// with a non-standard namespace URI
+	Others []XMLElement `xml:",any"`
+}
+
+// XMLElement represents a raw XML element with its text content
+type XMLElement struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
 }
\ No newline at end of file
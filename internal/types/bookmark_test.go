@@ -0,0 +1,108 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMCPErrorIs(t *testing.T) {
+	err := &MCPError{Code: ErrorCodeNetwork, Message: "Failed to fetch RSS feed: connection refused"}
+
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"same code", err, ErrNetwork, true},
+		{"other code", err, ErrParsing, false},
+		{"wrapped", fmt.Errorf("fetching page 2: %w", err), ErrNetwork, true},
+		{"wrapped other code", fmt.Errorf("fetching page 2: %w", err), ErrAPI, false},
+		{"plain error", errors.New("network error"), ErrNetwork, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMCPErrorAs(t *testing.T) {
+	wrapped := fmt.Errorf("get_hatena_bookmarks: %w", &MCPError{Code: ErrorCodeValidation, Message: "username is required"})
+
+	var mcpErr *MCPError
+	if !errors.As(wrapped, &mcpErr) {
+		t.Fatal("errors.As() = false, want true")
+	}
+	if mcpErr.Code != ErrorCodeValidation || mcpErr.Error() != "username is required" {
+		t.Errorf("got %q %q", mcpErr.Code, mcpErr.Error())
+	}
+}
+
+func TestMCPErrorWithCause(t *testing.T) {
+	cause := errors.New("connection reset by peer")
+	err := (&MCPError{Code: ErrorCodeNetwork, Message: "Failed to fetch RSS feed"}).WithCause(cause)
+
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false, want true")
+	}
+	if !errors.Is(err, ErrNetwork) {
+		t.Error("errors.Is(err, ErrNetwork) = false, want the code to still match")
+	}
+	if err.Error() != "Failed to fetch RSS feed" {
+		t.Errorf("Error() = %q, want the message alone", err.Error())
+	}
+	if (&MCPError{}).Unwrap() != nil {
+		t.Error("Unwrap() of an error without a cause != nil")
+	}
+}
+
+func TestFlexibleIntUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    FlexibleInt
+		wantErr bool
+	}{
+		{`2`, 2, false},
+		{`"2"`, 2, false},
+		{`" 3 "`, 3, false},
+		{`"-1"`, -1, false},
+		{`"two"`, 0, true},
+		{`""`, 0, true},
+		{`2.5`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got FlexibleInt
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidation) {
+					t.Errorf("error = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlexibleIntInStruct(t *testing.T) {
+	var params struct {
+		Page FlexibleInt `json:"page,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(`{"page":"4"}`), &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Page != 4 {
+		t.Errorf("Page = %d, want 4", params.Page)
+	}
+}
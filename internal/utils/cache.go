@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// Cache is an in-memory key-value cache whose entries expire after a fixed
+// TTL. Negative entries, standing for results that found nothing, expire
+// after their own, typically shorter, TTL. It is safe for concurrent use.
+type Cache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	negativeTTL time.Duration
+	clock       func() time.Time
+	entries     map[string]cacheEntry
+
+	// cleanupHook, if set, runs at the start of each cleanup pass; tests
+	// use it to inject failures
+	cleanupHook func()
+}
+
+// cacheEntry is a cached value and the time it expires. hits counts the
+// lookups that found it, and refreshing marks it as claimed by ClaimRefresh.
+type cacheEntry struct {
+	value      interface{}
+	expiresAt  time.Time
+	negative   bool
+	hits       int
+	refreshing bool
+}
+
+// hotEntryHits is the number of lookups after which an entry counts as
+// hot, and so worth refreshing before it expires
+const hotEntryHits = 2
+
+// CacheOptions configures a Cache
+type CacheOptions struct {
+	// TTL is how long entries are kept
+	TTL time.Duration
+
+	// NegativeTTL is how long negative entries are kept; SetNegative does
+	// nothing if it is not positive
+	NegativeTTL time.Duration
+
+	// Clock returns the current time (defaults to time.Now)
+	Clock func() time.Time
+}
+
+// NewCache creates a cache whose entries expire ttl after being set, as
+// measured by clock (time.Now if nil). It keeps no negative entries.
+func NewCache(ttl time.Duration, clock func() time.Time) *Cache {
+	return NewCacheWithOptions(CacheOptions{TTL: ttl, Clock: clock})
+}
+
+// NewCacheWithOptions creates a cache with the given options
+func NewCacheWithOptions(options CacheOptions) *Cache {
+	if options.Clock == nil {
+		options.Clock = time.Now
+	}
+	return &Cache{
+		ttl:         options.TTL,
+		negativeTTL: options.NegativeTTL,
+		clock:       options.Clock,
+		entries:     make(map[string]cacheEntry),
+	}
+}
+
+// Get returns the value cached under key, if it has not expired
+func (c *Cache) Get(key string) (interface{}, bool) {
+	value, _, ok := c.Lookup(key)
+	return value, ok
+}
+
+// Lookup returns the value cached under key, if it has not expired, and
+// whether it is a negative entry
+func (c *Cache) Lookup(key string) (value interface{}, negative bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, false
+	}
+	if !c.clock().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false, false
+	}
+	entry.hits++
+	c.entries[key] = entry
+	return entry.value, entry.negative, true
+}
+
+// ClaimRefresh reports whether the entry under key should be refreshed
+// now: it is a hot, non-negative entry expiring within ahead that nobody
+// else has claimed. A successful claim lasts until the entry is replaced or
+// the claim is released, so that each entry is refreshed at most once.
+func (c *Cache) ClaimRefresh(key string, ahead time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.negative || entry.refreshing || entry.hits < hotEntryHits {
+		return false
+	}
+	remaining := entry.expiresAt.Sub(c.clock())
+	if remaining <= 0 || remaining > ahead {
+		return false
+	}
+	entry.refreshing = true
+	c.entries[key] = entry
+	return true
+}
+
+// ReleaseRefresh gives up a claim made by ClaimRefresh without replacing
+// the entry, so that a later lookup can claim it again
+func (c *Cache) ReleaseRefresh(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[key]; ok {
+		entry.refreshing = false
+		c.entries[key] = entry
+	}
+}
+
+// Set caches value under key for the cache's TTL, replacing any previous
+// value. Expired entries are dropped at the same time.
+func (c *Cache) Set(key string, value interface{}) {
+	c.set(key, value, c.ttl, false)
+}
+
+// SetNegative caches value under key as a negative entry, such as an empty
+// result, for the cache's negative TTL, replacing any previous value
+func (c *Cache) SetNegative(key string, value interface{}) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+	c.set(key, value, c.negativeTTL, true)
+}
+
+// set caches value under key for ttl
+func (c *Cache) set(key string, value interface{}, ttl time.Duration, negative bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock()
+	c.deleteExpired(now)
+	c.entries[key] = cacheEntry{value: value, expiresAt: now.Add(ttl), negative: negative}
+}
+
+// DeleteExpired drops the expired entries and returns how many were dropped
+func (c *Cache) DeleteExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.deleteExpired(c.clock())
+}
+
+// deleteExpired drops the entries expired at now; c.mu must be held
+func (c *Cache) deleteExpired(now time.Time) int {
+	deleted := 0
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// StartCleanup starts a goroutine that drops expired entries every
+// interval, so that entries which are never read again do not accumulate,
+// until the returned function is called. A panic in a cleanup pass is
+// logged and the loop carries on at the next tick.
+func (c *Cache) StartCleanup(interval time.Duration, logger *slog.Logger) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.cleanup(logger)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// cleanup runs one cleanup pass, recovering from a panic in it
+func (c *Cache) cleanup(logger *slog.Logger) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Cache cleanup panicked; retrying at the next tick", "panic", r)
+		}
+	}()
+
+	if c.cleanupHook != nil {
+		c.cleanupHook()
+	}
+	if deleted := c.DeleteExpired(); deleted > 0 {
+		logger.Debug("Dropped expired cache entries", "count", deleted)
+	}
+}
+
+// InvalidateUser drops the entries of username's calls within namespace, as
+// identified by the key prefix GenerateCacheKey sets, and returns how many
+// were dropped. The username ends at a separator, so that invalidating "bob"
+// leaves the entries of "bobby" in place, and entries of other namespaces
+// sharing the cache are kept.
+func (c *Cache) InvalidateUser(namespace, username string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := username + cacheKeySeparator + namespace + cacheKeySeparator
+	deleted := 0
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Len returns the number of entries, including expired ones not yet dropped
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// cacheKeySeparator separates the parts of a cache key. Usernames cannot
+// contain it, so a username prefix cannot run into the next part.
+const cacheKeySeparator = ":"
+
+// fetchKey holds the get_hatena_bookmarks parameters that shape what is
+// fetched. The others, such as sorting and date formatting, only change how
+// the fetched bookmarks are presented.
+type fetchKey struct {
+	Username     string `json:"username"`
+	Tag          string `json:"tag"`
+	Date         string `json:"date"`
+	URL          string `json:"url"`
+	Page         int    `json:"page"`
+	FetchAll     bool   `json:"fetch_all"`
+	MinResults   int    `json:"min_results"`
+	AnnotatePage bool   `json:"annotate_page"`
+	BestEffort   bool   `json:"best_effort"`
+}
+
+// GenerateCacheKey returns a key identifying the fetch of a
+// get_hatena_bookmarks call within namespace, prefixed with the username and
+// namespace so that a user's entries can be invalidated together. Only the
+// parameters that shape the fetch are keyed on, so that calls differing in
+// response options share an entry. The cursor is expected to have been
+// applied to the filters and page already.
+func GenerateCacheKey(namespace string, params types.GetHatenaBookmarksParams) string {
+	data, err := json.Marshal(fetchKey{
+		Username:     params.Username,
+		Tag:          params.Tag,
+		Date:         params.Date,
+		URL:          params.URL,
+		Page:         params.Page,
+		FetchAll:     params.FetchAll,
+		MinResults:   params.MinResults,
+		AnnotatePage: params.AnnotatePage,
+		BestEffort:   params.BestEffort,
+	})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return params.Username + cacheKeySeparator + namespace + cacheKeySeparator + hex.EncodeToString(sum[:])
+}
+
+// CacheNamespace returns a short identifier for a configuration described
+// by parts, such as a base URL and an auth identity, so that services
+// sharing a cache under different configurations do not share entries. The
+// parts are hashed, keeping keys short and credentials out of them.
+func CacheNamespace(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
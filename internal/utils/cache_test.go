@@ -0,0 +1,341 @@
+package utils
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// testClock is a manually advanced clock for cache tests
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// newTestClock returns a clock set to a fixed time
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 2, 10, 9, 0, 0, 0, time.UTC)}
+}
+
+// Now returns the current test time
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// syncBuffer is a bytes.Buffer safe for use by a logger and a test at once
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStartCleanupDropsExpiredEntries(t *testing.T) {
+	clock := newTestClock()
+	cache := NewCache(time.Minute, clock.Now)
+	cache.Set("stale", 1)
+	clock.Advance(30 * time.Second)
+	cache.Set("fresh", 2)
+	clock.Advance(45 * time.Second)
+
+	stop := cache.StartCleanup(time.Millisecond, slog.New(slog.NewTextHandler(&syncBuffer{}, nil)))
+	defer stop()
+
+	waitFor(t, "the stale entry to be dropped", func() bool { return cache.Len() == 1 })
+	if _, ok := cache.Get("fresh"); !ok {
+		t.Error("fresh entry was dropped")
+	}
+}
+
+func TestStartCleanupSurvivesPanic(t *testing.T) {
+	clock := newTestClock()
+	cache := NewCache(time.Minute, clock.Now)
+	cache.Set("stale", 1)
+	clock.Advance(2 * time.Minute)
+
+	// The first pass panics; later passes must still run
+	var passes atomic.Int32
+	cache.cleanupHook = func() {
+		if passes.Add(1) == 1 {
+			panic("injected cleanup failure")
+		}
+	}
+
+	var logs syncBuffer
+	stop := cache.StartCleanup(time.Millisecond, slog.New(slog.NewTextHandler(&logs, nil)))
+	defer stop()
+
+	waitFor(t, "a cleanup pass after the panic", func() bool { return passes.Load() >= 2 && cache.Len() == 0 })
+	if !strings.Contains(logs.String(), "Cache cleanup panicked") || !strings.Contains(logs.String(), "injected cleanup failure") {
+		t.Errorf("logs = %q, want the panic logged", logs.String())
+	}
+}
+
+func TestStartCleanupStop(t *testing.T) {
+	cache := NewCache(time.Minute, nil)
+	var passes atomic.Int32
+	cache.cleanupHook = func() { passes.Add(1) }
+
+	stop := cache.StartCleanup(time.Millisecond, slog.New(slog.NewTextHandler(&syncBuffer{}, nil)))
+	waitFor(t, "a cleanup pass", func() bool { return passes.Load() > 0 })
+	stop()
+	stop() // stopping twice is harmless
+
+	// Allow a pass already under way to finish
+	time.Sleep(5 * time.Millisecond)
+	stopped := passes.Load()
+	time.Sleep(20 * time.Millisecond)
+	if passes.Load() != stopped {
+		t.Error("cleanup kept running after stop")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	clock := newTestClock()
+	cache := NewCache(time.Minute, clock.Now)
+	cache.Set("key", 1)
+
+	if value, ok := cache.Get("key"); !ok || value != 1 {
+		t.Fatalf("Get() = %v, %v, want the cached value", value, ok)
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := cache.Get("key"); ok {
+		t.Error("entry outlived its TTL")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d, want the expired entry dropped on lookup", cache.Len())
+	}
+}
+
+func TestCacheNegativeEntriesExpireIndependently(t *testing.T) {
+	clock := newTestClock()
+	cache := NewCacheWithOptions(CacheOptions{TTL: 5 * time.Minute, NegativeTTL: 30 * time.Second, Clock: clock.Now})
+	cache.Set("full", 1)
+	cache.SetNegative("empty", 0)
+
+	if _, negative, ok := cache.Lookup("empty"); !ok || !negative {
+		t.Fatalf("Lookup(empty) = negative %v, ok %v, want a negative entry", negative, ok)
+	}
+	if _, negative, ok := cache.Lookup("full"); !ok || negative {
+		t.Fatalf("Lookup(full) = negative %v, ok %v, want a positive entry", negative, ok)
+	}
+
+	clock.Advance(30 * time.Second)
+	if _, ok := cache.Get("empty"); ok {
+		t.Error("negative entry outlived NegativeTTL")
+	}
+	if _, ok := cache.Get("full"); !ok {
+		t.Error("positive entry expired with the negative one")
+	}
+
+	clock.Advance(5 * time.Minute)
+	if _, ok := cache.Get("full"); ok {
+		t.Error("positive entry outlived TTL")
+	}
+}
+
+func TestCacheSetNegativeWithoutNegativeTTL(t *testing.T) {
+	cache := NewCache(time.Minute, nil)
+	cache.SetNegative("empty", 0)
+
+	if _, ok := cache.Get("empty"); ok || cache.Len() != 0 {
+		t.Error("SetNegative cached an entry without a negative TTL")
+	}
+}
+
+func TestInvalidateUser(t *testing.T) {
+	cache := NewCache(time.Minute, nil)
+	cache.Set(GenerateCacheKey("ns", types.GetHatenaBookmarksParams{Username: "bob"}), 1)
+	cache.Set(GenerateCacheKey("ns", types.GetHatenaBookmarksParams{Username: "bob", Page: 2}), 2)
+	bobby := GenerateCacheKey("ns", types.GetHatenaBookmarksParams{Username: "bobby"})
+	cache.Set(bobby, 3)
+	alice := GenerateCacheKey("ns", types.GetHatenaBookmarksParams{Username: "alice", Tag: "bob"})
+	cache.Set(alice, 4)
+
+	if got := cache.InvalidateUser("ns", "bob"); got != 2 {
+		t.Errorf("InvalidateUser(bob) = %d, want 2", got)
+	}
+	if _, ok := cache.Get(bobby); !ok {
+		t.Error("invalidating bob dropped bobby's entry")
+	}
+	if _, ok := cache.Get(alice); !ok {
+		t.Error("invalidating bob dropped alice's entry")
+	}
+	if got := cache.InvalidateUser("ns", "bo"); got != 0 {
+		t.Errorf("InvalidateUser(bo) = %d, want 0", got)
+	}
+}
+
+func TestInvalidateUserNamespaces(t *testing.T) {
+	cache := NewCache(time.Minute, nil)
+	params := types.GetHatenaBookmarksParams{Username: "bob"}
+	tenantA := GenerateCacheKey(CacheNamespace("https://a.example"), params)
+	tenantB := GenerateCacheKey(CacheNamespace("https://b.example"), params)
+	cache.Set(tenantA, 1)
+	cache.Set(tenantB, 2)
+
+	if got := cache.InvalidateUser(CacheNamespace("https://a.example"), "bob"); got != 1 {
+		t.Errorf("InvalidateUser() = %d, want 1", got)
+	}
+	if _, ok := cache.Get(tenantA); ok {
+		t.Error("bob's entry in the invalidated namespace was kept")
+	}
+	if _, ok := cache.Get(tenantB); !ok {
+		t.Error("invalidating one namespace dropped bob's entry in another")
+	}
+}
+
+func TestGenerateCacheKey(t *testing.T) {
+	params := types.GetHatenaBookmarksParams{Username: "alice", Tag: "go", Page: 2}
+	key := GenerateCacheKey("ns", params)
+
+	if !strings.HasPrefix(key, "alice:ns:") {
+		t.Errorf("key = %q, want the username and namespace prefix", key)
+	}
+
+	// Response options do not change what is fetched
+	same := params
+	same.Cursor = "opaque"
+	same.NoCache = true
+	same.SortTags = true
+	same.SortBy = "score"
+	same.LowercaseTags = true
+	same.DateFormat = "jp"
+	same.Timezone = "Asia/Tokyo"
+	same.GroupByDate = true
+	same.IncludeEpoch = true
+	if GenerateCacheKey("ns", same) != key {
+		t.Error("a response option changed the key")
+	}
+
+	for name, other := range map[string]types.GetHatenaBookmarksParams{
+		"page":          {Username: "alice", Tag: "go", Page: 3},
+		"tag":           {Username: "alice", Tag: "rust", Page: 2},
+		"date":          {Username: "alice", Tag: "go", Page: 2, Date: "20240210"},
+		"url":           {Username: "alice", Tag: "go", Page: 2, URL: "https://example.com/"},
+		"fetch_all":     {Username: "alice", Tag: "go", Page: 2, FetchAll: true},
+		"min_results":   {Username: "alice", Tag: "go", Page: 2, MinResults: 5},
+		"annotate_page": {Username: "alice", Tag: "go", Page: 2, AnnotatePage: true},
+		"best_effort":   {Username: "alice", Tag: "go", Page: 2, BestEffort: true},
+		"username":      {Username: "bob", Tag: "go", Page: 2},
+	} {
+		if GenerateCacheKey("ns", other) == key {
+			t.Errorf("changing the %s kept the key", name)
+		}
+	}
+	if GenerateCacheKey("other", params) == key {
+		t.Error("changing the namespace kept the key")
+	}
+}
+
+func TestCacheNamespace(t *testing.T) {
+	namespace := CacheNamespace("https://b.hatena.ne.jp", "bearer", "secret-token")
+
+	if len(namespace) != 16 || strings.Contains(namespace, "secret") {
+		t.Errorf("namespace = %q, want a short hash", namespace)
+	}
+	if CacheNamespace("https://b.hatena.ne.jp", "bearer", "secret-token") != namespace {
+		t.Error("namespace is not stable")
+	}
+	if CacheNamespace("https://mirror.example", "bearer", "secret-token") == namespace {
+		t.Error("a different base URL gave the same namespace")
+	}
+	if CacheNamespace("ab", "c") == CacheNamespace("a", "bc") {
+		t.Error("moving the boundary between parts gave the same namespace")
+	}
+}
+
+func TestCacheClaimRefresh(t *testing.T) {
+	clock := newTestClock()
+	cache := NewCacheWithOptions(CacheOptions{TTL: 5 * time.Minute, NegativeTTL: 5 * time.Minute, Clock: clock.Now})
+	cache.Set("hot", 1)
+	cache.Set("cold", 2)
+	cache.SetNegative("negative", 0)
+	for i := 0; i < hotEntryHits; i++ {
+		cache.Get("hot")
+		cache.Get("negative")
+	}
+
+	if cache.ClaimRefresh("hot", time.Minute) {
+		t.Error("claimed an entry not yet within the refresh window")
+	}
+
+	clock.Advance(4*time.Minute + 30*time.Second)
+	if cache.ClaimRefresh("cold", time.Minute) {
+		t.Error("claimed an entry that is not hot")
+	}
+	if cache.ClaimRefresh("negative", time.Minute) {
+		t.Error("claimed a negative entry")
+	}
+	if cache.ClaimRefresh("missing", time.Minute) {
+		t.Error("claimed a missing entry")
+	}
+	if !cache.ClaimRefresh("hot", time.Minute) {
+		t.Fatal("could not claim a hot entry within the refresh window")
+	}
+	if cache.ClaimRefresh("hot", time.Minute) {
+		t.Error("claimed an entry twice")
+	}
+
+	cache.ReleaseRefresh("hot")
+	if !cache.ClaimRefresh("hot", time.Minute) {
+		t.Error("could not reclaim a released entry")
+	}
+
+	// Replacing the entry ends the claim and resets its hits
+	cache.Set("hot", 3)
+	clock.Advance(4*time.Minute + 30*time.Second)
+	if cache.ClaimRefresh("hot", time.Minute) {
+		t.Error("claimed a replaced entry before it was hot again")
+	}
+	for i := 0; i < hotEntryHits; i++ {
+		cache.Get("hot")
+	}
+	if !cache.ClaimRefresh("hot", time.Minute) {
+		t.Error("could not claim a replaced entry once hot")
+	}
+
+	clock.Advance(time.Minute)
+	if cache.ClaimRefresh("hot", time.Minute) {
+		t.Error("claimed an expired entry")
+	}
+}
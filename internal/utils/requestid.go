@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+// RequestIDHeader is the HTTP header carrying the request ID to upstream
+// servers and gateways
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key for the request ID
+type requestIDKey struct{}
+
+// NewRequestID returns a short random ID for correlating the log lines of
+// one tool call
+func NewRequestID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// maxRequestIDLength bounds the length of a request ID accepted from a client
+const maxRequestIDLength = 128
+
+// IsValidRequestID reports whether a request ID received from a client may
+// be used: it must be non-empty, at most maxRequestIDLength bytes and consist
+// of printable ASCII characters, so that it is safe in headers and logs
+func IsValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or an empty
+// string if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// LoggerFromContext returns logger annotated with the request ID carried by
+// ctx, or logger itself if there is none
+func LoggerFromContext(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return logger.With("request_id", requestID)
+	}
+	return logger
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewRequestID(t *testing.T) {
+	first, second := NewRequestID(), NewRequestID()
+
+	if !regexp.MustCompile(`^[0-9a-f]{8}$`).MatchString(first) {
+		t.Errorf("NewRequestID() = %q, want 8 hex digits", first)
+	}
+	if first == second {
+		t.Errorf("NewRequestID() returned %q twice", first)
+	}
+	if !IsValidRequestID(first) {
+		t.Errorf("generated request ID %q is not valid", first)
+	}
+}
+
+func TestIsValidRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		want      bool
+	}{
+		{"hex", "1a2b3c4d", true},
+		{"UUID", "123e4567-e89b-12d3-a456-426614174000", true},
+		{"punctuation", "trace:abc/def", true},
+		{"maximum length", strings.Repeat("a", maxRequestIDLength), true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"space", "a b", false},
+		{"newline", "abc\r\nX-Injected: 1", false},
+		{"non-ASCII", "リクエスト", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidRequestID(tt.requestID); got != tt.want {
+				t.Errorf("IsValidRequestID(%q) = %v, want %v", tt.requestID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestIDContext(t *testing.T) {
+	if got := RequestIDFromContext(context.Background()); got != "" {
+		t.Errorf("RequestIDFromContext() = %q without an ID", got)
+	}
+
+	ctx := WithRequestID(context.Background(), "1a2b3c4d")
+	if got := RequestIDFromContext(ctx); got != "1a2b3c4d" {
+		t.Errorf("RequestIDFromContext() = %q, want 1a2b3c4d", got)
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	if LoggerFromContext(context.Background(), logger) != logger {
+		t.Error("LoggerFromContext() changed the logger without a request ID")
+	}
+
+	LoggerFromContext(WithRequestID(context.Background(), "1a2b3c4d"), logger).Info("fetching")
+	if !strings.Contains(logs.String(), "request_id=1a2b3c4d") {
+		t.Errorf("log line = %q, want the request ID", logs.String())
+	}
+}
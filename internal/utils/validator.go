@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"net/url"
 	"regexp"
 	"strconv"
@@ -51,6 +52,59 @@ func (v *Validator) ValidateGetBookmarksParams(params types.GetHatenaBookmarksPa
 	return nil
 }
 
+// ValidateAll validates the parameters for GetBookmarks like
+// ValidateGetBookmarksParams, but collects every field error instead of
+// stopping at the first one. The returned MCPError lists each failure as a
+// types.FieldError in Details.
+func (v *Validator) ValidateAll(params types.GetHatenaBookmarksParams) error {
+	var fieldErrors []types.FieldError
+
+	collect := func(field string, err error) {
+		if err == nil {
+			return
+		}
+		fieldErrors = append(fieldErrors, types.FieldError{Field: field, Message: err.Error()})
+	}
+
+	// Raw XML input is parsed as is, so no username is needed
+	if params.RawXML == "" {
+		collect("username", v.ValidateUsername(params.Username))
+	}
+
+	if params.Tag != "" {
+		collect("tag", v.ValidateTag(params.Tag))
+	}
+
+	if params.Date != "" {
+		collect("date", v.ValidateDate(params.Date))
+	}
+
+	if params.URL != "" {
+		collect("url", v.ValidateURL(params.URL))
+	}
+
+	collect("page", v.ValidatePage(params.Page))
+
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+
+	message := fieldErrors[0].Message
+	if len(fieldErrors) > 1 {
+		messages := make([]string, 0, len(fieldErrors))
+		for _, fieldError := range fieldErrors {
+			messages = append(messages, fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message))
+		}
+		message = fmt.Sprintf("Validation failed for %d fields: %s", len(fieldErrors), strings.Join(messages, "; "))
+	}
+
+	return &types.MCPError{
+		Code:    types.ErrorCodeValidation,
+		Message: message,
+		Details: fieldErrors,
+	}
+}
+
 // ValidateUsername validates the username parameter
 func (v *Validator) ValidateUsername(username string) error {
 	username = strings.TrimSpace(username)
@@ -72,6 +126,15 @@ func (v *Validator) ValidateUsername(username string) error {
 		}
 	}
 
+	// Clients sometimes send the username already URL-encoded
+	if IsPercentEncoded(username) {
+		return &types.MCPError{
+			Code:    types.ErrorCodeValidation,
+			Message: "username must not be URL-encoded; send the raw username",
+			Details: map[string]interface{}{"username": username},
+		}
+	}
+
 	// Username should contain only alphanumeric characters and hyphens
 	validUsernameRegex := regexp.MustCompile(`^[a-zA-Z0-9\-]+$`)
 	if !validUsernameRegex.MatchString(username) {
@@ -85,6 +148,14 @@ func (v *Validator) ValidateUsername(username string) error {
 	return nil
 }
 
+// percentEncodingRegex matches a percent-encoded byte such as %61
+var percentEncodingRegex = regexp.MustCompile(`%[0-9A-Fa-f]{2}`)
+
+// IsPercentEncoded reports whether s contains percent-encoded bytes
+func IsPercentEncoded(s string) bool {
+	return percentEncodingRegex.MatchString(s)
+}
+
 // ValidateTag validates the tag parameter
 func (v *Validator) ValidateTag(tag string) error {
 	tag = strings.TrimSpace(tag)
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"hatena-bookmark-mcp/internal/types"
+)
+
+// checkValidation asserts that err is nil, or a validation error containing
+// wantErr when wantErr is set
+func checkValidation(t *testing.T, err error, wantErr string) {
+	t.Helper()
+
+	if wantErr == "" {
+		if err != nil {
+			t.Errorf("error = %v, want nil", err)
+		}
+		return
+	}
+	if !errors.Is(err, types.ErrValidation) || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("error = %v, want a validation error containing %q", err, wantErr)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{"https", "https://example.com/article?id=1", ""},
+		{"http", "http://example.com/", ""},
+		{"surrounding whitespace", "  https://example.com/  ", ""},
+		{"no scheme", "example.com/article", "URL must include scheme"},
+		{"no host", "https:///article", "URL must include host"},
+		{"other scheme", "ftp://example.com/file", "URL scheme must be http or https"},
+		{"javascript", "javascript://example.com/%0Aalert(1)", "URL scheme must be http or https"},
+		{"unparseable", "https://exa mple.com/", "Invalid URL format"},
+		{"too long", "https://example.com/" + strings.Repeat("a", 2000), "2000 characters or less"},
+	}
+
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkValidation(t, v.ValidateURL(tt.url), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	v := NewValidator()
+
+	if err := v.ValidateAll(types.GetHatenaBookmarksParams{Username: "alice", Tag: "go", Date: "20240210", Page: 2}); err != nil {
+		t.Fatalf("ValidateAll() error = %v, want nil for valid params", err)
+	}
+
+	t.Run("one failure", func(t *testing.T) {
+		err := v.ValidateAll(types.GetHatenaBookmarksParams{Username: "alice", Date: "2024-02-10"})
+
+		var mcpErr *types.MCPError
+		if !errors.As(err, &mcpErr) {
+			t.Fatalf("error = %v, want an MCPError", err)
+		}
+		if mcpErr.Message != "Date must be in YYYYMMDD format" {
+			t.Errorf("Message = %q, want the field's own message", mcpErr.Message)
+		}
+	})
+
+	t.Run("every failure", func(t *testing.T) {
+		err := v.ValidateAll(types.GetHatenaBookmarksParams{
+			Username: "not valid!",
+			Tag:      "<script>",
+			Date:     "20241399",
+			URL:      "example.com",
+			Page:     -1,
+		})
+
+		var mcpErr *types.MCPError
+		if !errors.As(err, &mcpErr) || mcpErr.Code != types.ErrorCodeValidation {
+			t.Fatalf("error = %v, want a validation MCPError", err)
+		}
+		fieldErrors, ok := mcpErr.Details.([]types.FieldError)
+		if !ok {
+			t.Fatalf("Details = %T, want []types.FieldError", mcpErr.Details)
+		}
+
+		var fields []string
+		for _, fieldError := range fieldErrors {
+			fields = append(fields, fieldError.Field)
+		}
+		if want := []string{"username", "tag", "date", "url", "page"}; strings.Join(fields, ",") != strings.Join(want, ",") {
+			t.Errorf("fields = %q, want %q", fields, want)
+		}
+		if !strings.HasPrefix(mcpErr.Message, "Validation failed for 5 fields: username: ") {
+			t.Errorf("Message = %q", mcpErr.Message)
+		}
+	})
+
+	t.Run("raw XML needs no username", func(t *testing.T) {
+		if err := v.ValidateAll(types.GetHatenaBookmarksParams{RawXML: "<rss/>"}); err != nil {
+			t.Errorf("ValidateAll() error = %v, want nil", err)
+		}
+	})
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantErr  string
+	}{
+		{"plain", "alice", ""},
+		{"hyphen and digits", "alice-2024", ""},
+		{"surrounding whitespace", " alice ", ""},
+		{"empty", "  ", "Username is required"},
+		{"too long", strings.Repeat("a", 51), "50 characters or less"},
+		{"percent-encoded", "%61lice", "username must not be URL-encoded; send the raw username"},
+		{"encoded space", "ali%20ce", "username must not be URL-encoded"},
+		{"raw space", "ali ce", "only alphanumeric characters and hyphens"},
+		{"lone percent", "alice%", "only alphanumeric characters and hyphens"},
+		{"path separator", "a/b", "only alphanumeric characters and hyphens"},
+	}
+
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkValidation(t, v.ValidateUsername(tt.username), tt.wantErr)
+		})
+	}
+}
+
+func TestIsPercentEncoded(t *testing.T) {
+	tests := map[string]bool{
+		"%61lice":  true,
+		"a%2Fb":    true,
+		"a%2fb":    true,
+		"alice":    false,
+		"100%":     false,
+		"%zz":      false,
+		"50% off!": false,
+	}
+
+	for s, want := range tests {
+		if got := IsPercentEncoded(s); got != want {
+			t.Errorf("IsPercentEncoded(%q) = %v, want %v", s, got, want)
+		}
+	}
+}